@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// maxRequestBodySize limits the size of JSON bodies accepted by the write API.
+const maxRequestBodySize = 1 << 20
+
+func readJSON(r *http.Request, w http.ResponseWriter, v interface{}) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err := dec.Decode(v); err != nil {
+		return errors.New("invalid JSON")
+	}
+	return nil
+}
+
+func validateFAQText(text *FAQText) error {
+	text.Question = strings.TrimSpace(text.Question)
+	text.Answer = strings.TrimSpace(text.Answer)
+
+	if !isSupportedLocale(text.Locale.Code) {
+		return fmt.Errorf("unsupported locale: %v", text.Locale.Code)
+	}
+	if len(text.Question) == 0 {
+		return errors.New("question empty")
+	}
+	if len(text.Answer) == 0 {
+		return errors.New("answer empty")
+	}
+	return nil
+}
+
+func faqIDParam(ps httprouter.Params) (int, bool) {
+	id, err := strconv.Atoi(ps.ByName("id"))
+	return id, err == nil && id > 0
+}
+
+func postAPIFAQ(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	input := FAQ{}
+	if err := readJSON(r, w, &input); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateFAQTexts(input.Texts); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.CategoryID != 0 {
+		categories, err := faqRepository.AllCategories()
+		if err != nil {
+			writeJSONErr(w, http.StatusInternalServerError, internalError)
+			return
+		}
+		if categoryByID(categories, input.CategoryID) == nil {
+			writeJSONErr(w, http.StatusBadRequest, "category not found")
+			return
+		}
+	}
+
+	faq := FAQ{Texts: apiFAQTexts(input.Texts, apiAuthor(r))}
+	if input.CategoryID != 0 {
+		faq.CategoryID = input.CategoryID
+		faq.Position = input.Position
+	}
+	err := faqRepository.CreateFAQWithTexts(&faq)
+	if err == errCategoryNotFound {
+		writeJSONErr(w, http.StatusBadRequest, "category not found")
+		return
+	}
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/faqs/%d", faq.ID))
+	writeJSONWithStatus(w, http.StatusCreated, faq)
+}
+
+func putAPIFAQText(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, ok := faqIDParam(ps)
+	if !ok {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+
+	text := FAQText{}
+	if err := readJSON(r, w, &text); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	text.Locale = localeFromCode(ps.ByName("locale"))
+	text.Author = apiAuthor(r)
+	if err := validateFAQText(&text); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err := faqRepository.SaveFAQText(faqID, &text)
+	if err == errFAQNotFound {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+	if err == errVersionConflict {
+		writeJSONErr(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	writeJSON(w, text)
+}
+
+// putAPIFAQTexts saves texts in several locales at once. Either all texts
+// are saved or none.
+func putAPIFAQTexts(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, ok := faqIDParam(ps)
+	if !ok {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+
+	input := []FAQText{}
+	if err := readJSON(r, w, &input); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateFAQTexts(input); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	texts := apiFAQTexts(input, apiAuthor(r))
+	err := faqRepository.SaveFAQTexts(faqID, texts)
+	if err == errFAQNotFound {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+	if err == errVersionConflict {
+		writeJSONErr(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	writeJSON(w, texts)
+}
+
+// validateFAQTexts validates texts, which must not be empty and may have one
+// text per locale.
+func validateFAQTexts(texts []FAQText) error {
+	if len(texts) == 0 {
+		return errors.New("texts empty")
+	}
+	seen := make(map[string]bool)
+	for i := range texts {
+		text := &texts[i]
+		if err := validateFAQText(text); err != nil {
+			return err
+		}
+		if seen[text.Locale.Code] {
+			return fmt.Errorf("duplicate locale: %v", text.Locale.Code)
+		}
+		seen[text.Locale.Code] = true
+	}
+	return nil
+}
+
+// apiFAQTexts returns texts with full locales, authored by author.
+func apiFAQTexts(texts []FAQText, author string) []FAQText {
+	result := []FAQText{}
+	for _, text := range texts {
+		text.Locale = localeFromCode(text.Locale.Code)
+		text.Author = author
+		result = append(result, text)
+	}
+	return result
+}
+
+func deleteAPIFAQ(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, ok := faqIDParam(ps)
+	if !ok {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+
+	err := faqRepository.DeleteFAQ(faqID, apiAuthor(r))
+	if err == errFAQNotFound {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deleteAPIFAQText(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, ok := faqIDParam(ps)
+	if !ok {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+
+	err := faqRepository.DeleteFAQText(faqID, ps.ByName("locale"), apiAuthor(r))
+	if err == errFAQTextNotFound {
+		writeJSONErr(w, http.StatusNotFound, "faq text not found")
+		return
+	}
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
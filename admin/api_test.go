@@ -0,0 +1,131 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func jsonHeader() *http.Header {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	return &header
+}
+
+func TestPostAPIFAQ(t *testing.T) {
+	faqRepository = &mockDB{}
+	resp := doRequestWithHeader("POST", "/api/faqs", body(`{"texts":[{"locale":{"code":"en"},"question":"Why?","answer":"Because."}]}`), jsonHeader())
+
+	expectStatus(t, resp, 201)
+	expectHeader(t, resp, "Content-Type", "application/json")
+	expectHeader(t, resp, "Location", "/api/faqs/123")
+	expectBodyContains(t, resp, `{"id":123,"texts":[{"locale":{"code":"en","name_en":"English","name_local":"English"},"question":"Why?","answer":"Because."}]}`)
+}
+
+func TestPostAPIFAQValidation(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("POST", "/api/faqs", body(`{"texts":`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "invalid JSON")
+
+	resp = doRequestWithHeader("POST", "/api/faqs", body(`{"texts":[]}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "texts empty")
+
+	resp = doRequestWithHeader("POST", "/api/faqs", body(`{"texts":[{"locale":{"code":"xx"},"question":"q","answer":"a"}]}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "unsupported locale: xx")
+
+	resp = doRequestWithHeader("POST", "/api/faqs", body(`{"texts":[{"locale":{"code":"en"},"question":" ","answer":"a"}]}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "question empty")
+
+	resp = doRequestWithHeader("POST", "/api/faqs", body(`{"texts":[{"locale":{"code":"en"},"question":"q","answer":""}]}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "answer empty")
+
+	resp = doRequestWithHeader("POST", "/api/faqs", body(`{"texts":[{"locale":{"code":"en"},"question":"q","answer":"a"},{"locale":{"code":"en"},"question":"q","answer":"a"}]}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "duplicate locale: en")
+}
+
+func TestPostAPIFAQWithBrokenDB(t *testing.T) {
+	faqRepository = &brokenDB{}
+	resp := doRequestWithHeader("POST", "/api/faqs", body(`{"texts":[{"locale":{"code":"en"},"question":"q","answer":"a"}]}`), jsonHeader())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestPutAPIFAQText(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("PUT", "/api/faqs/123/texts/de", body(`{"question":"Warum?","answer":"Darum."}`), jsonHeader())
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Type", "application/json")
+	expectBodyContains(t, resp, `{"locale":{"code":"de","name_en":"German","name_local":"Deutsch"},"question":"Warum?","answer":"Darum."}`)
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/not-a-valid-id/texts/de", body(`{"question":"q","answer":"a"}`), jsonHeader())
+	expectErrorJSON(t, resp, 404, "faq not found")
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/123/texts/xx", body(`{"question":"q","answer":"a"}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "unsupported locale: xx")
+
+	faqRepository = &brokenDB{}
+	resp = doRequestWithHeader("PUT", "/api/faqs/123/texts/de", body(`{"question":"q","answer":"a"}`), jsonHeader())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestPutAPIFAQTextConflict(t *testing.T) {
+	faqRepository = NewMemoryDB()
+	resp := doRequestWithHeader("POST", "/api/faqs", body(`{"texts":[{"locale":{"code":"en"},"question":"Why?","answer":"Because."}]}`), jsonHeader())
+	expectStatus(t, resp, 201)
+	expectBodyContains(t, resp, `"answer":"Because.","version":1}`)
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/1/texts/en", body(`{"question":"Why?","answer":"Just because.","version":1}`), jsonHeader())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"answer":"Just because.","version":2}`)
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/1/texts/en", body(`{"question":"Why?","answer":"No idea.","version":1}`), jsonHeader())
+	expectErrorJSON(t, resp, 409, "version conflict")
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/1/texts", body(`[{"locale":{"code":"en"},"question":"Why?","answer":"No idea.","version":1}]`), jsonHeader())
+	expectErrorJSON(t, resp, 409, "version conflict")
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/1/texts/en", body(`{"question":"Why?","answer":"No idea."}`), jsonHeader())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"answer":"No idea.","version":3}`)
+}
+
+func TestDeleteAPIFAQ(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("DELETE", "/api/faqs/123", emptyBody())
+	expectStatus(t, resp, 204)
+
+	resp = doRequest("DELETE", "/api/faqs/not-a-valid-id", emptyBody())
+	expectErrorJSON(t, resp, 404, "faq not found")
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("DELETE", "/api/faqs/123", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestDeleteAPIFAQText(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("DELETE", "/api/faqs/123/texts/de", emptyBody())
+	expectStatus(t, resp, 204)
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("DELETE", "/api/faqs/123/texts/de", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestSaveTextForMissingFAQ(t *testing.T) {
+	repo := prepareDB()
+
+	txt := FAQText{Question: "question", Answer: "answer", Locale: Locale{Code: "en"}}
+	err := repo.SaveFAQText(999999, &txt)
+	expectSameError(t, errFAQNotFound, err)
+
+	err = repo.DeleteFAQ(999999, testAuthor)
+	expectSameError(t, errFAQNotFound, err)
+
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+	err = repo.DeleteFAQText(f.ID, "en", testAuthor)
+	expectSameError(t, errFAQTextNotFound, err)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Actions recorded in the audit log. Text edits are recorded as revisions.
+const (
+	auditPublishText    = "publish text"
+	auditDeleteFAQ      = "delete faq"
+	auditDeleteText     = "delete text"
+	auditRestoreFAQ     = "restore faq"
+	auditPurgeFAQ       = "purge faq"
+	auditPurgeTrash     = "purge trash"
+	auditCreateCategory = "create category"
+	auditSaveCategory   = "save category"
+	auditDeleteCategory = "delete category"
+	auditMoveFAQ        = "move faq"
+	auditMoveFAQs       = "move faqs"
+	auditMoveCategories = "move categories"
+	auditCreateLocale   = "create locale"
+	auditSaveLocale     = "save locale"
+	auditMoveLocales    = "move locales"
+	auditCreateUser     = "create user"
+	auditSaveUser       = "save user"
+	auditDeleteUser     = "delete user"
+)
+
+// systemAuthor is recorded for changes faqaas makes on its own, like seeding
+// locales and users or emptying the trash.
+const systemAuthor = "system"
+
+// auditPageSize is the number of entries shown on the audit log page.
+const auditPageSize = 200
+
+// AuditEntry records who did what. The entry is written in the transaction
+// of the change.
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	Author    string    `json:"author"`
+	Action    string    `json:"action"`
+	Details   string    `json:"details"` // What was changed, e.g. "faq 12, de"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func faqDetails(faqID int) string {
+	return fmt.Sprintf("faq %d", faqID)
+}
+
+func faqTextDetails(faqID int, localeCode string) string {
+	return fmt.Sprintf("faq %d, %s", faqID, localeCode)
+}
+
+func faqMoveDetails(faqID int, categoryID int, position int) string {
+	return fmt.Sprintf("faq %d to category %d, position %d", faqID, categoryID, position)
+}
+
+func faqsMoveDetails(categoryID int, faqIDs []int) string {
+	return fmt.Sprintf("faqs %s to category %d", idList(faqIDs), categoryID)
+}
+
+func purgeDetails(n int, deletedBefore time.Time) string {
+	return fmt.Sprintf("%d faqs deleted before %s", n, deletedBefore.UTC().Format("2006-01-02 15:04"))
+}
+
+func categoryDetails(slug string) string {
+	return "category " + slug
+}
+
+func categoryIDDetails(categoryID int) string {
+	return fmt.Sprintf("category %d", categoryID)
+}
+
+func categoriesDetails(categoryIDs []int) string {
+	return "categories " + idList(categoryIDs)
+}
+
+func localeDetails(code string) string {
+	return "locale " + code
+}
+
+func localesDetails(codes []string) string {
+	return "locales " + strings.Join(codes, ", ")
+}
+
+func userDetails(user *User) string {
+	return fmt.Sprintf("user %s, %s", user.Email, user.Role)
+}
+
+func userIDDetails(userID int) string {
+	return fmt.Sprintf("user %d", userID)
+}
+
+func idList(ids []int) string {
+	s := []string{}
+	for _, id := range ids {
+		s = append(s, strconv.Itoa(id))
+	}
+	return strings.Join(s, ", ")
+}
+
+///// Audit persistence
+
+func (db *DB) AuditLog(limit int) ([]AuditEntry, error) {
+	return getAuditLog(db.DB, limit)
+}
+
+func (mdb *mockDB) AuditLog(limit int) ([]AuditEntry, error) {
+	createdAt := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	return []AuditEntry{AuditEntry{ID: 1, Author: adminUserEmail, Action: auditPublishText, Details: faqTextDetails(123, "de"), CreatedAt: createdAt}}, nil
+}
+
+func (mdb *brokenDB) AuditLog(limit int) ([]AuditEntry, error) {
+	return nil, errors.New(someDBError)
+}
+
+// withAudit runs fn in a transaction and records the change in the audit
+// log.
+func withAudit(db *sql.DB, author string, action string, details string, fn func(tx *sql.Tx) error) error {
+	return withTx(db, func(tx *sql.Tx) error {
+		err := fn(tx)
+		if err != nil {
+			return err
+		}
+		return addAuditEntry(tx, author, action, details)
+	})
+}
+
+func addAuditEntry(db dbtx, author string, action string, details string) error {
+	// UTC keeps the text representation of SQLite sortable
+	_, err := db.Exec(`INSERT INTO audit_log (author, action, details, created_at) VALUES ($1, $2, $3, $4);`,
+		author, action, details, time.Now().UTC())
+	if err != nil {
+		logError(err)
+	}
+	return err
+}
+
+func getAuditLog(db *sql.DB, limit int) ([]AuditEntry, error) {
+	rows, err := db.Query(`
+		SELECT id, author, action, details, created_at FROM audit_log
+		ORDER BY id DESC
+		LIMIT $1;`, limit)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		e := AuditEntry{}
+		err = rows.Scan(&e.ID, &e.Author, &e.Action, &e.Details, &e.CreatedAt)
+		if err != nil {
+			logError(err)
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+///// Audit handlers
+
+type AuditPageData struct {
+	PageTitle string
+	MenuBar   []MenuEntry
+	Entries   []AuditEntry
+}
+
+func getAdminAudit(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	entries, err := faqRepository.AuditLog(auditPageSize)
+	if err != nil {
+		panic(err)
+	}
+	data := AuditPageData{
+		PageTitle: "Admin / Audit Log",
+		MenuBar:   menuBar("Audit Log"),
+		Entries:   entries,
+	}
+	mustExecuteTemplate(tmplAdminAudit, w, data)
+}
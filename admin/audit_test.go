@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testAuthor is recorded as the author of the changes tests make.
+const testAuthor = "test@example.com"
+
+func testAuditLog(t *testing.T, repo FAQRepository) {
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+	txt := FAQText{Question: "question", Answer: "answer", Locale: Locale{Code: "en"}}
+	expectNoError(t, repo.SaveFAQText(f.ID, &txt))
+
+	expectNoError(t, repo.PublishFAQText(f.ID, "en", "eddie@example.com"))
+	expectSameError(t, errFAQTextNotFound, repo.PublishFAQText(f.ID, "de", "eddie@example.com"))
+	expectNoError(t, repo.DeleteFAQ(f.ID, "anna@example.com"))
+	expectNoError(t, repo.RestoreFAQ(f.ID, apiKeyAuthor))
+
+	entries, err := repo.AuditLog(2)
+	expectNoError(t, err)
+	expectSameInt(t, 2, len(entries))
+	expectSameString(t, apiKeyAuthor, entries[0].Author)
+	expectSameString(t, auditRestoreFAQ, entries[0].Action)
+	expectSameString(t, faqDetails(f.ID), entries[0].Details)
+	expectSameString(t, "anna@example.com", entries[1].Author)
+	expectSameString(t, auditDeleteFAQ, entries[1].Action)
+
+	entries, err = repo.AuditLog(auditPageSize)
+	expectNoError(t, err)
+	expectSameInt(t, 3, len(entries))
+	expectSameString(t, "eddie@example.com", entries[2].Author)
+	expectSameString(t, auditPublishText, entries[2].Action)
+	expectSameString(t, faqTextDetails(f.ID, "en"), entries[2].Details)
+}
+
+func TestAuditLog(t *testing.T) {
+	testAuditLog(t, NewMemoryDB())
+}
+
+func TestAuditLogInDB(t *testing.T) {
+	testAuditLog(t, prepareDB())
+}
+
+func TestAdminChangesAreAudited(t *testing.T) {
+	repo := seedUsersDB(t)
+	faqRepository = repo
+	defer requireLogin()()
+
+	resp := doRequestWithHeader("POST", "/admin/faqs/publish", body("faqID=2&localeCode=de"), loginHeader("eddie@example.com"))
+	expectStatus(t, resp, 302)
+
+	entries, err := repo.AuditLog(1)
+	expectNoError(t, err)
+	expectSameString(t, "eddie@example.com", entries[0].Author)
+	expectSameString(t, faqTextDetails(2, "de"), entries[0].Details)
+
+	resp = doRequestWithHeader("GET", "/admin/audit", emptyBody(), loginHeader("eddie@example.com"))
+	expectStatus(t, resp, 403)
+	resp = doRequestWithHeader("GET", "/admin/audit", emptyBody(), loginHeader("ada@example.com"))
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, "<td>eddie@example.com</td>")
+}
+
+func TestGetAdminAudit(t *testing.T) {
+	faqRepository = &mockDB{}
+	resp := doRequest("GET", "/admin/audit", emptyBody())
+
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, "<td>2018-06-01 12:00</td>")
+	expectBodyContains(t, resp, "<td>faq 123, de</td>")
+}
+
+func TestAPIChangesAreAuditedByUser(t *testing.T) {
+	repo := seedUsersDB(t)
+	faqRepository = repo
+	authenticateFunc = func(email string, password string) (*User, error) { return repo.UserByEmail(email) }
+	defer func() { authenticateFunc = alwaysAdminFunc }()
+
+	resp := doRequest("POST", "/api/faqs/2/texts/de/publish", emptyBody())
+	expectStatus(t, resp, 200)
+	entries, err := repo.AuditLog(1)
+	expectNoError(t, err)
+	expectSameString(t, apiKeyAuthor, entries[0].Author)
+
+	resp = doRequestWithHeader("DELETE", "/api/faqs/2", emptyBody(), basicAuthHeader("vera@example.com"))
+	expectStatus(t, resp, 403)
+	resp = doRequestWithHeader("DELETE", "/api/faqs/2", emptyBody(), basicAuthHeader("eddie@example.com"))
+	expectStatus(t, resp, 204)
+	entries, err = repo.AuditLog(1)
+	expectNoError(t, err)
+	expectSameString(t, "eddie@example.com", entries[0].Author)
+	expectSameString(t, auditDeleteFAQ, entries[0].Action)
+}
+
+func basicAuthHeader(email string) *http.Header {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.SetBasicAuth(email, "secret")
+	return &r.Header
+}
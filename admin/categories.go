@@ -0,0 +1,689 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+type Category struct {
+	ID       int            `json:"id"`
+	Slug     string         `json:"slug"`
+	Position int            `json:"position"`
+	Names    []CategoryName `json:"names"`
+}
+
+type CategoryName struct {
+	Locale Locale `json:"locale"`
+	Name   string `json:"name"`
+}
+
+// NameForLocale returns the category name in the given locale, falling back
+// along the fallback chain of the locale and finally to the slug.
+func (c *Category) NameForLocale(localeCode string) string {
+	if name, ok := c.ResolvedName(localeCode); ok {
+		return name.Name
+	}
+	return c.Slug
+}
+
+// ResolvedName returns the first name along the fallback chain of
+// localeCode. ok is false if the category has no name in any of its locales.
+func (c *Category) ResolvedName(localeCode string) (name CategoryName, ok bool) {
+	for _, code := range fallbackChain(localeCode) {
+		for _, n := range c.Names {
+			if n.Locale.Code == code && len(n.Name) > 0 {
+				return n, true
+			}
+		}
+	}
+	return CategoryName{}, false
+}
+
+func (c *Category) NameInDefaultLocale() string {
+	return c.NameForLocale(getDefaultLocale().Code)
+}
+
+var (
+	errCategoryNotFound = errors.New("category not found")
+	errCategoryExists   = errors.New("category already exists")
+)
+
+var slugRegexp = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+func isValidSlug(slug string) bool {
+	return slugRegexp.MatchString(slug)
+}
+
+func categoryBySlug(categories []Category, slug string) *Category {
+	for i := range categories {
+		if categories[i].Slug == slug {
+			return &categories[i]
+		}
+	}
+	return nil
+}
+
+func categoryByID(categories []Category, id int) *Category {
+	for i := range categories {
+		if categories[i].ID == id {
+			return &categories[i]
+		}
+	}
+	return nil
+}
+
+func faqsInCategory(faqs []FAQ, categoryID int) []FAQ {
+	result := []FAQ{}
+	for _, f := range faqs {
+		if f.CategoryID == categoryID {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// FAQSection groups the FAQs of one category for the admin FAQ list.
+// Category is nil for the section of uncategorized FAQs.
+type FAQSection struct {
+	Category *Category
+	FAQs     []FAQ
+}
+
+func faqSections(faqs []FAQ, categories []Category) []FAQSection {
+	sections := []FAQSection{}
+	for i := range categories {
+		sections = append(sections, FAQSection{
+			Category: &categories[i],
+			FAQs:     faqsInCategory(faqs, categories[i].ID),
+		})
+	}
+
+	uncategorized := []FAQ{}
+	for _, f := range faqs {
+		if categoryByID(categories, f.CategoryID) == nil {
+			uncategorized = append(uncategorized, f)
+		}
+	}
+	if len(uncategorized) > 0 {
+		sections = append(sections, FAQSection{FAQs: uncategorized})
+	}
+	return sections
+}
+
+// moveID moves id one place up or down within ids and returns the new order.
+// It returns false if id is not in ids or cannot be moved any further.
+func moveID(ids []int, id int, direction string) ([]int, bool) {
+	idx := -1
+	for i, v := range ids {
+		if v == id {
+			idx = i
+		}
+	}
+	other := idx + 1
+	if direction == "up" {
+		other = idx - 1
+	}
+	if idx < 0 || other < 0 || other >= len(ids) {
+		return ids, false
+	}
+
+	moved := append([]int{}, ids...)
+	moved[idx], moved[other] = moved[other], moved[idx]
+	return moved, true
+}
+
+///// Category persistence
+
+func (db *DB) AllCategories() ([]Category, error) {
+	return getAllCategories(db.DB)
+}
+
+func (db *DB) CreateCategory(slug string, author string) (*Category, error) {
+	var category *Category
+	err := withAudit(db.DB, author, auditCreateCategory, categoryDetails(slug), func(tx *sql.Tx) error {
+		var err error
+		category, err = createCategory(tx, slug)
+		return err
+	})
+	return category, err
+}
+
+func (db *DB) SaveCategory(category *Category, author string) error {
+	return withAudit(db.DB, author, auditSaveCategory, categoryDetails(category.Slug), func(tx *sql.Tx) error {
+		return saveCategory(tx, category)
+	})
+}
+
+func (db *DB) DeleteCategory(categoryID int, author string) error {
+	return withAudit(db.DB, author, auditDeleteCategory, categoryIDDetails(categoryID), func(tx *sql.Tx) error {
+		return deleteCategory(tx, categoryID)
+	})
+}
+
+func (db *DB) MoveFAQ(faqID int, categoryID int, position int, author string) error {
+	return withAudit(db.DB, author, auditMoveFAQ, faqMoveDetails(faqID, categoryID, position), func(tx *sql.Tx) error {
+		return moveFAQ(tx, faqID, categoryID, position)
+	})
+}
+
+func (db *DB) MoveFAQs(categoryID int, faqIDs []int, author string) error {
+	return withAudit(db.DB, author, auditMoveFAQs, faqsMoveDetails(categoryID, faqIDs), func(tx *sql.Tx) error {
+		return moveFAQs(tx, categoryID, faqIDs)
+	})
+}
+
+func (db *DB) MoveCategories(categoryIDs []int, author string) error {
+	return withAudit(db.DB, author, auditMoveCategories, categoriesDetails(categoryIDs), func(tx *sql.Tx) error {
+		return moveCategories(tx, categoryIDs)
+	})
+}
+
+func (mdb *mockDB) AllCategories() ([]Category, error) {
+	names := []CategoryName{
+		CategoryName{Locale: Locale{Code: "en", NameLocal: "English"}, Name: "Billing"},
+		CategoryName{Locale: Locale{Code: "de", NameLocal: "Deutsch"}, Name: "Abrechnung"},
+	}
+	return []Category{Category{ID: 1, Slug: "billing", Position: 1, Names: names}}, nil
+}
+
+func (mdb *mockDB) CreateCategory(slug string, author string) (*Category, error) {
+	return &Category{ID: 1, Slug: slug, Position: 1}, nil
+}
+
+func (mdb *mockDB) SaveCategory(category *Category, author string) error {
+	return nil
+}
+
+func (mdb *mockDB) DeleteCategory(categoryID int, author string) error {
+	return nil
+}
+
+func (mdb *mockDB) MoveFAQ(faqID int, categoryID int, position int, author string) error {
+	return nil
+}
+
+func (mdb *mockDB) MoveFAQs(categoryID int, faqIDs []int, author string) error {
+	return nil
+}
+
+func (mdb *mockDB) MoveCategories(categoryIDs []int, author string) error {
+	return nil
+}
+
+func (mdb *brokenDB) AllCategories() ([]Category, error) {
+	return nil, errors.New(someDBError)
+}
+
+func (mdb *brokenDB) CreateCategory(slug string, author string) (*Category, error) {
+	return nil, errors.New(someDBError)
+}
+
+func (mdb *brokenDB) SaveCategory(category *Category, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) DeleteCategory(categoryID int, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) MoveFAQ(faqID int, categoryID int, position int, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) MoveFAQs(categoryID int, faqIDs []int, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) MoveCategories(categoryIDs []int, author string) error {
+	return errors.New(someDBError)
+}
+
+func getAllCategories(db *sql.DB) ([]Category, error) {
+	rows, err := db.Query("SELECT id, slug, position FROM categories ORDER BY position, id;")
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		c := Category{Names: []CategoryName{}}
+		err = rows.Scan(&c.ID, &c.Slug, &c.Position)
+		if err != nil {
+			logError(err)
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	nameRows, err := db.Query("SELECT category_id, locale, name FROM category_texts ORDER BY id;")
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer nameRows.Close()
+
+	for nameRows.Next() {
+		var categoryID int
+		var localeCode string
+		var name string
+		err = nameRows.Scan(&categoryID, &localeCode, &name)
+		if err != nil {
+			logError(err)
+			return nil, err
+		}
+		if c := categoryByID(categories, categoryID); c != nil {
+			c.Names = append(c.Names, CategoryName{Locale: localeFromCode(localeCode), Name: name})
+		}
+	}
+	if err = nameRows.Err(); err != nil {
+		return nil, err
+	}
+
+	return categories, nil
+}
+
+func createCategory(db dbtx, slug string) (*Category, error) {
+	sqlStatement := `
+		INSERT INTO categories (slug, position)
+		VALUES ($1, (SELECT COALESCE(MAX(position), 0) + 1 FROM categories))
+		RETURNING id, position;`
+	category := Category{Slug: slug, Names: []CategoryName{}}
+	err := db.QueryRow(sqlStatement, slug).Scan(&category.ID, &category.Position)
+	if isUniqueViolation(err) {
+		return nil, errCategoryExists
+	}
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	return &category, nil
+}
+
+// saveCategory updates slug and position of a category and upserts its
+// names. Names left empty are deleted, locales not listed stay untouched.
+func saveCategory(db dbtx, category *Category) error {
+	res, err := db.Exec("UPDATE categories SET slug = $1, position = $2 WHERE id = $3;",
+		category.Slug, category.Position, category.ID)
+	if isUniqueViolation(err) {
+		return errCategoryExists
+	}
+	if err != nil {
+		logError(err)
+		return err
+	}
+	if err = expectRowsAffected(res, errCategoryNotFound); err != nil {
+		return err
+	}
+
+	for _, n := range category.Names {
+		if len(n.Name) == 0 {
+			_, err = db.Exec("DELETE FROM category_texts WHERE category_id = $1 AND locale = $2;",
+				category.ID, n.Locale.Code)
+		} else {
+			_, err = db.Exec(`
+				INSERT INTO category_texts (category_id, locale, name)
+				VALUES ($1, $2, $3)
+				ON CONFLICT (category_id, locale)
+				  DO UPDATE SET name = EXCLUDED.name;`,
+				category.ID, n.Locale.Code, n.Name)
+		}
+		if err != nil {
+			logError(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteCategory deletes a category and its names. Its FAQs are left
+// without a category.
+func deleteCategory(db dbtx, categoryID int) error {
+	for _, sqlStatement := range []string{
+		`UPDATE faqs SET category_id = NULL WHERE category_id = $1;`,
+		`DELETE FROM category_texts WHERE category_id = $1;`,
+	} {
+		_, err := db.Exec(sqlStatement, categoryID)
+		if err != nil {
+			logError(err)
+			return err
+		}
+	}
+
+	res, err := db.Exec(`DELETE FROM categories WHERE id = $1;`, categoryID)
+	if err != nil {
+		logError(err)
+		return err
+	}
+	return expectRowsAffected(res, errCategoryNotFound)
+}
+
+func moveFAQ(db dbtx, faqID int, categoryID int, position int) error {
+	var category sql.NullInt64
+	if categoryID > 0 {
+		category = sql.NullInt64{Int64: int64(categoryID), Valid: true}
+	}
+	res, err := db.Exec(`UPDATE faqs SET category_id = $1, position = $2 WHERE id = $3;`,
+		category, position, faqID)
+	if isForeignKeyViolation(err) {
+		return errCategoryNotFound
+	}
+	if err != nil {
+		logError(err)
+		return err
+	}
+	return expectRowsAffected(res, errFAQNotFound)
+}
+
+// moveFAQs numbers the FAQs of a category in the order of faqIDs.
+func moveFAQs(db dbtx, categoryID int, faqIDs []int) error {
+	for i, faqID := range faqIDs {
+		if err := moveFAQ(db, faqID, categoryID, i+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveCategories numbers the categories in the order of categoryIDs.
+func moveCategories(db dbtx, categoryIDs []int) error {
+	for i, categoryID := range categoryIDs {
+		res, err := db.Exec(`UPDATE categories SET position = $1 WHERE id = $2;`, i+1, categoryID)
+		if err != nil {
+			logError(err)
+			return err
+		}
+		if err = expectRowsAffected(res, errCategoryNotFound); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+///// Category handlers
+
+func getCategories(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	localeCode, localized, err := requestedLocale(r)
+	if err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	categories, err := faqRepository.AllCategories()
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	setVaryPickedLanguage(w)
+	if !localized {
+		writeJSON(w, categories)
+		return
+	}
+	// Only the name served in the requested locale
+	served := []string{}
+	for i := range categories {
+		names := []CategoryName{}
+		if name, ok := categories[i].ResolvedName(localeCode); ok {
+			names = append(names, name)
+			served = append(served, name.Locale.Code)
+		}
+		categories[i].Names = names
+	}
+	setContentLanguage(w, served, localeCode)
+	writeJSON(w, categories)
+}
+
+type CategoriesPageData struct {
+	PageTitle  string
+	MenuBar    []MenuEntry
+	Categories []Category
+}
+
+type CategoryEditPageData struct {
+	PageTitle string
+	MenuBar   []MenuEntry
+	Category  Category
+	Names     []CategoryName
+}
+
+func getAdminCategories(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	categories, err := faqRepository.AllCategories()
+	if err != nil {
+		panic(err)
+	}
+	data := CategoriesPageData{
+		PageTitle:  "Admin / Categories",
+		MenuBar:    menuBar("Categories"),
+		Categories: categories,
+	}
+	mustExecuteTemplate(tmplAdminCategories, w, data)
+}
+
+func getAdminCategoriesEdit(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+
+	categories, err := faqRepository.AllCategories()
+	if err != nil {
+		panic(err)
+	}
+	category := categoryByID(categories, id)
+	if category == nil {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+
+	names := []CategoryName{}
+	for _, loc := range getSupportedLocales() {
+		n := CategoryName{Locale: loc}
+		for _, existing := range category.Names {
+			if existing.Locale.Code == loc.Code {
+				n.Name = existing.Name
+			}
+		}
+		names = append(names, n)
+	}
+
+	data := CategoryEditPageData{
+		PageTitle: "Admin / Edit Category",
+		MenuBar:   menuBar("Categories"),
+		Category:  *category,
+		Names:     names,
+	}
+	mustExecuteTemplate(tmplAdminCategoryEdit, w, data)
+}
+
+func postAdminCategoriesCreate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	slug := strings.TrimSpace(r.FormValue("slug"))
+	if !isValidSlug(slug) {
+		http.Error(w, "invalid slug", http.StatusBadRequest)
+		return
+	}
+
+	category, err := faqRepository.CreateCategory(slug, currentAuthor(r))
+	if err == errCategoryExists {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/admin/categories/edit/%d", category.ID)
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+func postAdminCategoriesUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.Atoi(r.FormValue("categoryID"))
+	if err != nil {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+
+	categories, err := faqRepository.AllCategories()
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	category := categoryByID(categories, id)
+	if category == nil {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+
+	category.Slug = strings.TrimSpace(r.FormValue("slug"))
+	if !isValidSlug(category.Slug) {
+		http.Error(w, "invalid slug", http.StatusBadRequest)
+		return
+	}
+	category.Names = []CategoryName{}
+	for _, loc := range getSupportedLocales() {
+		name := strings.TrimSpace(r.FormValue("name_" + loc.Code))
+		category.Names = append(category.Names, CategoryName{Locale: loc, Name: name})
+	}
+
+	err = faqRepository.SaveCategory(category, currentAuthor(r))
+	if err == errCategoryExists {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/admin/categories/edit/%d", category.ID)
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+func postAdminCategoriesDelete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.Atoi(r.FormValue("categoryID"))
+	if err != nil {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+
+	err = faqRepository.DeleteCategory(id, currentAuthor(r))
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/categories", http.StatusFound)
+}
+
+func postAdminCategoriesMove(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	id, err := strconv.Atoi(r.FormValue("categoryID"))
+	if err != nil {
+		http.Error(w, "category not found", http.StatusNotFound)
+		return
+	}
+
+	categories, err := faqRepository.AllCategories()
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	ids := []int{}
+	for _, c := range categories {
+		ids = append(ids, c.ID)
+	}
+	ids, _ = moveID(ids, id, r.FormValue("direction"))
+
+	// Renumber all categories so that positions stay dense and unique.
+	if err = faqRepository.MoveCategories(ids, currentAuthor(r)); err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/categories", http.StatusFound)
+}
+
+func postAdminFAQsMove(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, err := strconv.Atoi(r.FormValue("faqID"))
+	if err != nil {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+
+	faqs, err := faqRepository.AllFAQs()
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	var faq *FAQ
+	for i := range faqs {
+		if faqs[i].ID == faqID {
+			faq = &faqs[i]
+		}
+	}
+	if faq == nil {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+
+	siblings := faqsInCategory(faqs, faq.CategoryID)
+	ids := []int{}
+	for _, f := range siblings {
+		ids = append(ids, f.ID)
+	}
+	ids, _ = moveID(ids, faqID, r.FormValue("direction"))
+
+	// Renumber the whole category so that positions stay dense and unique.
+	if err = faqRepository.MoveFAQs(faq.CategoryID, ids, currentAuthor(r)); err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/faqs", http.StatusFound)
+}
+
+func postAdminFAQsCategory(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, err := strconv.Atoi(r.FormValue("faqID"))
+	if err != nil {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+	categoryID, _ := strconv.Atoi(r.FormValue("categoryID"))
+
+	faqs, err := faqRepository.AllFAQs()
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	// Append the FAQ to the end of its new category.
+	position := 1
+	for _, f := range faqsInCategory(faqs, categoryID) {
+		if f.ID != faqID && f.Position >= position {
+			position = f.Position + 1
+		}
+	}
+
+	err = faqRepository.MoveFAQ(faqID, categoryID, position, currentAuthor(r))
+	if err == errCategoryNotFound {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/admin/faqs/edit/%d", faqID)
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
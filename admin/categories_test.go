@@ -0,0 +1,271 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// categorizedDB files FAQ 456 under the "billing" category of mockDB.
+type categorizedDB struct {
+	mockDB
+}
+
+func (cdb *categorizedDB) AllFAQs() ([]FAQ, error) {
+	faqs, _ := cdb.mockDB.AllFAQs()
+	faqs[1].CategoryID = 1
+	faqs[1].Position = 1
+	return faqs, nil
+}
+
+func (cdb *categorizedDB) ListFAQs(opts ListOptions) ([]FAQ, int, error) {
+	faqs, _ := cdb.AllFAQs()
+	page, total := listFAQsFrom(faqs, opts)
+	return page, total, nil
+}
+
+func formHeader() *http.Header {
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return &header
+}
+
+func TestGetAPICategories(t *testing.T) {
+	faqRepository = &mockDB{}
+	resp := doRequest("GET", "/api/categories", emptyBody())
+
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Type", "application/json")
+	expectBodyContains(t, resp, `[{"id":1,"slug":"billing","position":1,"names":[{"locale":{"code":"en","name_local":"English"},"name":"Billing"},{"locale":{"code":"de","name_local":"Deutsch"},"name":"Abrechnung"}]}]`)
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("GET", "/api/categories", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestGetAPIFAQsByCategory(t *testing.T) {
+	faqRepository = &categorizedDB{}
+
+	resp := doRequest("GET", "/api/faqs?category=billing", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"id":456,"texts":null,"category_id":1,"position":1}]`)
+
+	resp = doRequest("GET", "/api/faqs?category=shipping", emptyBody())
+	expectErrorJSON(t, resp, 404, "category not found")
+}
+
+func TestPostAPIFAQWithCategory(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("POST", "/api/faqs", body(`{"category_id":1,"position":3,"texts":[{"locale":{"code":"en"},"question":"q","answer":"a"}]}`), jsonHeader())
+	expectStatus(t, resp, 201)
+	expectBodyContains(t, resp, `"category_id":1,"position":3}`)
+
+	resp = doRequestWithHeader("POST", "/api/faqs", body(`{"category_id":2,"texts":[{"locale":{"code":"en"},"question":"q","answer":"a"}]}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "category not found")
+}
+
+func TestGetAdminFAQsGroupedByCategory(t *testing.T) {
+	faqRepository = &categorizedDB{}
+	resp := doRequest("GET", "/admin/faqs", emptyBody())
+
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<h3>Billing</h3>`)
+	expectBodyContains(t, resp, `<h3>Uncategorized</h3>`)
+	expectBodyContains(t, resp, `<form action="/admin/faqs/move" method="post" class="d-inline">`)
+}
+
+func TestGetAdminCategories(t *testing.T) {
+	faqRepository = &mockDB{}
+	resp := doRequest("GET", "/admin/categories", emptyBody())
+
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<title>Admin / Categories</title>`)
+	expectBodyContains(t, resp, `<td>billing</td>`)
+	expectBodyContains(t, resp, `<td>Billing</td>`)
+	expectBodyContains(t, resp, `href="/admin/categories/edit/1"`)
+}
+
+func TestGetAdminCategoriesEdit(t *testing.T) {
+	faqRepository = &mockDB{}
+	resp := doRequest("GET", "/admin/categories/edit/1", emptyBody())
+
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<title>Admin / Edit Category</title>`)
+	expectBodyContains(t, resp, `name="name_de" value="Abrechnung"`)
+	expectBodyContains(t, resp, `name="name_fr" value=""`)
+
+	resp = doRequest("GET", "/admin/categories/edit/2", emptyBody())
+	expectStatus(t, resp, 404)
+}
+
+func TestGetAdminFAQsEditWithCategories(t *testing.T) {
+	faqRepository = &mockDB{}
+	resp := doRequest("GET", "/admin/faqs/edit/123", emptyBody())
+
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<form action="/admin/faqs/category" method="post" class="form-inline mb-4">`)
+	expectBodyContains(t, resp, `<option value="1">Billing</option>`)
+}
+
+func TestPostAdminCategoriesCreate(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("POST", "/admin/categories/create", body("slug=billing"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/categories/edit/1")
+
+	resp = doRequestWithHeader("POST", "/admin/categories/create", body("slug=Not+A+Slug"), formHeader())
+	expectStatus(t, resp, 400)
+}
+
+func TestPostAdminCategoriesUpdate(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("POST", "/admin/categories/update", body("categoryID=1&slug=payments&name_en=Payments"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/categories/edit/1")
+
+	resp = doRequestWithHeader("POST", "/admin/categories/update", body("categoryID=7&slug=payments"), formHeader())
+	expectStatus(t, resp, 404)
+}
+
+func TestPostAdminCategoriesDeleteAndMove(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("POST", "/admin/categories/delete", body("categoryID=1"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/categories")
+
+	resp = doRequestWithHeader("POST", "/admin/categories/move", body("categoryID=1&direction=up"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/categories")
+}
+
+func TestPostAdminFAQsMoveAndCategory(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("POST", "/admin/faqs/move", body("faqID=456&direction=down"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/faqs")
+
+	resp = doRequestWithHeader("POST", "/admin/faqs/move", body("faqID=999&direction=down"), formHeader())
+	expectStatus(t, resp, 404)
+
+	resp = doRequestWithHeader("POST", "/admin/faqs/category", body("faqID=123&categoryID=1"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/faqs/edit/123")
+}
+
+func TestMoveID(t *testing.T) {
+	tests := []struct {
+		id        int
+		direction string
+		expected  []int
+		moved     bool
+	}{
+		{id: 2, direction: "up", expected: []int{2, 1, 3}, moved: true},
+		{id: 2, direction: "down", expected: []int{1, 3, 2}, moved: true},
+		{id: 1, direction: "up", expected: []int{1, 2, 3}, moved: false},
+		{id: 3, direction: "down", expected: []int{1, 2, 3}, moved: false},
+		{id: 4, direction: "up", expected: []int{1, 2, 3}, moved: false},
+	}
+
+	for _, test := range tests {
+		ids, moved := moveID([]int{1, 2, 3}, test.id, test.direction)
+		if !reflect.DeepEqual(test.expected, ids) || moved != test.moved {
+			t.Errorf("moveID(%v, %v): got %v/%v, wanted %v/%v", test.id, test.direction, ids, moved, test.expected, test.moved)
+		}
+	}
+}
+
+func TestCategoryNameForLocale(t *testing.T) {
+	c := Category{Slug: "billing", Names: []CategoryName{
+		CategoryName{Locale: Locale{Code: "en"}, Name: "Billing"},
+		CategoryName{Locale: Locale{Code: "de"}, Name: "Abrechnung"},
+	}}
+	expectSameString(t, "Abrechnung", c.NameForLocale("de"))
+	expectSameString(t, "Billing", c.NameForLocale("fr"))
+
+	c.Names = nil
+	expectSameString(t, "billing", c.NameForLocale("fr"))
+}
+
+func TestCategoriesInDB(t *testing.T) {
+	repo := prepareDB()
+
+	c, err := repo.CreateCategory("billing", testAuthor)
+	expectNoError(t, err)
+	expectHasID(t, c.ID)
+
+	_, err = repo.CreateCategory("billing", testAuthor)
+	expectSameError(t, errCategoryExists, err)
+
+	c.Names = []CategoryName{CategoryName{Locale: Locale{Code: "de"}, Name: "Abrechnung"}}
+	err = repo.SaveCategory(c, testAuthor)
+	expectNoError(t, err)
+
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+	err = repo.MoveFAQ(f.ID, c.ID, 2, testAuthor)
+	expectNoError(t, err)
+
+	err = repo.MoveFAQ(f.ID, c.ID+1000, 2, testAuthor)
+	expectSameError(t, errCategoryNotFound, err)
+
+	f2, err := repo.FAQById(f.ID)
+	expectNoError(t, err)
+	expectSameInt(t, c.ID, f2.CategoryID)
+	expectSameInt(t, 2, f2.Position)
+
+	categories, err := repo.AllCategories()
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(categories))
+	expectSameString(t, "Abrechnung", categories[0].NameForLocale("de"))
+
+	err = repo.DeleteCategory(c.ID, testAuthor)
+	expectNoError(t, err)
+
+	f2, err = repo.FAQById(f.ID)
+	expectNoError(t, err)
+	expectSameInt(t, 0, f2.CategoryID)
+}
+
+func testMoveFAQsAndCategories(t *testing.T, repo FAQRepository) {
+	billing, err := repo.CreateCategory("billing", testAuthor)
+	expectNoError(t, err)
+	shipping, err := repo.CreateCategory("shipping", testAuthor)
+	expectNoError(t, err)
+	f1, err := repo.CreateFAQ()
+	expectNoError(t, err)
+	f2, err := repo.CreateFAQ()
+	expectNoError(t, err)
+
+	expectNoError(t, repo.MoveFAQs(billing.ID, []int{f2.ID, f1.ID}, testAuthor))
+	stored, err := repo.FAQById(f1.ID)
+	expectNoError(t, err)
+	expectSameInt(t, billing.ID, stored.CategoryID)
+	expectSameInt(t, 2, stored.Position)
+
+	// Nothing is moved if one of the FAQs is missing
+	expectSameError(t, errFAQNotFound, repo.MoveFAQs(billing.ID, []int{f1.ID, f2.ID, f2.ID + 1000}, testAuthor))
+	stored, err = repo.FAQById(f1.ID)
+	expectNoError(t, err)
+	expectSameInt(t, 2, stored.Position)
+
+	expectNoError(t, repo.MoveCategories([]int{shipping.ID, billing.ID}, testAuthor))
+	expectSameError(t, errCategoryNotFound, repo.MoveCategories([]int{billing.ID, shipping.ID + 1000}, testAuthor))
+	categories, err := repo.AllCategories()
+	expectNoError(t, err)
+	expectSameString(t, "shipping", categories[0].Slug)
+	expectSameInt(t, 1, categories[0].Position)
+	expectSameInt(t, 2, categories[1].Position)
+}
+
+func TestMoveFAQsAndCategories(t *testing.T) {
+	testMoveFAQsAndCategories(t, NewMemoryDB())
+}
+
+func TestMoveFAQsAndCategoriesInDB(t *testing.T) {
+	testMoveFAQsAndCategories(t, prepareDB())
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// CSV files hold the translations of all locales for spreadsheets. Each FAQ
+// has a question and an answer row, with the unit ID and field in the first
+// columns followed by one column per supported locale.
+const csvContentType = "text/csv; charset=utf-8"
+
+// utf8BOM lets spreadsheet applications recognize the encoding.
+const utf8BOM = "\ufeff"
+
+// translationRows returns the rows of a CSV file for all FAQs with a text in
+// the default locale.
+func translationRows(repo FAQRepository) ([][]string, error) {
+	faqs, err := repo.AllFAQs()
+	if err != nil {
+		return nil, err
+	}
+
+	header := []string{"id", "field"}
+	for _, l := range getSupportedLocales() {
+		header = append(header, l.Code)
+	}
+	rows := [][]string{header}
+	for _, faq := range faqs {
+		if len(faq.TextForLocale(getDefaultLocale().Code).Question) == 0 {
+			continue
+		}
+		question := []string{unitID(faq.ID), "question"}
+		answer := []string{unitID(faq.ID), "answer"}
+		for _, l := range getSupportedLocales() {
+			text := faq.TextForLocale(l.Code)
+			question = append(question, text.Question)
+			answer = append(answer, text.Answer)
+		}
+		rows = append(rows, question, answer)
+	}
+	return rows, nil
+}
+
+func encodeCSV(w io.Writer, rows [][]string) error {
+	_, err := io.WriteString(w, utf8BOM)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(w)
+	err = cw.WriteAll(rows)
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// readCSV decodes a translated CSV file into the units of each locale
+// besides the default locale, whose column holds the source texts.
+func readCSV(r io.Reader) ([]translationSet, error) {
+	br := bufio.NewReader(r)
+	if bom, err := br.Peek(len(utf8BOM)); err == nil && string(bom) == utf8BOM {
+		br.Discard(len(utf8BOM))
+	}
+	records, err := csv.NewReader(br).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %v", err)
+	}
+	if len(records) == 0 || len(records[0]) < 2 || records[0][0] != "id" || records[0][1] != "field" {
+		return nil, errors.New("invalid CSV: the first columns must be id and field")
+	}
+
+	header := records[0]
+	source := -1
+	seen := make(map[string]bool)
+	for i, code := range header[2:] {
+		if seen[code] {
+			return nil, fmt.Errorf("duplicate locale: %v", code)
+		}
+		seen[code] = true
+		if code == getDefaultLocale().Code {
+			source = i + 2
+		} else if !isSupportedLocale(code) {
+			return nil, fmt.Errorf("unsupported locale: %v", code)
+		}
+	}
+	if source < 0 {
+		return nil, fmt.Errorf("missing column for the default locale: %v", getDefaultLocale().Code)
+	}
+
+	builders := make([]unitBuilder, len(header))
+	for _, record := range records[1:] {
+		for i := 2; i < len(header); i++ {
+			if i != source {
+				builders[i].set(record[0], record[1], record[source], record[i])
+			}
+		}
+	}
+	sets := []translationSet{}
+	for i := 2; i < len(header); i++ {
+		if i != source {
+			sets = append(sets, translationSet{Locale: header[i], Units: builders[i].units})
+		}
+	}
+	return sets, nil
+}
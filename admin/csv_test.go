@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	csv := "\ufeffid,field,de,en,fr\n" +
+		"faq-2,question,Wie bezahle ich?,How do I pay?,\n" +
+		"faq-2,answer,\"Per Karte,\nbitte.\",By card.,\n"
+	sets, err := readCSV(strings.NewReader(csv))
+	expectNoError(t, err)
+	expectSameInt(t, 2, len(sets))
+	expectSameString(t, "de", sets[0].Locale)
+	expectSameString(t, "fr", sets[1].Locale)
+	unit := sets[0].Units[0]
+	expectSameString(t, "faq-2", unit.ID)
+	expectSameString(t, "How do I pay?", unit.SourceQuestion)
+	expectSameString(t, "By card.", unit.SourceAnswer)
+	expectSameString(t, "Wie bezahle ich?", unit.Question)
+	expectSameString(t, "Per Karte,\nbitte.", unit.Answer)
+	expectSameString(t, "", sets[1].Units[0].Question)
+
+	tests := []struct {
+		csv string
+		err string
+	}{
+		{"", "invalid CSV: the first columns must be id and field"},
+		{"faq,field,en\n", "invalid CSV: the first columns must be id and field"},
+		{"id,field,en,de\nfaq-2,question,q\n", "invalid CSV: record on line 2: wrong number of fields"},
+		{"id,field,de\n", "missing column for the default locale: en"},
+		{"id,field,en,xx\n", "unsupported locale: xx"},
+		{"id,field,en,de,de\n", "duplicate locale: de"},
+	}
+	for _, tt := range tests {
+		_, err := readCSV(strings.NewReader(tt.csv))
+		if err == nil {
+			t.Errorf("expected error %q for %s", tt.err, tt.csv)
+			continue
+		}
+		expectSameString(t, tt.err, err.Error())
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+	authenticateFunc = alwaysAdminFunc
+
+	resp := doRequest("GET", "/admin/translations.csv", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeaderMatches(t, resp, "Content-Type", `^text/csv; charset=utf-8$`)
+	expectHeaderMatches(t, resp, "Content-Disposition", `^attachment; filename="faqs\.csv"$`)
+	csv := resp.Body.String()
+	expectIsTrue(t, strings.HasPrefix(csv, "\ufeffid,field,en,de,fr,"))
+	expectBodyContains(t, resp, "faq-2,question,How do I pay?,Wie bezahle ich?,,")
+	expectBodyContains(t, resp, "faq-5,answer,By mail.,,,")
+
+	csv = strings.Replace(csv, "faq-2,question,How do I pay?,Wie bezahle ich?,,", "faq-2,question,How do I pay?,Wie zahle ich?,Comment payer ?,", 1)
+	csv = strings.Replace(csv, "faq-2,answer,By card.,Per Karte.,,", "faq-2,answer,By card.,Per Karte.,Par carte.,", 1)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, err := mw.CreateFormFile("file", "faqs.csv")
+	expectNoError(t, err)
+	fw.Write([]byte(csv))
+	mw.Close()
+	header := http.Header{}
+	header.Set("Content-Type", mw.FormDataContentType())
+
+	resp = doRequestWithHeader("POST", "/admin/translations/import", &form, &header)
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, "1 translations into de saved, 1 skipped, 0 conflicts.")
+	expectBodyContains(t, resp, "1 translations into fr saved, 1 skipped, 0 conflicts.")
+	faq, _ := faqRepository.FAQById(2)
+	expectSameString(t, "Wie zahle ich?", faq.TextForLocale("de").Question)
+	expectSameString(t, "Par carte.", faq.TextForLocale("fr").Answer)
+
+	resp = doRequest("POST", "/api/translations/csv", body(csv))
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"locale":"de","saved":[],"skipped":[{"unit":"faq-2","reason":"unchanged"},{"unit":"faq-5","reason":"not translated"}],"conflicts":[]},`)
+
+	resp = doRequest("GET", "/api/translations.csv", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, "faq-2,answer,By card.,Per Karte.,Par carte.,")
+}
+
+// failingLocaleDB fails to save texts in one locale.
+type failingLocaleDB struct {
+	*MemoryDB
+	localeCode string
+}
+
+func (db *failingLocaleDB) SaveFAQText(faqID int, text *FAQText) error {
+	if text.Locale.Code == db.localeCode {
+		return errors.New(someDBError)
+	}
+	return db.MemoryDB.SaveFAQText(faqID, text)
+}
+
+func TestCSVImportReportsSavedLocalesOnError(t *testing.T) {
+	csv := "id,field,en,de,fr\n" +
+		"faq-2,question,How do I pay?,Wie zahle ich?,Comment payer ?\n" +
+		"faq-2,answer,By card.,Per Karte.,Par carte.\n"
+
+	faqRepository = &failingLocaleDB{MemoryDB: seedTransferDB(t), localeCode: "fr"}
+	resp := doRequest("POST", "/api/translations/csv", body(csv))
+	expectStatus(t, resp, 500)
+	expectBodyContains(t, resp, `{"error":"internal error","reports":[{"locale":"de","saved":[2],"skipped":[],"conflicts":[]},{"locale":"fr","saved":[],`)
+	faq, _ := faqRepository.FAQById(2)
+	expectSameString(t, "Wie zahle ich?", faq.TextForLocale("de").Question)
+
+	faqRepository = &failingLocaleDB{MemoryDB: seedTransferDB(t), localeCode: "fr"}
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, err := mw.CreateFormFile("file", "faqs.csv")
+	expectNoError(t, err)
+	fw.Write([]byte(csv))
+	mw.Close()
+	header := http.Header{}
+	header.Set("Content-Type", mw.FormDataContentType())
+
+	resp = doRequestWithHeader("POST", "/admin/translations/import", &form, &header)
+	expectStatus(t, resp, 500)
+	expectBodyContains(t, resp, "The import stopped because of an internal error.")
+	expectBodyContains(t, resp, "1 translations into de saved, 0 skipped, 0 conflicts.")
+	expectBodyContains(t, resp, "0 translations into fr saved, 0 skipped, 0 conflicts.")
+}
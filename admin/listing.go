@@ -0,0 +1,315 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+const (
+	defaultPageSize = 100
+	maxPageSize     = 500
+)
+
+// faqSorts maps the sort options of the list endpoint to SQL orderings.
+// "position" is the order of the admin UI: by category, then position.
+var faqSorts = map[string]string{
+	"position": "categories.position NULLS LAST, categories.id NULLS LAST, faqs.position, faqs.id",
+	"id":       "faqs.id",
+	"-id":      "faqs.id DESC",
+}
+
+// faqFields are the names accepted by the fields parameter.
+var faqFields = map[string]bool{
+	"id":          true,
+	"category_id": true,
+	"position":    true,
+	"question":    true,
+	"answer":      true,
+}
+
+// ListOptions selects a page of FAQs. A zero Limit lists all FAQs, a zero
+// CategoryID lists all categories.
+type ListOptions struct {
+	Limit      int
+	Offset     int
+	Sort       string
+	CategoryID int
+
+	// Published lists only FAQs with a published text in one of Locales,
+	// or in any locale if Locales is empty.
+	Published bool
+	Locales   []string
+}
+
+// lists reports whether faq is selected by opts. FAQs without loaded texts
+// are kept, as with Published.
+func (opts ListOptions) lists(faq FAQ) bool {
+	if opts.CategoryID != 0 && faq.CategoryID != opts.CategoryID {
+		return false
+	}
+	if !opts.Published || faq.Texts == nil {
+		return true
+	}
+	for _, t := range faq.Texts {
+		if !t.IsPublished() {
+			continue
+		}
+		if len(opts.Locales) == 0 || containsString(opts.Locales, t.Locale.Code) && len(t.PublishedQuestion) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// parseListOptions reads the list params of a request. Requests without
+// limit and offset are not paged.
+func parseListOptions(r *http.Request) (ListOptions, error) {
+	opts := ListOptions{Sort: "position"}
+
+	if limit := r.FormValue("limit"); len(limit) > 0 {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageSize {
+			return opts, fmt.Errorf("invalid limit: %v", limit)
+		}
+		opts.Limit = n
+	}
+	if offset := r.FormValue("offset"); len(offset) > 0 {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid offset: %v", offset)
+		}
+		opts.Offset = n
+		if opts.Limit == 0 {
+			opts.Limit = defaultPageSize
+		}
+	}
+	if s := r.FormValue("sort"); len(s) > 0 {
+		if _, ok := faqSorts[s]; !ok {
+			return opts, fmt.Errorf("unsupported sort: %v", s)
+		}
+		opts.Sort = s
+	}
+	return opts, nil
+}
+
+func parseFields(r *http.Request) (map[string]bool, error) {
+	param := strings.TrimSpace(r.FormValue("fields"))
+	if len(param) == 0 {
+		return nil, nil
+	}
+	fields := make(map[string]bool)
+	for _, field := range strings.Split(param, ",") {
+		field = strings.TrimSpace(field)
+		if !faqFields[field] {
+			return nil, fmt.Errorf("unknown field: %v", field)
+		}
+		fields[field] = true
+	}
+	return fields, nil
+}
+
+// selectFields returns the JSON representation of faq reduced to fields.
+func selectFields(faq FAQ, fields map[string]bool) map[string]interface{} {
+	m := make(map[string]interface{})
+	if fields["id"] {
+		m["id"] = faq.ID
+	}
+	if fields["category_id"] {
+		m["category_id"] = faq.CategoryID
+	}
+	if fields["position"] {
+		m["position"] = faq.Position
+	}
+	if fields["question"] || fields["answer"] {
+		texts := []map[string]interface{}{}
+		for _, text := range faq.Texts {
+			t := map[string]interface{}{"locale": text.Locale}
+			if fields["question"] {
+				t["question"] = text.Question
+			}
+			if fields["answer"] {
+				t["answer"] = text.Answer
+			}
+			texts = append(texts, t)
+		}
+		m["texts"] = texts
+	}
+	return m
+}
+
+// onlyLocale returns faq with just the text served for the given locale,
+// which may be in a fallback locale.
+func onlyLocale(faq FAQ, localeCode string) FAQ {
+	if faq.Texts == nil {
+		return faq
+	}
+	texts := []FAQText{}
+	if text := faq.ResolvedText(localeCode); len(text.Question) > 0 {
+		texts = append(texts, text)
+	}
+	faq.Texts = texts
+	return faq
+}
+
+// setPaginationHeaders sets X-Total-Count and, for paged requests, a Link
+// header with first, prev, next and last pages relative to the request URL.
+func setPaginationHeaders(w http.ResponseWriter, r *http.Request, limit int, offset int, total int) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if limit == 0 {
+		return
+	}
+
+	pageURL := func(offset int) string {
+		q := r.URL.Query()
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("offset", strconv.Itoa(offset))
+		u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		return u.String()
+	}
+
+	lastOffset := 0
+	if total > 0 {
+		lastOffset = (total - 1) / limit * limit
+	}
+	links := []string{fmt.Sprintf(`<%v>; rel="first"`, pageURL(0))}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links = append(links, fmt.Sprintf(`<%v>; rel="prev"`, pageURL(prev)))
+	}
+	if offset+limit < total {
+		links = append(links, fmt.Sprintf(`<%v>; rel="next"`, pageURL(offset+limit)))
+	}
+	links = append(links, fmt.Sprintf(`<%v>; rel="last"`, pageURL(lastOffset)))
+	w.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// listFAQsFrom applies opts to faqs, which must be in "position" order. It
+// returns the page and the number of FAQs before paging.
+func listFAQsFrom(faqs []FAQ, opts ListOptions) ([]FAQ, int) {
+	filtered := []FAQ{}
+	for _, faq := range faqs {
+		if opts.lists(faq) {
+			filtered = append(filtered, faq)
+		}
+	}
+	switch opts.Sort {
+	case "id":
+		sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].ID < filtered[j].ID })
+	case "-id":
+		sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].ID > filtered[j].ID })
+	}
+
+	total := len(filtered)
+	start, end := pageBounds(total, opts.Limit, opts.Offset)
+	return filtered[start:end], total
+}
+
+func pageBounds(total int, limit int, offset int) (int, int) {
+	start := offset
+	if start > total {
+		start = total
+	}
+	end := start + limit
+	if limit == 0 || end > total {
+		end = total
+	}
+	return start, end
+}
+
+///// Listing persistence
+
+func (db *DB) ListFAQs(opts ListOptions) ([]FAQ, int, error) {
+	return listFAQs(db.DB, opts)
+}
+
+func (mdb *mockDB) ListFAQs(opts ListOptions) ([]FAQ, int, error) {
+	faqs, _ := mdb.AllFAQs()
+	page, total := listFAQsFrom(faqs, opts)
+	return page, total, nil
+}
+
+func (mdb *brokenDB) ListFAQs(opts ListOptions) ([]FAQ, int, error) {
+	return nil, 0, errors.New(someDBError)
+}
+
+// listFAQsWhere selects the FAQs of ListOptions by category ($1), whether
+// published ($2) and locales ($3).
+const listFAQsWhere = `
+		WHERE faqs.deleted_at IS NULL
+		AND ($1 = 0 OR faqs.category_id = $1)
+		AND (NOT $2 OR EXISTS (
+		  SELECT 1 FROM faq_texts
+		  WHERE faq_texts.faq_id = faqs.id AND faq_texts.published_at IS NOT NULL
+		  AND (cardinality($3::text[]) = 0 OR faq_texts.locale = ANY($3) AND faq_texts.published_question <> '')))`
+
+func listFAQs(db *sql.DB, opts ListOptions) ([]FAQ, int, error) {
+	locales := pq.Array(append([]string{}, opts.Locales...))
+	var total int
+	err := db.QueryRow(`
+		SELECT count(*) FROM faqs`+listFAQsWhere+`;`,
+		opts.CategoryID, opts.Published, locales).Scan(&total)
+	if err != nil {
+		logError(err)
+		return nil, 0, err
+	}
+
+	orderBy, ok := faqSorts[opts.Sort]
+	if !ok {
+		orderBy = faqSorts["position"]
+	}
+	rows, err := db.Query(`
+		SELECT faqs.id, faqs.category_id, faqs.position
+		FROM faqs
+		LEFT JOIN categories ON categories.id = faqs.category_id`+listFAQsWhere+`
+		ORDER BY `+orderBy+`
+		LIMIT NULLIF($4, 0) OFFSET $5;`, opts.CategoryID, opts.Published, locales, opts.Limit, opts.Offset)
+	if err != nil {
+		logError(err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	faqs := []FAQ{}
+	for rows.Next() {
+		var id int
+		var categoryID sql.NullInt64
+		var position int
+		err = rows.Scan(&id, &categoryID, &position)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		faqs = append(faqs, FAQ{ID: id, CategoryID: int(categoryID.Int64), Position: position})
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = addTexts(db, faqs)
+	if err != nil {
+		return nil, 0, err
+	}
+	return faqs, total, nil
+}
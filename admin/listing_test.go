@@ -0,0 +1,187 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestGetAPIFAQsPagination(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("GET", "/api/faqs?limit=1&offset=1", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"id":456,"texts":null}]`)
+	expectHeader(t, resp, "X-Total-Count", "3")
+	expectHeader(t, resp, "Link", `</api/faqs?limit=1&offset=0>; rel="first", </api/faqs?limit=1&offset=0>; rel="prev", </api/faqs?limit=1&offset=2>; rel="next", </api/faqs?limit=1&offset=2>; rel="last"`)
+
+	resp = doRequest("GET", "/api/faqs?limit=2&offset=5", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[]`)
+
+	resp = doRequest("GET", "/api/faqs?limit=0", emptyBody())
+	expectErrorJSON(t, resp, 400, "invalid limit: 0")
+
+	resp = doRequest("GET", "/api/faqs?offset=-1", emptyBody())
+	expectErrorJSON(t, resp, 400, "invalid offset: -1")
+}
+
+func TestGetAPIFAQsSort(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("GET", "/api/faqs?sort=-id&fields=id", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"id":789},{"id":456},{"id":123}]`)
+
+	resp = doRequest("GET", "/api/faqs?sort=question", emptyBody())
+	expectErrorJSON(t, resp, 400, "unsupported sort: question")
+}
+
+func TestGetAPIFAQsLocaleAndFields(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("GET", "/api/faqs?locale=de&limit=1", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"id":123,"texts":[{"locale":{"code":"de","name_local":"Deutsch"},"question":"Frage?","answer":"Antwort!"}]}]`)
+
+	resp = doRequest("GET", "/api/faqs?locale=de&limit=1&fields=id,question", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"id":123,"texts":[{"locale":{"code":"de","name_local":"Deutsch"},"question":"Frage?"}]}]`)
+
+	resp = doRequest("GET", "/api/faqs?locale=xx", emptyBody())
+	expectErrorJSON(t, resp, 400, "unsupported locale: xx")
+
+	resp = doRequest("GET", "/api/faqs?fields=id,body", emptyBody())
+	expectErrorJSON(t, resp, 400, "unknown field: body")
+}
+
+func TestGetAPISearchFAQsPagination(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("GET", "/api/search-faqs?lang=en&query=bar&offset=1", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"results":[],"fuzzy":false}`)
+	expectHeader(t, resp, "X-Total-Count", "1")
+}
+
+func TestListFAQsInDB(t *testing.T) {
+	repo := prepareDB()
+
+	for i := 0; i < 3; i++ {
+		_, err := repo.CreateFAQ()
+		expectNoError(t, err)
+	}
+
+	faqs, total, err := repo.ListFAQs(ListOptions{Limit: 2, Sort: "-id"})
+	expectNoError(t, err)
+	expectSameInt(t, 3, total)
+	expectSameInt(t, 2, len(faqs))
+	expectIsTrue(t, faqs[0].ID > faqs[1].ID)
+
+	faqs, total, err = repo.ListFAQs(ListOptions{Limit: 2, Offset: 2, Sort: "id"})
+	expectNoError(t, err)
+	expectSameInt(t, 3, total)
+	expectSameInt(t, 1, len(faqs))
+
+	faqs, total, err = repo.ListFAQs(ListOptions{Sort: "id"})
+	expectNoError(t, err)
+	expectSameInt(t, 3, total)
+	expectSameInt(t, 3, len(faqs))
+
+	testListPublishedFAQs(t, repo, faqs[1].ID, faqs[2].ID)
+}
+
+// testListPublishedFAQs publishes an English text of the first FAQ and a
+// German text of the second, and expects only them to be listed and counted.
+func testListPublishedFAQs(t *testing.T, repo FAQRepository, first int, second int) {
+	en := FAQText{Locale: Locale{Code: "en"}, Question: "question?", Answer: "answer!"}
+	expectNoError(t, repo.SaveFAQText(first, &en))
+	expectNoError(t, repo.PublishFAQText(first, "en", testAuthor))
+	de := FAQText{Locale: Locale{Code: "de"}, Question: "Frage?", Answer: "Antwort!"}
+	expectNoError(t, repo.SaveFAQText(second, &de))
+	expectNoError(t, repo.PublishFAQText(second, "de", testAuthor))
+
+	faqs, total, err := repo.ListFAQs(ListOptions{Limit: 1, Sort: "id", Published: true})
+	expectNoError(t, err)
+	expectSameInt(t, 2, total)
+	expectSameInt(t, first, faqs[0].ID)
+
+	faqs, total, err = repo.ListFAQs(ListOptions{Sort: "id", Published: true, Locales: []string{"de"}})
+	expectNoError(t, err)
+	expectSameInt(t, 1, total)
+	expectSameInt(t, second, faqs[0].ID)
+
+	faqs, total, err = repo.ListFAQs(ListOptions{Sort: "id", Published: true, Locales: []string{"fr"}})
+	expectNoError(t, err)
+	expectSameInt(t, 0, total)
+	expectSameInt(t, 0, len(faqs))
+}
+
+func TestListPublishedFAQs(t *testing.T) {
+	repo := NewMemoryDB()
+	ids := []int{}
+	for i := 0; i < 3; i++ {
+		f, err := repo.CreateFAQ()
+		expectNoError(t, err)
+		ids = append(ids, f.ID)
+	}
+	testListPublishedFAQs(t, repo, ids[1], ids[2])
+}
+
+func TestGetAPIFAQsCountsServedFAQs(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+
+	resp := doRequest("GET", "/api/faqs?fields=id", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"id":2}]`)
+	expectHeader(t, resp, "X-Total-Count", "1")
+
+	resp = doRequest("GET", "/api/faqs?locale=de&fields=id", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"id":2}]`)
+	expectHeader(t, resp, "X-Total-Count", "1")
+}
+
+func TestGetAPIFAQsUnpaged(t *testing.T) {
+	repo := NewMemoryDB()
+	faqRepository = repo
+	for i := 0; i < defaultPageSize+20; i++ {
+		f := FAQ{Texts: []FAQText{FAQText{Locale: Locale{Code: "en"}, Question: "question?", Answer: "answer!"}}}
+		expectNoError(t, repo.CreateFAQWithTexts(&f))
+		expectNoError(t, repo.PublishFAQText(f.ID, "en", testAuthor))
+	}
+
+	// Every FAQ takes an ID for itself and one for the revision of its text
+	resp := doRequest("GET", "/api/faqs?fields=id", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"id":239}]`)
+	expectHeader(t, resp, "X-Total-Count", "120")
+	expectEmptyHeader(t, resp, "Link")
+
+	resp = doRequest("GET", "/api/faqs?fields=id&offset=100", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"id":201},`)
+	expectBodyContains(t, resp, `{"id":239}]`)
+	expectHeaderMatches(t, resp, "Link", `rel="prev"`)
+}
+
+func TestGetAPISearchFAQsContentLanguageOfPage(t *testing.T) {
+	repo := seedTransferDB(t)
+	faqRepository = repo
+	f := FAQ{Texts: []FAQText{FAQText{Locale: Locale{Code: "de"}, Question: "Wie bezahle ich per Karte?", Answer: "Mit Karte."}}}
+	expectNoError(t, repo.CreateFAQWithTexts(&f))
+	expectNoError(t, repo.PublishFAQText(f.ID, "de", testAuthor))
+	f = FAQ{Texts: []FAQText{FAQText{Locale: Locale{Code: "en"}, Question: "Can I pay by Karte?", Answer: "Yes."}}}
+	expectNoError(t, repo.CreateFAQWithTexts(&f))
+	expectNoError(t, repo.PublishFAQText(f.ID, "en", testAuthor))
+
+	languages := []string{}
+	for _, offset := range []string{"0", "1"} {
+		resp := doRequest("GET", "/api/search-faqs?lang=de&query=Karte&limit=1&offset="+offset, emptyBody())
+		expectStatus(t, resp, 200)
+		expectHeader(t, resp, "X-Total-Count", "2")
+		languages = append(languages, resp.Header().Get("Content-Language"))
+	}
+	sort.Strings(languages)
+	expectSameString(t, "de en", strings.Join(languages, " "))
+}
@@ -0,0 +1,692 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// LocaleSetting is a locale as managed on the Languages page. Only enabled
+// locales are supported, in the order of their position. Exactly one locale
+// is the default locale, which must be enabled.
+type LocaleSetting struct {
+	Locale
+	Enabled  bool `json:"enabled"`
+	Position int  `json:"position"`
+	Default  bool `json:"default"`
+
+	// Fallbacks are the locale codes whose texts are served, in order, when
+	// an FAQ is not translated into this locale. See fallbackChain.
+	Fallbacks []string `json:"fallbacks"`
+}
+
+// FallbackCodes returns the configured fallbacks as a comma separated list.
+func (s LocaleSetting) FallbackCodes() string {
+	return strings.Join(s.Fallbacks, ",")
+}
+
+// ParentCodes returns the parents the locale falls back to when no
+// fallbacks are configured.
+func (s LocaleSetting) ParentCodes() string {
+	return strings.Join(parentLocales(s.Code), ",")
+}
+
+var (
+	errLocaleExists   = errors.New("locale exists")
+	errLocaleNotFound = errors.New("locale not found")
+)
+
+// localesMu guards the supported locales, which change when locales are
+// managed at runtime.
+var (
+	localesMu        sync.RWMutex
+	supportedLocales []Locale // In display order
+	defaultLocale    Locale
+	languageMatcher  language.Matcher
+	matcherLocales   []string            // Locale codes by matcher index
+	localeFallbacks  map[string][]string // Configured fallbacks by locale code
+)
+
+// SUPPORTED_LOCALES seeds the locales table of a new database, its first
+// locale being the default. Until the locales are loaded from the database
+// it sets the supported locales, as in tests and migrations, so it must be
+// valid.
+func init() {
+	settings, err := localesFromEnv()
+	if err != nil {
+		panic(err)
+	}
+	setLocales(settings)
+}
+
+func localesFromEnv() ([]LocaleSetting, error) {
+	settings := []LocaleSetting{}
+	for i, code := range strings.Split(os.Getenv("SUPPORTED_LOCALES"), ",") {
+		code = strings.TrimSpace(code)
+		if err := validateLocaleCode(code); err != nil {
+			return nil, fmt.Errorf("SUPPORTED_LOCALES missing or wrong: %v", err)
+		}
+		settings = append(settings, LocaleSetting{Locale: localeFromCode(code), Enabled: true, Position: i + 1, Default: i == 0, Fallbacks: []string{}})
+	}
+	return settings, nil
+}
+
+func validateLocaleCode(code string) error {
+	if len(localeFromCode(code).NameEnglish) == 0 {
+		return fmt.Errorf("unknown locale: %v", code)
+	}
+	return nil
+}
+
+// setLocales makes the enabled locales of settings the supported locales.
+func setLocales(settings []LocaleSetting) {
+	locales := []Locale{}
+	var def Locale
+	fallbacks := make(map[string][]string)
+	for _, s := range sortedLocaleSettings(settings) {
+		if !s.Enabled {
+			continue
+		}
+		locales = append(locales, s.Locale)
+		if len(s.Fallbacks) > 0 {
+			fallbacks[s.Code] = s.Fallbacks
+		}
+		if s.Default {
+			def = s.Locale
+		}
+	}
+
+	// The first tag is what the matcher falls back to
+	codes := []string{def.Code}
+	for _, l := range locales {
+		if l.Code != def.Code {
+			codes = append(codes, l.Code)
+		}
+	}
+	tags := []language.Tag{}
+	for _, code := range codes {
+		tags = append(tags, language.Make(code))
+	}
+
+	localesMu.Lock()
+	defer localesMu.Unlock()
+	supportedLocales = locales
+	defaultLocale = def
+	languageMatcher = language.NewMatcher(tags)
+	matcherLocales = codes
+	localeFallbacks = fallbacks
+}
+
+func sortedLocaleSettings(settings []LocaleSetting) []LocaleSetting {
+	sorted := append([]LocaleSetting{}, settings...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Position != sorted[j].Position {
+			return sorted[i].Position < sorted[j].Position
+		}
+		return sorted[i].Code < sorted[j].Code
+	})
+	return sorted
+}
+
+// loadLocales sets the supported locales from repo. An empty locales table is
+// seeded from SUPPORTED_LOCALES first.
+func loadLocales(repo FAQRepository) error {
+	settings, err := repo.AllLocales()
+	if err != nil {
+		return err
+	}
+	if len(settings) == 0 {
+		settings, err = localesFromEnv()
+		if err != nil {
+			return err
+		}
+		for i := range settings {
+			if err = repo.CreateLocale(&settings[i], systemAuthor); err != nil {
+				return err
+			}
+		}
+	}
+	setLocales(settings)
+	return nil
+}
+
+// localesReloadInterval is how often the locales are read from the database.
+// Changes made on another server instance take effect after at most this
+// delay.
+const localesReloadInterval = 30 * time.Second
+
+func reloadLocalesPeriodically(repo FAQRepository, interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		if err := loadLocales(repo); err != nil {
+			logError(err)
+		}
+	}
+}
+
+// getSupportedLocales returns the enabled locales in display order. The
+// slice must not be modified.
+func getSupportedLocales() []Locale {
+	localesMu.RLock()
+	defer localesMu.RUnlock()
+	return supportedLocales
+}
+
+// matchLocale returns the code of the supported locale that best matches
+// the language preferences, which are Accept-Language values in order of
+// precedence. It is the default locale if nothing matches.
+func matchLocale(preferences ...string) string {
+	localesMu.RLock()
+	defer localesMu.RUnlock()
+	_, index := language.MatchStrings(languageMatcher, preferences...)
+	return matcherLocales[index]
+}
+
+func localeFromCode(languageCode string) Locale {
+	tag, err := language.Parse(languageCode)
+	if err != nil {
+		return Locale{Code: languageCode}
+	}
+	en := display.English.Tags()
+	locale := Locale{
+		Code:        languageCode,
+		NameEnglish: en.Name(tag),
+		NameLocal:   display.Self.Name(tag),
+	}
+	return locale
+}
+
+// rtlScripts are the scripts written from right to left, by ISO 15924 code.
+var rtlScripts = map[string]bool{
+	"Adlm": true, // Adlam
+	"Arab": true, // Arabic, Persian, Urdu
+	"Hebr": true, // Hebrew, Yiddish
+	"Mand": true, // Mandaic
+	"Nkoo": true, // N'Ko
+	"Rohg": true, // Hanifi Rohingya
+	"Samr": true, // Samaritan
+	"Syrc": true, // Syriac
+	"Thaa": true, // Thaana, Dhivehi
+}
+
+// Dir returns the direction of the locale's script for the HTML dir
+// attribute, "rtl" or "ltr". Without an explicit script subtag the most
+// likely script of the language is used, as Arab for ar.
+func (l Locale) Dir() string {
+	if l.IsRTL() {
+		return "rtl"
+	}
+	return "ltr"
+}
+
+func (l Locale) IsRTL() bool {
+	tag, err := language.Parse(l.Code)
+	if err != nil {
+		return false
+	}
+	script, _ := tag.Script()
+	return rtlScripts[script.String()]
+}
+
+func getDefaultLocale() Locale {
+	localesMu.RLock()
+	defer localesMu.RUnlock()
+	return defaultLocale
+}
+
+func isSupportedLocale(localeCode string) bool {
+	for _, loc := range getSupportedLocales() {
+		if loc.Code == localeCode {
+			return true
+		}
+	}
+	return false
+}
+
+// fallbackChain returns the locales whose texts are served for localeCode,
+// in order: the locale itself, its configured fallbacks and the default
+// locale. Without configured fallbacks a regional locale falls back to its
+// parents, as pt-BR to pt. Unsupported fallbacks are left out.
+func fallbackChain(localeCode string) []string {
+	localesMu.RLock()
+	fallbacks, ok := localeFallbacks[localeCode]
+	def := defaultLocale.Code
+	localesMu.RUnlock()
+	if !ok {
+		fallbacks = parentLocales(localeCode)
+	}
+
+	chain := []string{localeCode}
+	candidates := append(append([]string{}, fallbacks...), def)
+	for _, code := range candidates {
+		if isSupportedLocale(code) && !containsString(chain, code) {
+			chain = append(chain, code)
+		}
+	}
+	return chain
+}
+
+// parentLocales returns the codes of localeCode with subtags removed from
+// the end, as zh-Hant and zh for zh-Hant-TW.
+func parentLocales(localeCode string) []string {
+	parents := []string{}
+	for i := strings.LastIndex(localeCode, "-"); i > 0; i = strings.LastIndex(localeCode, "-") {
+		localeCode = localeCode[:i]
+		parents = append(parents, localeCode)
+	}
+	return parents
+}
+
+// validateLocaleChange checks that updated keeps a single, enabled default
+// locale and that its fallbacks are other known locales.
+func validateLocaleChange(current, updated LocaleSetting) error {
+	if updated.Default && !updated.Enabled {
+		return errors.New("the default locale must be enabled")
+	}
+	if current.Default && !updated.Default {
+		return errors.New("make another locale the default instead")
+	}
+	for _, code := range updated.Fallbacks {
+		if err := validateLocaleCode(code); err != nil {
+			return err
+		}
+		if code == updated.Code {
+			return errors.New("a locale can't fall back to itself")
+		}
+	}
+	return nil
+}
+
+// parseLocaleCodes splits a comma separated list of locale codes.
+func parseLocaleCodes(s string) []string {
+	codes := []string{}
+	for _, code := range strings.Split(s, ",") {
+		code = strings.TrimSpace(code)
+		if len(code) > 0 {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+func localeSettingByCode(settings []LocaleSetting, code string) *LocaleSetting {
+	for i := range settings {
+		if settings[i].Code == code {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+///// Persistence
+
+func (db *DB) AllLocales() ([]LocaleSetting, error) {
+	return getAllLocales(db.DB)
+}
+
+func (db *DB) CreateLocale(setting *LocaleSetting, author string) error {
+	return withAudit(db.DB, author, auditCreateLocale, localeDetails(setting.Code), func(tx *sql.Tx) error {
+		return createLocale(tx, setting)
+	})
+}
+
+func (db *DB) SaveLocale(setting *LocaleSetting, author string) error {
+	return withAudit(db.DB, author, auditSaveLocale, localeDetails(setting.Code), func(tx *sql.Tx) error {
+		return saveLocale(tx, setting)
+	})
+}
+
+func (db *DB) MoveLocales(codes []string, author string) error {
+	return withAudit(db.DB, author, auditMoveLocales, localesDetails(codes), func(tx *sql.Tx) error {
+		return moveLocales(tx, codes)
+	})
+}
+
+func (mdb *mockDB) AllLocales() ([]LocaleSetting, error) {
+	return localesFromEnv()
+}
+
+func (mdb *mockDB) CreateLocale(setting *LocaleSetting, author string) error {
+	return nil
+}
+
+func (mdb *mockDB) SaveLocale(setting *LocaleSetting, author string) error {
+	return nil
+}
+
+func (mdb *mockDB) MoveLocales(codes []string, author string) error {
+	return nil
+}
+
+func (mdb *brokenDB) AllLocales() ([]LocaleSetting, error) {
+	return nil, errors.New(someDBError)
+}
+
+func (mdb *brokenDB) CreateLocale(setting *LocaleSetting, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) SaveLocale(setting *LocaleSetting, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) MoveLocales(codes []string, author string) error {
+	return errors.New(someDBError)
+}
+
+func getAllLocales(db *sql.DB) ([]LocaleSetting, error) {
+	rows, err := db.Query(`SELECT code, enabled, position, is_default, fallbacks FROM locales ORDER BY position, code;`)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := []LocaleSetting{}
+	for rows.Next() {
+		var code, fallbacks string
+		s := LocaleSetting{}
+		if err = rows.Scan(&code, &s.Enabled, &s.Position, &s.Default, &fallbacks); err != nil {
+			logError(err)
+			return nil, err
+		}
+		s.Locale = localeFromCode(code)
+		s.Fallbacks = parseLocaleCodes(fallbacks)
+		settings = append(settings, s)
+	}
+	return settings, rows.Err()
+}
+
+func createLocale(db dbtx, setting *LocaleSetting) error {
+	if setting.Default {
+		if err := clearDefaultLocale(db); err != nil {
+			return err
+		}
+	}
+	_, err := db.Exec(`INSERT INTO locales (code, enabled, position, is_default, fallbacks) VALUES ($1, $2, $3, $4, $5);`,
+		setting.Code, setting.Enabled, setting.Position, setting.Default, strings.Join(setting.Fallbacks, ","))
+	if isUniqueViolation(err) {
+		return errLocaleExists
+	}
+	if err != nil {
+		logError(err)
+	}
+	return err
+}
+
+// saveLocale updates a locale. Making it the default locale unsets the
+// previous default.
+func saveLocale(db dbtx, setting *LocaleSetting) error {
+	if setting.Default {
+		if err := clearDefaultLocale(db); err != nil {
+			return err
+		}
+	}
+	res, err := db.Exec(`UPDATE locales SET enabled = $1, position = $2, is_default = $3, fallbacks = $4 WHERE code = $5;`,
+		setting.Enabled, setting.Position, setting.Default, strings.Join(setting.Fallbacks, ","), setting.Code)
+	if err != nil {
+		logError(err)
+		return err
+	}
+	return expectRowsAffected(res, errLocaleNotFound)
+}
+
+// moveLocales numbers the positions of the locales with codes from 1 in the
+// given order.
+func moveLocales(db dbtx, codes []string) error {
+	for i, code := range codes {
+		res, err := db.Exec(`UPDATE locales SET position = $1 WHERE code = $2;`, i+1, code)
+		if err != nil {
+			logError(err)
+			return err
+		}
+		if err = expectRowsAffected(res, errLocaleNotFound); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func clearDefaultLocale(tx dbtx) error {
+	_, err := tx.Exec(`UPDATE locales SET is_default = false WHERE is_default;`)
+	if err != nil {
+		logError(err)
+	}
+	return err
+}
+
+///// Locale handlers
+
+func getAPILocales(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	settings, err := faqRepository.AllLocales()
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeJSON(w, settings)
+}
+
+// apiLocaleChange is the body of locale requests. Fields left out keep their
+// value, or take the default for new locales.
+type apiLocaleChange struct {
+	Code      string    `json:"code"`
+	Enabled   *bool     `json:"enabled"`
+	Position  *int      `json:"position"`
+	Default   *bool     `json:"default"`
+	Fallbacks *[]string `json:"fallbacks"`
+}
+
+func (c apiLocaleChange) apply(setting *LocaleSetting) {
+	if c.Enabled != nil {
+		setting.Enabled = *c.Enabled
+	}
+	if c.Position != nil {
+		setting.Position = *c.Position
+	}
+	if c.Default != nil {
+		setting.Default = *c.Default
+	}
+	if c.Fallbacks != nil {
+		setting.Fallbacks = *c.Fallbacks
+	}
+}
+
+func postAPILocale(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	change := apiLocaleChange{}
+	if err := readJSON(r, w, &change); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	code := strings.TrimSpace(change.Code)
+	if err := validateLocaleCode(code); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	settings, err := faqRepository.AllLocales()
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	setting := LocaleSetting{Locale: localeFromCode(code), Enabled: true, Position: nextLocalePosition(settings), Fallbacks: []string{}}
+	change.apply(&setting)
+	if err := validateLocaleChange(LocaleSetting{}, setting); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = faqRepository.CreateLocale(&setting, apiAuthor(r))
+	if err == errLocaleExists {
+		writeJSONErr(w, http.StatusConflict, "locale exists")
+		return
+	}
+	if err == nil {
+		err = loadLocales(faqRepository)
+	}
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeJSONWithStatus(w, http.StatusCreated, setting)
+}
+
+func putAPILocale(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	change := apiLocaleChange{}
+	if err := readJSON(r, w, &change); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+	setting, status, err := updateLocale(ps.ByName("code"), apiAuthor(r), change.apply)
+	if err != nil {
+		msg := err.Error()
+		if status == http.StatusInternalServerError {
+			msg = internalError
+		}
+		writeJSONErr(w, status, msg)
+		return
+	}
+	writeJSON(w, setting)
+}
+
+// updateLocale changes the locale with the given code and reloads the
+// supported locales. On failure it returns the HTTP status to respond with.
+func updateLocale(code string, author string, change func(*LocaleSetting)) (*LocaleSetting, int, error) {
+	settings, err := faqRepository.AllLocales()
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	current := localeSettingByCode(settings, code)
+	if current == nil {
+		return nil, http.StatusNotFound, errLocaleNotFound
+	}
+
+	updated := *current
+	change(&updated)
+	if err = validateLocaleChange(*current, updated); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	err = faqRepository.SaveLocale(&updated, author)
+	if err == nil {
+		err = loadLocales(faqRepository)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return &updated, http.StatusOK, nil
+}
+
+func nextLocalePosition(settings []LocaleSetting) int {
+	position := 0
+	for _, s := range settings {
+		if s.Position > position {
+			position = s.Position
+		}
+	}
+	return position + 1
+}
+
+func postAdminLocalesCreate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	code := strings.TrimSpace(r.FormValue("code"))
+	if err := validateLocaleCode(code); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	settings, err := faqRepository.AllLocales()
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	setting := LocaleSetting{Locale: localeFromCode(code), Position: nextLocalePosition(settings), Fallbacks: []string{}}
+	err = faqRepository.CreateLocale(&setting, currentAuthor(r))
+	if err == errLocaleExists {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == nil {
+		err = loadLocales(faqRepository)
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/locales", http.StatusFound)
+}
+
+// postAdminLocalesUpdate enables or disables a locale, makes it the
+// default locale or sets its fallbacks.
+func postAdminLocalesUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	action := r.FormValue("action")
+	_, status, err := updateLocale(r.FormValue("code"), currentAuthor(r), func(s *LocaleSetting) {
+		switch action {
+		case "enable":
+			s.Enabled = true
+		case "disable":
+			s.Enabled = false
+		case "default":
+			s.Default = true
+		case "fallbacks":
+			s.Fallbacks = parseLocaleCodes(r.FormValue("fallbacks"))
+		}
+	})
+	if status == http.StatusInternalServerError {
+		http.Error(w, internalError, status)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	http.Redirect(w, r, "/admin/locales", http.StatusFound)
+}
+
+func postAdminLocalesMove(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	settings, err := faqRepository.AllLocales()
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	settings = sortedLocaleSettings(settings)
+
+	i := -1
+	for j, s := range settings {
+		if s.Code == r.FormValue("code") {
+			i = j
+		}
+	}
+	if i < 0 {
+		http.Error(w, "locale not found", http.StatusNotFound)
+		return
+	}
+	j := i - 1
+	if r.FormValue("direction") == "down" {
+		j = i + 1
+	}
+	if j >= 0 && j < len(settings) {
+		settings[i], settings[j] = settings[j], settings[i]
+	}
+
+	// Renumber all locales so that positions stay dense and unique.
+	codes := []string{}
+	for _, s := range settings {
+		codes = append(codes, s.Code)
+	}
+	if err = faqRepository.MoveLocales(codes, currentAuthor(r)); err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	if err = loadLocales(faqRepository); err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/locales", http.StatusFound)
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// restoreLocales resets the supported locales to SUPPORTED_LOCALES after a
+// test has changed them.
+func restoreLocales() {
+	settings, err := localesFromEnv()
+	if err != nil {
+		panic(err)
+	}
+	setLocales(settings)
+}
+
+func testLocales(t *testing.T, repo FAQRepository) {
+	defer restoreLocales()
+
+	expectNoError(t, loadLocales(repo))
+	settings, err := repo.AllLocales()
+	expectNoError(t, err)
+	expectSameInt(t, len(getSupportedLocales()), len(settings))
+	expectSameString(t, "en", settings[0].Code)
+	expectIsTrue(t, settings[0].Default)
+	expectSameInt(t, 1, settings[0].Position)
+
+	expectSameError(t, errLocaleExists, repo.CreateLocale(&LocaleSetting{Locale: Locale{Code: "de"}}, testAuthor))
+	expectSameError(t, errLocaleNotFound, repo.SaveLocale(&LocaleSetting{Locale: Locale{Code: "ja"}}, testAuthor))
+
+	ja := LocaleSetting{Locale: Locale{Code: "ja"}, Position: 0}
+	expectNoError(t, repo.CreateLocale(&ja, testAuthor))
+	de := *localeSettingByCode(settings, "de")
+	de.Default = true
+	expectNoError(t, repo.SaveLocale(&de, testAuthor))
+
+	settings, err = repo.AllLocales()
+	expectNoError(t, err)
+	expectSameString(t, "ja", settings[0].Code)
+	expectIsTrue(t, !settings[0].Enabled)
+	expectIsTrue(t, !localeSettingByCode(settings, "en").Default)
+	expectIsTrue(t, localeSettingByCode(settings, "de").Default)
+
+	de.Fallbacks = []string{"fr"}
+	expectNoError(t, repo.SaveLocale(&de, testAuthor))
+	settings, _ = repo.AllLocales()
+	expectSameString(t, "fr", localeSettingByCode(settings, "de").FallbackCodes())
+	expectSameString(t, "", localeSettingByCode(settings, "fr").FallbackCodes())
+
+	position := localeSettingByCode(settings, "de").Position
+	expectSameError(t, errLocaleNotFound, repo.MoveLocales([]string{"de", "xx"}, testAuthor))
+	settings, _ = repo.AllLocales()
+	expectSameInt(t, position, localeSettingByCode(settings, "de").Position)
+	expectNoError(t, repo.MoveLocales([]string{"fr", "ja"}, testAuthor))
+	settings, _ = repo.AllLocales()
+	expectSameInt(t, 1, localeSettingByCode(settings, "fr").Position)
+	expectSameInt(t, 2, localeSettingByCode(settings, "ja").Position)
+
+	// Existing locales are not seeded again
+	expectNoError(t, loadLocales(repo))
+	expectSameString(t, "de", getDefaultLocale().Code)
+	expectIsTrue(t, !isSupportedLocale("ja"))
+}
+
+func TestLocales(t *testing.T) {
+	testLocales(t, NewMemoryDB())
+}
+
+func TestLocalesInDB(t *testing.T) {
+	testLocales(t, prepareDB())
+}
+
+func TestSetLocales(t *testing.T) {
+	defer restoreLocales()
+
+	setLocales([]LocaleSetting{
+		LocaleSetting{Locale: localeFromCode("fr"), Enabled: true, Position: 2},
+		LocaleSetting{Locale: localeFromCode("en"), Enabled: false, Position: 1},
+		LocaleSetting{Locale: localeFromCode("de"), Enabled: true, Position: 3, Default: true},
+	})
+	locales := getSupportedLocales()
+	expectSameInt(t, 2, len(locales))
+	expectSameString(t, "fr", locales[0].Code)
+	expectSameString(t, "de", getDefaultLocale().Code)
+
+	resp := doRequestWithHeader("GET", "/", emptyBody(), &http.Header{"Accept-Language": []string{"en"}})
+	expectHeader(t, resp, "Location", "/faqs/de")
+}
+
+func TestPostAPILocale(t *testing.T) {
+	defer restoreLocales()
+	repo := NewMemoryDB()
+	expectNoError(t, loadLocales(repo))
+	faqRepository = repo
+
+	resp := doRequest("POST", "/api/locales", body(`{"code": "ja"}`))
+	expectStatus(t, resp, 201)
+	expectBodyContains(t, resp, `"code":"ja","name_en":"Japanese"`)
+	expectBodyContains(t, resp, `"enabled":true,"position":15,"default":false`)
+	expectIsTrue(t, isSupportedLocale("ja"))
+
+	resp = doRequest("POST", "/api/locales", body(`{"code": "ja"}`))
+	expectErrorJSON(t, resp, 409, "locale exists")
+
+	resp = doRequest("POST", "/api/locales", body(`{"code": "xx-invalid"}`))
+	expectErrorJSON(t, resp, 400, "unknown locale: xx-invalid")
+
+	resp = doRequest("POST", "/api/locales", body(`{"code": "ko", "enabled": false, "default": true}`))
+	expectErrorJSON(t, resp, 400, "the default locale must be enabled")
+}
+
+func TestPutAPILocale(t *testing.T) {
+	defer restoreLocales()
+	repo := NewMemoryDB()
+	expectNoError(t, loadLocales(repo))
+	faqRepository = repo
+
+	resp := doRequest("PUT", "/api/locales/fr", body(`{"enabled": false}`))
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"enabled":false,"position":3`)
+	expectIsTrue(t, !isSupportedLocale("fr"))
+
+	resp = doRequest("PUT", "/api/locales/de", body(`{"default": true}`))
+	expectStatus(t, resp, 200)
+	expectSameString(t, "de", getDefaultLocale().Code)
+
+	resp = doRequest("PUT", "/api/locales/de", body(`{"enabled": false}`))
+	expectErrorJSON(t, resp, 400, "the default locale must be enabled")
+
+	resp = doRequest("PUT", "/api/locales/de", body(`{"default": false}`))
+	expectErrorJSON(t, resp, 400, "make another locale the default instead")
+
+	resp = doRequest("PUT", "/api/locales/ja", body(`{"enabled": true}`))
+	expectErrorJSON(t, resp, 404, "locale not found")
+
+	resp = doRequest("GET", "/api/locales", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"code":"fr","name_en":"French","name_local":"français","enabled":false,"position":3,"default":false,"fallbacks":[]}`)
+}
+
+func TestGetAPILocalesWithBrokenDB(t *testing.T) {
+	faqRepository = &brokenDB{}
+	resp := doRequest("GET", "/api/locales", emptyBody())
+	expectErrorJSON(t, resp, 500, internalError)
+}
+
+func TestPostAdminLocales(t *testing.T) {
+	defer restoreLocales()
+	repo := NewMemoryDB()
+	expectNoError(t, loadLocales(repo))
+	faqRepository = repo
+	authenticateFunc = alwaysAdminFunc
+
+	resp := doRequestWithHeader("POST", "/admin/locales/create", body("code=ja"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/locales")
+	expectIsTrue(t, !isSupportedLocale("ja"))
+
+	resp = doRequestWithHeader("POST", "/admin/locales/update", body("code=ja&action=enable"), formHeader())
+	expectStatus(t, resp, 302)
+	expectIsTrue(t, isSupportedLocale("ja"))
+
+	resp = doRequestWithHeader("POST", "/admin/locales/move", body("code=ja&direction=up"), formHeader())
+	expectStatus(t, resp, 302)
+	locales := getSupportedLocales()
+	expectSameString(t, "ja", locales[len(locales)-2].Code)
+
+	resp = doRequestWithHeader("POST", "/admin/locales/update", body("code=ja&action=default"), formHeader())
+	expectStatus(t, resp, 302)
+	expectSameString(t, "ja", getDefaultLocale().Code)
+
+	resp = doRequestWithHeader("POST", "/admin/locales/update", body("code=ja&action=disable"), formHeader())
+	expectStatus(t, resp, 400)
+
+	resp = doRequest("GET", "/admin/locales", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<button type="submit" name="action" value="default" class="btn btn-sm btn-outline-primary">Make default</button>`)
+}
+
+func TestFallbackChain(t *testing.T) {
+	defer restoreLocales()
+
+	expectSameString(t, "pt-BR,pt,en", strings.Join(fallbackChain("pt-BR"), ","))
+	expectSameString(t, "de,en", strings.Join(fallbackChain("de"), ","))
+	expectSameString(t, "en", strings.Join(fallbackChain("en"), ","))
+	expectSameString(t, "zh-Hant-TW,zh,en", strings.Join(fallbackChain("zh-Hant-TW"), ","))
+
+	settings, _ := localesFromEnv()
+	localeSettingByCode(settings, "pt-BR").Fallbacks = []string{"es", "ja", "pt"}
+	setLocales(settings)
+	expectSameString(t, "pt-BR,es,pt,en", strings.Join(fallbackChain("pt-BR"), ","))
+}
+
+func TestResolvedText(t *testing.T) {
+	faq := FAQ{Texts: []FAQText{
+		FAQText{Locale: Locale{Code: "en"}, Question: "How do I pay?"},
+		FAQText{Locale: Locale{Code: "pt"}, Question: "Como pago?"},
+		FAQText{Locale: Locale{Code: "fr"}},
+	}}
+	expectSameString(t, "Como pago?", faq.ResolvedText("pt-BR").Question)
+	expectSameString(t, "pt", faq.ResolvedText("pt-BR").Locale.Code)
+	expectSameString(t, "How do I pay?", faq.ResolvedText("fr").Question)
+	expectSameString(t, "en", faq.ResolvedText("de").Locale.Code)
+
+	faq.Texts = faq.Texts[1:]
+	expectSameString(t, "", faq.ResolvedText("de").Question)
+	expectSameString(t, "de", faq.ResolvedText("de").Locale.Code)
+}
+
+func TestGetAPISingleFAQWithFallback(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+
+	resp := doRequest("GET", "/api/faqs/2?locale=pt-BR", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "en")
+	expectBodyContains(t, resp, `"texts":[{"locale":{"code":"en","name_en":"English","name_local":"English"},"question":"How do I pay?"`)
+	expectBodyContains(t, resp, `"resolved_locale":"en"}`)
+
+	resp = doRequest("GET", "/api/faqs/2?locale=xx", emptyBody())
+	expectErrorJSON(t, resp, 400, "unsupported locale: xx")
+
+	resp = doRequest("GET", "/api/faqs?locale=pt-BR", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"texts":[{"locale":{"code":"en","name_en":"English","name_local":"English"},"question":"How do I pay?"`)
+}
+
+func TestSearchWithFallbacks(t *testing.T) {
+	repo := seedTransferDB(t)
+	expectNoError(t, repo.PublishFAQText(2, "de", testAuthor))
+	expectNoError(t, repo.PublishFAQText(5, "en", testAuthor))
+	faqRepository = repo
+
+	// FAQ 2 is served in German, so its English text is not searched
+	resp := doRequest("GET", "/api/search-faqs?lang=de&query=how", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "en")
+	expectBodyContains(t, resp, `"faq_id":5,`)
+	expectBodyContains(t, resp, `"resolved_locale":"en"`)
+	expectSameString(t, "1", resp.Header().Get("X-Total-Count"))
+
+	resp = doRequest("GET", "/api/search-faqs?lang=de&query=bezahle", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "de")
+	expectBodyContains(t, resp, `"resolved_locale":"de"`)
+}
+
+func TestPostAdminLocalesFallbacks(t *testing.T) {
+	defer restoreLocales()
+	repo := NewMemoryDB()
+	expectNoError(t, loadLocales(repo))
+	faqRepository = repo
+	authenticateFunc = alwaysAdminFunc
+
+	resp := doRequestWithHeader("POST", "/admin/locales/update", body("code=pt-BR&action=fallbacks&fallbacks=es,+pt"), formHeader())
+	expectStatus(t, resp, 302)
+	expectSameString(t, "pt-BR,es,pt,en", strings.Join(fallbackChain("pt-BR"), ","))
+
+	resp = doRequestWithHeader("POST", "/admin/locales/update", body("code=pt-BR&action=fallbacks&fallbacks=pt-BR"), formHeader())
+	expectStatus(t, resp, 400)
+
+	resp = doRequest("PUT", "/api/locales/de", body(`{"fallbacks": ["xx-invalid"]}`))
+	expectErrorJSON(t, resp, 400, "unknown locale: xx-invalid")
+
+	resp = doRequest("GET", "/admin/locales", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `name="fallbacks" value="es,pt" placeholder="pt"`)
+}
+
+func TestLocaleDir(t *testing.T) {
+	expectSameString(t, "rtl", localeFromCode("ar").Dir())
+	expectSameString(t, "rtl", localeFromCode("he").Dir())
+	expectSameString(t, "rtl", localeFromCode("fa").Dir())
+	expectSameString(t, "ltr", localeFromCode("de").Dir())
+	expectSameString(t, "ltr", localeFromCode("zh").Dir())
+
+	// An explicit script overrides the language's usual one
+	expectSameString(t, "ltr", localeFromCode("az-Latn").Dir())
+	expectSameString(t, "rtl", localeFromCode("pa-Arab").Dir())
+	expectSameString(t, "ltr", Locale{Code: "not a locale"}.Dir())
+}
+
+func TestRightToLeftPages(t *testing.T) {
+	repo := seedTransferDB(t)
+	ar := FAQText{Locale: Locale{Code: "ar"}, Question: "كيف أدفع؟", Answer: "بالبطاقة."}
+	expectNoError(t, repo.SaveFAQText(2, &ar))
+	expectNoError(t, repo.PublishFAQText(2, "ar", testAuthor))
+	faqRepository = repo
+	authenticateFunc = alwaysAdminFunc
+
+	resp := doRequest("GET", "/faq/ar/2", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<html lang="ar" dir="rtl">`)
+	expectBodyContains(t, resp, `href="/faq/en/2" lang="en" dir="ltr"`)
+
+	resp = doRequest("GET", "/faq/en/2", emptyBody())
+	expectBodyContains(t, resp, `<html lang="en" dir="ltr">`)
+
+	resp = doRequest("GET", "/faqs/ar", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<html lang="ar" dir="rtl">`)
+
+	resp = doRequest("GET", "/admin/faqs/edit/2", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `placeholder="Question" lang="ar" dir="rtl">`)
+	expectBodyContains(t, resp, `placeholder="Lorem Ipsum....." lang="de" dir="ltr">`)
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -19,11 +20,9 @@ import (
 
 	"github.com/gorilla/handlers"
 	"github.com/julienschmidt/httprouter"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 
 	"golang.org/x/crypto/bcrypt"
-	"golang.org/x/text/language"
-	"golang.org/x/text/language/display"
 
 	jose "gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
@@ -36,14 +35,54 @@ var dbConn *sql.DB
 
 type FAQRepository interface {
 	AllFAQs() ([]FAQ, error)
+	ListFAQs(opts ListOptions) ([]FAQ, int, error)
 	FAQById(id int) (*FAQ, error)
-	SearchFAQs(language string, query string) ([]FAQ, error)
+	SearchFAQs(language string, query string) ([]SearchResult, error)
+	FuzzySearchFAQs(language string, query string) ([]SearchResult, error)
+	SearchSuggestion(language string, query string) (string, error)
 	UpdateSearchIndex() error
+	PublishedLocales(faqIDs []int) (map[int][]string, error)
 
 	CreateFAQ() (*FAQ, error)
 	SaveFAQText(faqID int, text *FAQText) error
+	CreateFAQWithTexts(faq *FAQ) error
+	SaveFAQTexts(faqID int, texts []FAQText) error
+	PublishFAQText(faqID int, localeCode string, author string) error
+	FAQTextRevisions(faqID int, localeCode string) ([]FAQTextRevision, error)
+	FAQTextRevision(revisionID int) (*FAQTextRevision, error)
+
+	DeleteFAQ(faqID int, author string) error
+	DeleteFAQText(faqID int, localeCode string, author string) error
+
+	TrashedFAQs() ([]FAQ, error)
+	RestoreFAQ(faqID int, author string) error
+	PurgeFAQ(faqID int, author string) error
+	PurgeFAQs(deletedBefore time.Time, author string) (int, error)
+
+	AllCategories() ([]Category, error)
+	CreateCategory(slug string, author string) (*Category, error)
+	SaveCategory(category *Category, author string) error
+	DeleteCategory(categoryID int, author string) error
+	MoveFAQ(faqID int, categoryID int, position int, author string) error
+	MoveFAQs(categoryID int, faqIDs []int, author string) error
+	MoveCategories(categoryIDs []int, author string) error
+
+	// ImportFAQs runs the writes of an import in one transaction.
+	ImportFAQs(fn func(w importWriter) error) error
+
+	AllLocales() ([]LocaleSetting, error)
+	CreateLocale(setting *LocaleSetting, author string) error
+	SaveLocale(setting *LocaleSetting, author string) error
+	MoveLocales(codes []string, author string) error
+
+	AllUsers() ([]User, error)
+	UserByEmail(email string) (*User, error)
+	CreateUser(user *User, author string) error
+	SaveUser(user *User, author string) error
+	DeleteUser(id int, author string) error
+
+	AuditLog(limit int) ([]AuditEntry, error)
 
-	DeleteFAQ(faqID int) error
 	ClearDB() error
 }
 
@@ -51,7 +90,19 @@ type DB struct {
 	*sql.DB
 }
 
-func NewDB(dataSourceName string) (*DB, error) {
+// NewDB picks the repository from the scheme of databaseURL: memory://,
+// sqlite:// or else Postgres.
+func NewDB(databaseURL string) (FAQRepository, error) {
+	if databaseURL == memoryDatabaseURL {
+		return NewMemoryDB(), nil
+	}
+	if strings.HasPrefix(databaseURL, sqliteScheme) {
+		return NewSQLiteDB(strings.TrimPrefix(databaseURL, sqliteScheme))
+	}
+	return NewPostgresDB(databaseURL)
+}
+
+func NewPostgresDB(dataSourceName string) (*DB, error) {
 	db, err := sql.Open("postgres", dataSourceName)
 	if err != nil {
 		return nil, err
@@ -59,6 +110,9 @@ func NewDB(dataSourceName string) (*DB, error) {
 	if err = db.Ping(); err != nil {
 		return nil, err
 	}
+	if err = loadSearchConfigs(db); err != nil {
+		return nil, err
+	}
 	dbConn = db // TODO remove this!
 	return &DB{db}, nil
 }
@@ -71,7 +125,7 @@ func (db *DB) FAQById(id int) (*FAQ, error) {
 	return getFAQ(db.DB, id)
 }
 
-func (db *DB) SearchFAQs(language string, query string) ([]FAQ, error) {
+func (db *DB) SearchFAQs(language string, query string) ([]SearchResult, error) {
 	return searchFAQs(db.DB, language, query)
 }
 
@@ -84,23 +138,33 @@ func (db *DB) CreateFAQ() (*FAQ, error) {
 }
 
 func (db *DB) SaveFAQText(faqID int, text *FAQText) error {
-	err := saveFAQText(db.DB, faqID, text)
-	updateSearchIndex(db.DB)
-	return err
+	return saveFAQText(db.DB, faqID, text)
+}
+
+func (db *DB) DeleteFAQ(faqID int, author string) error {
+	return withAudit(db.DB, author, auditDeleteFAQ, faqDetails(faqID), func(tx *sql.Tx) error {
+		return deleteFAQ(tx, faqID)
+	})
 }
 
-func (db *DB) DeleteFAQ(faqID int) error {
-	return deleteFAQ(db.DB, faqID)
+func (db *DB) DeleteFAQText(faqID int, localeCode string, author string) error {
+	return withAudit(db.DB, author, auditDeleteText, faqTextDetails(faqID, localeCode), func(tx *sql.Tx) error {
+		return deleteFAQText(tx, faqID, localeCode)
+	})
 }
 
 func (db *DB) ClearDB() error {
-	_, err := db.Exec("DELETE FROM faq_texts;")
-	if err != nil {
-		return err
-	}
-	_, err = db.Exec("DELETE FROM faqs;")
-	if err != nil {
-		return err
+	return withTx(db.DB, func(tx *sql.Tx) error {
+		return clearDB(tx)
+	})
+}
+
+func clearDB(db dbtx) error {
+	for _, table := range []string{"faq_text_revisions", "faq_texts", "faqs", "category_texts", "categories", "locales", "users", "audit_log"} {
+		_, err := db.Exec(fmt.Sprintf("DELETE FROM %s;", table))
+		if err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -110,8 +174,8 @@ type mockDB struct{}
 
 func (mdb *mockDB) AllFAQs() ([]FAQ, error) {
 	texts := make([]FAQText, 0)
-	texts = append(texts, FAQText{Locale: Locale{Code: "en", NameLocal: "English"}, Question: "question?", Answer: "answer!"})
-	texts = append(texts, FAQText{Locale: Locale{Code: "de", NameLocal: "Deutsch"}, Question: "Frage?", Answer: "Antwort!"})
+	texts = append(texts, publishedFAQText(FAQText{Locale: Locale{Code: "en", NameLocal: "English"}, Question: "question?", Answer: "answer!"}))
+	texts = append(texts, publishedFAQText(FAQText{Locale: Locale{Code: "de", NameLocal: "Deutsch"}, Question: "Frage?", Answer: "Antwort!"}))
 
 	faqs := make([]FAQ, 0)
 	faqs = append(faqs, FAQ{ID: 123, Texts: texts})
@@ -130,8 +194,17 @@ func (mdb *mockDB) FAQById(id int) (*FAQ, error) {
 	return nil, errors.New("faq not found")
 }
 
-func (mdb *mockDB) SearchFAQs(language string, query string) ([]FAQ, error) {
-	return mdb.AllFAQs()
+func (mdb *mockDB) SearchFAQs(language string, query string) ([]SearchResult, error) {
+	faq, _ := mdb.FAQById(123)
+	text := faq.TextForLocale(language)
+	result := SearchResult{
+		FAQID:           faq.ID,
+		Text:            text,
+		QuestionSnippet: "<mark>" + text.Question + "</mark>",
+		AnswerSnippet:   text.Answer,
+		Rank:            0.6,
+	}
+	return []SearchResult{result}, nil
 }
 
 func (mdb *mockDB) UpdateSearchIndex() error {
@@ -146,7 +219,11 @@ func (mdb *mockDB) SaveFAQText(faqID int, text *FAQText) error {
 	return nil
 }
 
-func (mdb *mockDB) DeleteFAQ(faqID int) error {
+func (mdb *mockDB) DeleteFAQ(faqID int, author string) error {
+	return nil
+}
+
+func (mdb *mockDB) DeleteFAQText(faqID int, localeCode string, author string) error {
 	return nil
 }
 
@@ -167,7 +244,7 @@ func (mdb *brokenDB) FAQById(id int) (*FAQ, error) {
 	return nil, errors.New(someDBError)
 }
 
-func (mdb *brokenDB) SearchFAQs(language string, query string) ([]FAQ, error) {
+func (mdb *brokenDB) SearchFAQs(language string, query string) ([]SearchResult, error) {
 	return nil, errors.New(someDBError)
 }
 
@@ -183,7 +260,11 @@ func (mdb *brokenDB) SaveFAQText(faqID int, text *FAQText) error {
 	return errors.New(someDBError)
 }
 
-func (mdb *brokenDB) DeleteFAQ(faqID int) error {
+func (mdb *brokenDB) DeleteFAQ(faqID int, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) DeleteFAQText(faqID int, localeCode string, author string) error {
 	return errors.New(someDBError)
 }
 
@@ -202,7 +283,13 @@ type MenuEntry struct {
 func menuBar(activeItem string) []MenuEntry {
 	mb := []MenuEntry{
 		MenuEntry{Name: "FAQs", URL: "/admin/faqs", Active: activeItem == "FAQs"},
+		MenuEntry{Name: "Categories", URL: "/admin/categories", Active: activeItem == "Categories"},
 		MenuEntry{Name: "Languages", URL: "/admin/locales", Active: activeItem == "Languages"},
+		MenuEntry{Name: "Trash", URL: "/admin/trash", Active: activeItem == "Trash"},
+		MenuEntry{Name: "Translations", URL: "/admin/translations", Active: activeItem == "Translations"},
+		MenuEntry{Name: "Import & Export", URL: "/admin/import", Active: activeItem == "Import & Export"},
+		MenuEntry{Name: "Users", URL: "/admin/users", Active: activeItem == "Users"},
+		MenuEntry{Name: "Audit Log", URL: "/admin/audit", Active: activeItem == "Audit Log"},
 	}
 	return mb
 }
@@ -218,8 +305,15 @@ func (l *Locale) IsDefaultLocale() bool {
 }
 
 type FAQ struct {
-	ID    int       `json:"id"`
-	Texts []FAQText `json:"texts"`
+	ID         int        `json:"id"`
+	Texts      []FAQText  `json:"texts"`
+	CategoryID int        `json:"category_id,omitempty"`
+	Position   int        `json:"position,omitempty"`   // Position within its category
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"` // Set while the FAQ is in the trash
+}
+
+func (f FAQ) IsDeleted() bool {
+	return f.DeletedAt != nil
 }
 
 func (f *FAQ) TextForLocale(localeCode string) FAQText {
@@ -231,6 +325,20 @@ func (f *FAQ) TextForLocale(localeCode string) FAQText {
 	return FAQText{Locale: Locale{Code: localeCode}}
 }
 
+// ResolvedText returns the first text with a question along the fallback
+// chain of localeCode. Its locale is the one actually served. If there is
+// none, the empty text in localeCode is returned.
+func (f *FAQ) ResolvedText(localeCode string) FAQText {
+	for _, code := range fallbackChain(localeCode) {
+		for _, t := range f.Texts {
+			if t.Locale.Code == code && len(t.Question) > 0 {
+				return t
+			}
+		}
+	}
+	return FAQText{Locale: localeFromCode(localeCode)}
+}
+
 func (f *FAQ) TextInDefaultLocale() FAQText {
 	for _, t := range f.Texts {
 		if t.Locale.IsDefaultLocale() {
@@ -240,23 +348,105 @@ func (f *FAQ) TextInDefaultLocale() FAQText {
 	return FAQText{Locale: getDefaultLocale()}
 }
 
+// FAQText holds the working copy (draft) of an FAQ in one locale together
+// with the content that was last published from it.
 type FAQText struct {
 	iD       int
 	Locale   Locale `json:"locale"`
 	Question string `json:"question"`
 	Answer   string `json:"answer"`
+
+	// Version counts the saves of the text. A save passing the version it
+	// was based on fails with errVersionConflict if the text has been saved
+	// since. Version 0 saves unconditionally.
+	Version int `json:"version,omitempty"`
+
+	// SourceVersion is the version of the text in the default locale when
+	// this translation was saved. The translation is outdated once the
+	// source has been saved since. Not used for the default locale.
+	SourceVersion int `json:"source_version,omitempty"`
+
+	// keepSourceVersion saves SourceVersion as it is, e.g. when restoring a
+	// revision, instead of the current version of the source.
+	keepSourceVersion bool
+
+	PublishedQuestion string     `json:"-"`
+	PublishedAnswer   string     `json:"-"`
+	PublishedAt       *time.Time `json:"-"`
+
+	Author string `json:"-"` // Who saved the text, recorded in its revision
+}
+
+func (t *FAQText) IsPublished() bool {
+	return t.PublishedAt != nil
+}
+
+func (t *FAQText) HasUnpublishedChanges() bool {
+	return !t.IsPublished() || t.Question != t.PublishedQuestion || t.Answer != t.PublishedAnswer
+}
+
+// Published returns the FAQ as the public sees it: only published texts,
+// showing their published content.
+func (f *FAQ) Published() *FAQ {
+	published := *f
+	if f.Texts == nil {
+		return &published
+	}
+
+	published.Texts = []FAQText{}
+	for _, t := range f.Texts {
+		if t.IsPublished() {
+			t.Question = t.PublishedQuestion
+			t.Answer = t.PublishedAnswer
+			published.Texts = append(published.Texts, t)
+		}
+	}
+	return &published
+}
+
+func (f *FAQ) HasUnpublishedChanges() bool {
+	for _, t := range f.Texts {
+		if t.HasUnpublishedChanges() {
+			return true
+		}
+	}
+	return false
 }
 
 type Error struct {
 	Error string `json:"error"`
 }
 
+var (
+	errFAQNotFound     = errors.New("faq not found")
+	errFAQTextNotFound = errors.New("faq text not found")
+	errVersionConflict = errors.New("version conflict")
+)
+
+// Postgres error codes we map to errors of our own.
+const (
+	pqForeignKeyViolation = "23503"
+	pqUniqueViolation     = "23505"
+)
+
+func isPQError(err error, code pq.ErrorCode) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == code
+}
+
+func isUniqueViolation(err error) bool {
+	return isPQError(err, pqUniqueViolation) || isSQLiteUniqueViolation(err)
+}
+
+func isForeignKeyViolation(err error) bool {
+	return isPQError(err, pqForeignKeyViolation) || isSQLiteForeignKeyViolation(err)
+}
+
 func redirectToFAQs(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
-	lang, _ := r.Cookie("lang")
-	accept := r.Header.Get("Accept-Language")
-	tag, _ := language.MatchStrings(languageMatcher, lang.String(), accept)
+	localeCode, _ := negotiateLocale(r)
+	setVaryLanguage(w)
 
-	redirectURL := fmt.Sprintf("/faqs/%s", tag)
+	redirectURL := fmt.Sprintf("/faqs/%s", localeCode)
 	http.Redirect(w, r, redirectURL, http.StatusFound)
 }
 
@@ -282,36 +472,70 @@ func getSingleFAQHTML(w http.ResponseWriter, r *http.Request, p httprouter.Param
 		writeJSONErr(w, 404, "faq not found")
 		return
 	}
+	faq = faq.Published()
+	if len(faq.Texts) == 0 || faq.IsDeleted() {
+		writeJSONErr(w, 404, "faq not found")
+		return
+	}
+	text := faq.ResolvedText(localeCode)
 	data := FAQPageData{
-		PageTitle: faq.TextForLocale(localeCode).Question,
+		PageTitle: text.Question,
 		// MenuBar:   menuBar("FAQs"),
-		Text: faq.TextForLocale(localeCode),
-		FAQ:  faq,
+		Text:   text,
+		FAQ:    faq,
+		Locale: localeFromCode(localeCode),
 	}
+	w.Header().Set("Content-Language", text.Locale.Code)
 	mustExecuteTemplateNoLayout(tmplFAQ, w, data)
 }
 
 func getLanguages(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	writeJSON(w, supportedLocales)
+	writeJSON(w, getSupportedLocales())
 }
 
-func saveFAQText(db *sql.DB, faqID int, text *FAQText) error {
+// saveFAQText upserts the text and records a revision. It sets the new
+// version and source version of text, or returns errVersionConflict if
+// text.Version is stale.
+func saveFAQText(db dbtx, faqID int, text *FAQText) error {
 	sqlStatement := `
-		INSERT INTO faq_texts (faq_id,locale,question,answer)
-		VALUES ($1, $2, $3, $4)
-		ON CONFLICT ON CONSTRAINT texts_faq_id_locale
-		  DO UPDATE SET
-		   question = EXCLUDED.question,
-		   answer = EXCLUDED.answer;
+		WITH saved AS (
+		  INSERT INTO faq_texts (faq_id,locale,question,answer,search_config,source_version)
+		  VALUES ($1, $2, $3, $4, $6::regconfig,
+		    CASE WHEN $9 THEN $10::integer
+		    ELSE (SELECT version FROM faq_texts WHERE faq_id = $1 AND locale = $8 AND $2 <> $8) END)
+		  ON CONFLICT ON CONSTRAINT texts_faq_id_locale
+		    DO UPDATE SET
+		     question = EXCLUDED.question,
+		     answer = EXCLUDED.answer,
+		     search_config = EXCLUDED.search_config,
+		     source_version = EXCLUDED.source_version,
+		     version = faq_texts.version + 1
+		    WHERE $7 = 0 OR faq_texts.version = $7
+		  RETURNING faq_id, locale, question, answer, version, source_version
+		), revision AS (
+		  INSERT INTO faq_text_revisions (faq_id,locale,question,answer,author,source_version)
+		  SELECT faq_id, locale, question, answer, $5, source_version FROM saved
+		)
+		SELECT version, source_version FROM saved;
 		`
-	_, err := db.Exec(sqlStatement, faqID, text.Locale.Code, text.Question, text.Answer)
+	var sourceVersion sql.NullInt64
+	err := db.QueryRow(sqlStatement, faqID, text.Locale.Code, text.Question, text.Answer, text.Author,
+		searchConfig(text.Locale.Code), text.Version, getDefaultLocale().Code,
+		text.keepSourceVersion, nullInt(text.SourceVersion)).Scan(&text.Version, &sourceVersion)
+	text.SourceVersion = int(sourceVersion.Int64)
+	if isForeignKeyViolation(err) {
+		return errFAQNotFound
+	}
+	if err == sql.ErrNoRows {
+		return errVersionConflict
+	}
 	if err != nil {
 		logError(err)
 	}
 	return err
 }
 
-func createFAQ(db *sql.DB) (*FAQ, error) {
+func createFAQ(db dbtx) (*FAQ, error) {
 	sqlStatement := `INSERT INTO faqs (question) VALUES (NULL) RETURNING id;`
 	faq := FAQ{}
 	err := db.QueryRow(sqlStatement).Scan(&faq.ID)
@@ -322,24 +546,53 @@ func createFAQ(db *sql.DB) (*FAQ, error) {
 	return &faq, nil
 }
 
-func deleteFAQ(db *sql.DB, faqID int) error {
-	sqlStatement := `DELETE FROM faq_texts WHERE faq_id = $1;`
-	_, err := db.Exec(sqlStatement, faqID)
+// deleteFAQ moves an FAQ to the trash. It is purged for good by purgeFAQs.
+func deleteFAQ(db dbtx, faqID int) error {
+	sqlStatement := `UPDATE faqs SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL;`
+	res, err := db.Exec(sqlStatement, faqID)
 	if err != nil {
 		logError(err)
 		return err
 	}
+	return expectRowsAffected(res, errFAQNotFound)
+}
 
-	sqlStatement = `DELETE FROM faqs WHERE id = $1;`
-	_, err = db.Exec(sqlStatement, faqID)
+func deleteFAQText(db dbtx, faqID int, localeCode string) error {
+	sqlStatement := `DELETE FROM faq_texts WHERE faq_id = $1 AND locale = $2;`
+	res, err := db.Exec(sqlStatement, faqID, localeCode)
 	if err != nil {
 		logError(err)
+		return err
 	}
-	return err
+	return expectRowsAffected(res, errFAQTextNotFound)
+}
+
+// nullInt maps 0 to NULL.
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n != 0}
+}
+
+// expectRowsAffected returns notFound if the statement behind res did not
+// touch any row.
+func expectRowsAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		logError(err)
+		return err
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
 }
 
 func getAllFAQs(db *sql.DB) ([]FAQ, error) {
-	rows, err := db.Query("SELECT id FROM faqs ORDER BY id;")
+	rows, err := db.Query(`
+		SELECT faqs.id, faqs.category_id, faqs.position
+		FROM faqs
+		LEFT JOIN categories ON categories.id = faqs.category_id
+		WHERE faqs.deleted_at IS NULL
+		ORDER BY categories.position NULLS LAST, categories.id NULLS LAST, faqs.position, faqs.id;`)
 	if err != nil {
 		logError(err)
 		return nil, err
@@ -349,19 +602,14 @@ func getAllFAQs(db *sql.DB) ([]FAQ, error) {
 	faqs := []FAQ{}
 	for rows.Next() {
 		var id int
-		err = rows.Scan(&id)
+		var categoryID sql.NullInt64
+		var position int
+		err = rows.Scan(&id, &categoryID, &position)
 		if err != nil {
 			return nil, err
 		}
 
-		faq := FAQ{ID: id}
-		texts, err := getTextForFAQ(db, id)
-		if err != nil {
-			panic(err)
-		}
-
-		faq.Texts = texts
-		faqs = append(faqs, faq)
+		faqs = append(faqs, FAQ{ID: id, CategoryID: int(categoryID.Int64), Position: position})
 	}
 
 	err = rows.Err()
@@ -369,33 +617,77 @@ func getAllFAQs(db *sql.DB) ([]FAQ, error) {
 		return nil, err
 	}
 
+	err = addTexts(db, faqs)
+	if err != nil {
+		return nil, err
+	}
 	return faqs, nil
 }
 
 func getTextForFAQ(db *sql.DB, faqID int) ([]FAQText, error) {
-	rows, err := db.Query("SELECT id, locale, question, answer FROM faq_texts WHERE faq_id = $1;", faqID)
+	texts, err := getTextsForFAQs(db, []int{faqID})
+	if err != nil {
+		return nil, err
+	}
+	if texts[faqID] == nil {
+		return []FAQText{}, nil
+	}
+	return texts[faqID], nil
+}
+
+// getTextsForFAQs loads the texts of several FAQs in one query, by FAQ id.
+func getTextsForFAQs(db *sql.DB, faqIDs []int) (map[int][]FAQText, error) {
+	ids := make([]int64, len(faqIDs))
+	for i, id := range faqIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := db.Query(`
+		SELECT faq_id, locale, question, answer, version, source_version, published_question, published_answer, published_at
+		FROM faq_texts WHERE faq_id = ANY($1)
+		ORDER BY faq_id, id;`, pq.Array(ids))
 	if err != nil {
 		logError(err)
 		return nil, err
 	}
 	defer rows.Close()
-	texts := []FAQText{}
+	return scanFAQTexts(rows)
+}
+
+// scanFAQTexts reads rows of faq_id, locale, question, answer, version,
+// source_version and the published columns, by FAQ id.
+func scanFAQTexts(rows *sql.Rows) (map[int][]FAQText, error) {
+	texts := make(map[int][]FAQText)
 	for rows.Next() {
-		var id int
+		var faqID int
 		var localeCode string
 		var question string
 		var answer string
-		err = rows.Scan(&id, &localeCode, &question, &answer)
+		var version int
+		var sourceVersion sql.NullInt64
+		var publishedQuestion sql.NullString
+		var publishedAnswer sql.NullString
+		var publishedAt pq.NullTime
+		err := rows.Scan(&faqID, &localeCode, &question, &answer, &version, &sourceVersion, &publishedQuestion, &publishedAnswer, &publishedAt)
 		if err != nil {
 			logError(err)
 			return nil, err
 		}
-		texts = append(texts, FAQText{
+		text := FAQText{
 			Locale:   localeFromCode(localeCode),
-			Question: question, Answer: answer})
+			Question: question, Answer: answer,
+			Version:           version,
+			SourceVersion:     int(sourceVersion.Int64),
+			PublishedQuestion: publishedQuestion.String,
+			PublishedAnswer:   publishedAnswer.String,
+		}
+		if publishedAt.Valid {
+			text.PublishedAt = &publishedAt.Time
+		}
+		texts[faqID] = append(texts[faqID], text)
 	}
 
-	err = rows.Err()
+	err := rows.Err()
 	if err != nil {
 		return nil, err
 	}
@@ -403,82 +695,135 @@ func getTextForFAQ(db *sql.DB, faqID int) ([]FAQText, error) {
 	return texts, nil
 }
 
-func getFAQ(db *sql.DB, id int) (*FAQ, error) {
-	rows, err := db.Query("SELECT faq_texts.locale, faq_texts.question, faq_texts.answer FROM faqs, faq_texts WHERE faqs.id = $1 AND faq_texts.faq_id = $1;", id)
-	if err != nil {
-		logError(err)
-		return nil, err
+// addTexts sets the texts of all faqs with a single query.
+func addTexts(db *sql.DB, faqs []FAQ) error {
+	ids := make([]int, len(faqs))
+	for i := range faqs {
+		ids[i] = faqs[i].ID
 	}
-	defer rows.Close()
-	faq := FAQ{ID: id}
-	faq.Texts, err = getTextForFAQ(db, id)
+	texts, err := getTextsForFAQs(db, ids)
 	if err != nil {
-		logError(err)
-		return nil, err
+		return err
 	}
-	return &faq, nil
+	setTexts(faqs, texts)
+	return nil
 }
 
-func searchFAQs(db *sql.DB, lang string, query string) ([]FAQ, error) {
-	rows, err := db.Query(`
-		SELECT faq_texts.faq_id
-		FROM search_index
-		JOIN faq_texts ON search_index.id = faq_texts.id
-		WHERE document @@ plainto_tsquery('simple', $1)
-		AND faq_texts.locale = $2
-		ORDER BY ts_rank(document, plainto_tsquery('simple', $1)) DESC;`, query, lang)
-	if err != nil {
+// setTexts sets the texts of faqs from texts by FAQ id.
+func setTexts(faqs []FAQ, texts map[int][]FAQText) {
+	for i := range faqs {
+		faqs[i].Texts = texts[faqs[i].ID]
+		if faqs[i].Texts == nil {
+			faqs[i].Texts = []FAQText{}
+		}
+	}
+}
+
+func getFAQ(db *sql.DB, id int) (*FAQ, error) {
+	faq := FAQ{ID: id}
+	var categoryID sql.NullInt64
+	var deletedAt pq.NullTime
+	err := db.QueryRow("SELECT category_id, position, deleted_at FROM faqs WHERE id = $1;", id).Scan(&categoryID, &faq.Position, &deletedAt)
+	if err != nil && err != sql.ErrNoRows {
 		logError(err)
 		return nil, err
 	}
-	defer rows.Close()
-
-	faqs := []FAQ{}
-	for rows.Next() {
-		var id int
-		err = rows.Scan(&id)
-		if err != nil {
-			return nil, err
-		}
-
-		faq := FAQ{ID: id}
-		texts, err := getTextForFAQ(db, id)
-		if err != nil {
-			panic(err)
-		}
-
-		faq.Texts = texts
-		faqs = append(faqs, faq)
+	faq.CategoryID = int(categoryID.Int64)
+	if deletedAt.Valid {
+		faq.DeletedAt = &deletedAt.Time
 	}
 
-	err = rows.Err()
+	faq.Texts, err = getTextForFAQ(db, id)
 	if err != nil {
+		logError(err)
 		return nil, err
 	}
-
-	return faqs, nil
+	return &faq, nil
 }
 
 const internalError = "internal error"
 
 func writeJSON(w http.ResponseWriter, data interface{}) {
+	writeJSONWithStatus(w, http.StatusOK, data)
+}
+
+func writeJSONWithStatus(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
 	enc := json.NewEncoder(w)
 	enc.Encode(data)
 }
 
 func writeJSONErr(w http.ResponseWriter, statusCode int, errorText string) {
-	w.WriteHeader(statusCode)
-	writeJSON(w, Error{Error: errorText})
+	writeJSONWithStatus(w, statusCode, Error{Error: errorText})
 }
 
 func getFAQs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-	faqs, err := faqRepository.AllFAQs()
+	opts, err := parseListOptions(r)
+	if err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	localeCode, localized, err := requestedLocale(r)
+	if err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slug := strings.TrimSpace(r.FormValue("category"))
+	if len(slug) > 0 {
+		categories, err := faqRepository.AllCategories()
+		if err != nil {
+			writeJSONErr(w, http.StatusInternalServerError, internalError)
+			return
+		}
+		category := categoryBySlug(categories, slug)
+		if category == nil {
+			writeJSONErr(w, http.StatusNotFound, "category not found")
+			return
+		}
+		opts.CategoryID = category.ID
+	}
+	opts.Published = true
+	if localized {
+		opts.Locales = fallbackChain(localeCode)
+	}
+
+	faqs, total, err := faqRepository.ListFAQs(opts)
 	if err != nil {
 		writeJSONErr(w, http.StatusInternalServerError, internalError)
 		return
 	}
 
+	served := []string{}
+	for i := range faqs {
+		faqs[i] = *faqs[i].Published()
+		if localized {
+			faqs[i] = onlyLocale(faqs[i], localeCode)
+			for _, text := range faqs[i].Texts {
+				served = append(served, text.Locale.Code)
+			}
+		}
+	}
+	if localized {
+		setContentLanguage(w, served, localeCode)
+	}
+	setVaryPickedLanguage(w)
+	setPaginationHeaders(w, r, opts.Limit, opts.Offset, total)
+
+	if fields != nil {
+		selected := []map[string]interface{}{}
+		for _, faq := range faqs {
+			selected = append(selected, selectFields(faq, fields))
+		}
+		writeJSON(w, selected)
+		return
+	}
 	writeJSON(w, faqs)
 }
 
@@ -486,9 +831,7 @@ func getSingleFAQ(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 	idStr := ps.ByName("id")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		writeJSON(w, Error{Error: "faq not found"})
-		// http.Error(w, "faq not found", http.StatusNotFound)
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
 		return
 	}
 
@@ -498,12 +841,37 @@ func getSingleFAQ(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 		return
 	}
 
-	if len(faq.Texts) == 0 {
+	faq = faq.Published()
+	if len(faq.Texts) == 0 || faq.IsDeleted() {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+
+	localeCode, ok, err := requestedLocale(r)
+	if err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	setVaryPickedLanguage(w)
+	if !ok {
+		writeJSON(w, faq)
+		return
+	}
+	text := faq.ResolvedText(localeCode)
+	if len(text.Question) == 0 {
 		writeJSONErr(w, http.StatusNotFound, "faq not found")
 		return
 	}
+	localized := *faq
+	localized.Texts = []FAQText{text}
+	setContentLanguage(w, []string{text.Locale.Code}, localeCode)
+	writeJSON(w, localizedFAQ{FAQ: localized, ResolvedLocale: text.Locale.Code})
+}
 
-	writeJSON(w, faq)
+// localizedFAQ is an FAQ with only the text served for a requested locale.
+type localizedFAQ struct {
+	FAQ
+	ResolvedLocale string `json:"resolved_locale"`
 }
 
 func getSearchFAQs(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -512,21 +880,48 @@ func getSearchFAQs(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 		writeJSONErr(w, http.StatusBadRequest, "query param empty")
 		return
 	}
-	lang := strings.TrimSpace(r.FormValue("lang"))
-	if len(lang) == 0 {
-		writeJSONErr(w, http.StatusBadRequest, "lang param empty")
-		return
-	}
-	accept := r.Header.Get("Accept-Language")
-	langTag, _ := language.MatchStrings(languageMatcher, lang, accept)
+	localeCode, _ := negotiateLocale(r)
 
-	faqs, err := faqRepository.SearchFAQs(langTag.String(), query)
+	opts, err := parseListOptions(r)
 	if err != nil {
-		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	writeJSON(w, faqs)
+	resp := SearchResponse{Fuzzy: r.FormValue("fuzzy") == "true"}
+	if !resp.Fuzzy {
+		resp.Results, err = searchWithFallbacks(faqRepository.SearchFAQs, localeCode, query)
+		if err != nil {
+			writeJSONErr(w, http.StatusInternalServerError, internalError)
+			return
+		}
+		// Fall back to fuzzy matching, the query is probably misspelled
+		resp.Fuzzy = len(resp.Results) == 0
+	}
+	if resp.Fuzzy {
+		resp.Results, err = searchWithFallbacks(faqRepository.FuzzySearchFAQs, localeCode, query)
+		if err != nil {
+			writeJSONErr(w, http.StatusInternalServerError, internalError)
+			return
+		}
+		resp.DidYouMean, err = faqRepository.SearchSuggestion(localeCode, query)
+		if err != nil {
+			writeJSONErr(w, http.StatusInternalServerError, internalError)
+			return
+		}
+	}
+	total := len(resp.Results)
+	start, end := pageBounds(total, opts.Limit, opts.Offset)
+	resp.Results = resp.Results[start:end]
+	served := []string{}
+	for _, result := range resp.Results {
+		served = append(served, result.ResolvedLocale)
+	}
+	setContentLanguage(w, served, localeCode)
+	setVaryLanguage(w)
+	setPaginationHeaders(w, r, opts.Limit, opts.Offset, total)
+
+	writeJSON(w, resp)
 }
 
 type FAQIndexPageData struct {
@@ -539,8 +934,10 @@ type FAQPageData struct {
 	PageTitle string
 	// MenuBar   []MenuEntry
 	// Locales   []Locale
-	FAQ  *FAQ
-	Text FAQText
+	FAQ     *FAQ
+	Text    FAQText // In the locale actually served
+	Locale  Locale  // Requested locale
+	Preview bool    // Renders the drafts for admins
 }
 
 type FAQsPageData struct {
@@ -548,6 +945,7 @@ type FAQsPageData struct {
 	MenuBar   []MenuEntry
 	Locales   []Locale
 	FAQs      []FAQ
+	Sections  []FAQSection
 }
 
 type FAQsNewPageData struct {
@@ -557,22 +955,46 @@ type FAQsNewPageData struct {
 }
 
 type FAQEditPageData struct {
-	PageTitle string
-	MenuBar   []MenuEntry
-	Locales   []Locale
-	FAQ       FAQ
+	PageTitle     string
+	MenuBar       []MenuEntry
+	Locales       []Locale
+	FAQ           FAQ
+	Categories    []Category
+	RetentionDays int
+	Conflict      *TextConflict
+}
+
+// TextConflict is a save rejected because the text had been saved by someone
+// else in the meantime. The diffs go from the saved text to the rejected one.
+type TextConflict struct {
+	LocaleCode string
+	Question   []DiffChunk
+	Answer     []DiffChunk
 }
 
 type LocalesPageData struct {
 	PageTitle string
 	MenuBar   []MenuEntry
-	Locales   []Locale
+	Locales   []LocaleSetting // Including disabled locales
+
+	// Translation completeness of the FAQs
+	FAQs               []FAQ
+	TranslationLocales []Locale                      // Locales besides the default locale
+	Summaries          map[string]TranslationSummary // By locale code
 }
 
 var tmplAdminFAQs *template.Template
 var tmplAdminFAQsNew *template.Template
 var tmplAdminFAQEdit *template.Template
 var tmplAdminLocales *template.Template
+var tmplAdminCategories *template.Template
+var tmplAdminCategoryEdit *template.Template
+var tmplAdminRevisions *template.Template
+var tmplAdminTrash *template.Template
+var tmplAdminImport *template.Template
+var tmplAdminTranslations *template.Template
+var tmplAdminUsers *template.Template
+var tmplAdminAudit *template.Template
 var tmplAdminLogin *template.Template
 
 var tmplFAQ *template.Template
@@ -584,6 +1006,14 @@ func init() {
 	tmplAdminFAQsNew = template.Must(template.ParseFiles(layoutTemplatePath, templPath("faqs_new.html")))
 	tmplAdminFAQEdit = template.Must(template.ParseFiles(layoutTemplatePath, templPath("faqs_edit.html")))
 	tmplAdminLocales = template.Must(template.ParseFiles(layoutTemplatePath, templPath("locales.html")))
+	tmplAdminCategories = template.Must(template.ParseFiles(layoutTemplatePath, templPath("categories.html")))
+	tmplAdminCategoryEdit = template.Must(template.ParseFiles(layoutTemplatePath, templPath("categories_edit.html")))
+	tmplAdminRevisions = template.Must(template.ParseFiles(layoutTemplatePath, templPath("revisions.html")))
+	tmplAdminTrash = template.Must(template.ParseFiles(layoutTemplatePath, templPath("trash.html")))
+	tmplAdminImport = template.Must(template.ParseFiles(layoutTemplatePath, templPath("import.html")))
+	tmplAdminTranslations = template.Must(template.ParseFiles(layoutTemplatePath, templPath("translations.html")))
+	tmplAdminUsers = template.Must(template.ParseFiles(layoutTemplatePath, templPath("users.html")))
+	tmplAdminAudit = template.Must(template.ParseFiles(layoutTemplatePath, templPath("audit.html")))
 	tmplAdminLogin = template.Must(template.ParseFiles(templPath("login.html")))
 
 	tmplFAQ = template.Must(template.ParseFiles(templPath("faq.html")))
@@ -613,7 +1043,7 @@ func mustExecuteTemplate(tmpl *template.Template, wr io.Writer, data interface{}
 	}
 }
 
-func createJWT(expiry time.Time) string {
+func createJWT(subject string, expiry time.Time) string {
 	key := []byte(jwtKey)
 	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: key}, (&jose.SignerOptions{}).WithType("JWT"))
 	if err != nil {
@@ -621,7 +1051,7 @@ func createJWT(expiry time.Time) string {
 	}
 
 	claims := jwt.Claims{
-		Subject: "admin",
+		Subject: subject,
 		// Issuer:    "issuer",
 		// NotBefore: jwt.NewNumericDate(time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)),
 		Expiry: jwt.NewNumericDate(expiry),
@@ -662,42 +1092,42 @@ const (
 )
 
 func isValidAdminJWT(rawJWTToken string) bool {
+	_, ok := adminJWTSubject(rawJWTToken)
+	return ok
+}
+
+// adminJWTSubject returns the subject of a valid admin JWT, the email of
+// the logged in user.
+func adminJWTSubject(rawJWTToken string) (string, bool) {
 	tok, err := jwt.ParseSigned(rawJWTToken)
 	if err != nil {
-		return false
+		return "", false
 	}
 
 	key := []byte(jwtKey)
 
 	cl := jwt.Claims{}
 	if err := tok.Claims(key, &cl); err != nil {
-		return false
+		return "", false
 	}
 
 	err = cl.ValidateWithLeeway(jwt.Expected{
-		Subject: "admin",
-		Time:    time.Now(),
+		Time: time.Now(),
 		// Issuer:  "issuer",
 	}, leeway)
 	if err != nil {
-		return false
+		return "", false
 	}
 
-	return true
+	return cl.Subject, true
 }
 
 func getAdmin(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	http.Redirect(w, r, "/admin/faqs", http.StatusFound)
 }
 
-func loggedInAsAdmin(r *http.Request) bool {
-	authCookie, err := r.Cookie(authCookieName)
-	if err != nil {
-		return false
-	}
-
-	return isValidAdminJWT(authCookie.Value)
-}
+// apiKeyAuthor is recorded as the author of changes made with the API key.
+const apiKeyAuthor = "api"
 
 func redirectToAdminLogin(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/admin/login", http.StatusFound)
@@ -708,10 +1138,15 @@ func getAdminFAQs(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 	if err != nil {
 		panic(err)
 	}
+	categories, err := faqRepository.AllCategories()
+	if err != nil {
+		panic(err)
+	}
 	data := FAQsPageData{
 		PageTitle: "Admin / FAQs",
 		MenuBar:   menuBar("FAQs"),
 		FAQs:      faqs,
+		Sections:  faqSections(faqs, categories),
 	}
 	mustExecuteTemplate(tmplAdminFAQs, w, data)
 }
@@ -743,6 +1178,12 @@ func getAdminFAQsEdit(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 		panic(err)
 	}
 
+	mustExecuteTemplate(tmplAdminFAQEdit, w, faqEditPageData(id))
+}
+
+// faqEditPageData returns the edit page of an FAQ with a form for each
+// supported locale.
+func faqEditPageData(id int) FAQEditPageData {
 	faq, err := faqRepository.FAQById(id)
 	if err != nil {
 		panic(err)
@@ -754,7 +1195,7 @@ func getAdminFAQsEdit(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 	}
 
 	faq.Texts = []FAQText{}
-	for _, loc := range supportedLocales {
+	for _, loc := range getSupportedLocales() {
 		t := FAQText{Locale: loc}
 		t2, ok := m[loc.Code]
 		if ok {
@@ -764,12 +1205,18 @@ func getAdminFAQsEdit(w http.ResponseWriter, r *http.Request, ps httprouter.Para
 		faq.Texts = append(faq.Texts, t)
 	}
 
-	data := FAQEditPageData{
-		PageTitle: "Admin / Edit FAQ",
-		MenuBar:   menuBar("FAQs"),
-		FAQ:       *faq,
+	categories, err := faqRepository.AllCategories()
+	if err != nil {
+		panic(err)
+	}
+
+	return FAQEditPageData{
+		PageTitle:     "Admin / Edit FAQ",
+		MenuBar:       menuBar("FAQs"),
+		FAQ:           *faq,
+		Categories:    categories,
+		RetentionDays: trashRetentionDays,
 	}
-	mustExecuteTemplate(tmplAdminFAQEdit, w, data)
 }
 
 type faqForm struct {
@@ -777,6 +1224,7 @@ type faqForm struct {
 	localeCode string
 	question   string
 	answer     string
+	version    string
 }
 
 func postAdminFAQsUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
@@ -785,18 +1233,34 @@ func postAdminFAQsUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.P
 		localeCode: r.FormValue("localeCode"),
 		question:   r.FormValue("question"),
 		answer:     r.FormValue("answer"),
+		version:    r.FormValue("version"),
+	}
+
+	if !canEditLocale(r, form.localeCode) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
 	}
 
 	loc := Locale{Code: form.localeCode}
-	text := FAQText{Question: form.question, Answer: form.answer, Locale: loc}
+	text := FAQText{Question: form.question, Answer: form.answer, Locale: loc, Author: currentAuthor(r)}
 
 	faqID, err := strconv.Atoi(form.faqID)
 	if err != nil {
 		panic(err)
 	}
+	if len(form.version) > 0 {
+		text.Version, err = strconv.Atoi(form.version)
+		if err != nil {
+			panic(err)
+		}
+	}
 
 	err = faqRepository.SaveFAQText(faqID, &text)
-	faqRepository.UpdateSearchIndex()
+	if err == errVersionConflict {
+		w.WriteHeader(http.StatusConflict)
+		mustExecuteTemplate(tmplAdminFAQEdit, w, conflictPageData(faqID, text))
+		return
+	}
 	if err != nil {
 		http.Error(w, internalError, http.StatusInternalServerError)
 	} else {
@@ -805,6 +1269,27 @@ func postAdminFAQsUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.P
 	}
 }
 
+// conflictPageData returns the edit page showing how the rejected text
+// differs from the saved one. Its form holds the rejected text, based on the
+// saved version, so saving again overwrites the other edit.
+func conflictPageData(faqID int, rejected FAQText) FAQEditPageData {
+	data := faqEditPageData(faqID)
+	for i := range data.FAQ.Texts {
+		saved := &data.FAQ.Texts[i]
+		if saved.Locale.Code != rejected.Locale.Code {
+			continue
+		}
+		data.Conflict = &TextConflict{
+			LocaleCode: saved.Locale.Code,
+			Question:   diffWords(saved.Question, rejected.Question),
+			Answer:     diffWords(saved.Answer, rejected.Answer),
+		}
+		saved.Question = rejected.Question
+		saved.Answer = rejected.Answer
+	}
+	return data
+}
+
 func postAdminFAQsDelete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	form := faqForm{
 		faqID: r.FormValue("faqID"),
@@ -815,8 +1300,7 @@ func postAdminFAQsDelete(w http.ResponseWriter, r *http.Request, ps httprouter.P
 		panic(err)
 	}
 
-	err = faqRepository.DeleteFAQ(faqID)
-	faqRepository.UpdateSearchIndex()
+	err = faqRepository.DeleteFAQ(faqID, currentAuthor(r))
 	if err != nil {
 		http.Error(w, internalError, http.StatusInternalServerError)
 	} else {
@@ -832,17 +1316,10 @@ func postAdminFAQsCreate(w http.ResponseWriter, r *http.Request, ps httprouter.P
 	}
 
 	loc := Locale{Code: form.localeCode}
-	text := FAQText{Question: form.question, Answer: form.answer, Locale: loc}
+	text := FAQText{Question: form.question, Answer: form.answer, Locale: loc, Author: currentAuthor(r)}
 
-	faq, err := faqRepository.CreateFAQ()
-	faqRepository.UpdateSearchIndex()
-	if err != nil {
-		http.Error(w, internalError, http.StatusInternalServerError)
-		return
-	}
-
-	err = faqRepository.SaveFAQText(faq.ID, &text)
-	faqRepository.UpdateSearchIndex()
+	faq := FAQ{Texts: []FAQText{text}}
+	err := faqRepository.CreateFAQWithTexts(&faq)
 	if err != nil {
 		http.Error(w, internalError, http.StatusInternalServerError)
 	} else {
@@ -851,8 +1328,10 @@ func postAdminFAQsCreate(w http.ResponseWriter, r *http.Request, ps httprouter.P
 	}
 }
 
+// updateSearchIndex rebuilds the vocabulary used for search suggestions. The
+// full-text index itself is kept up to date by a trigger on faq_texts.
 func updateSearchIndex(db *sql.DB) error {
-	sqlStatement := `REFRESH MATERIALIZED VIEW search_index;`
+	sqlStatement := `REFRESH MATERIALIZED VIEW CONCURRENTLY search_words;`
 	_, err := db.Exec(sqlStatement)
 	if err != nil {
 		logError(err)
@@ -861,10 +1340,25 @@ func updateSearchIndex(db *sql.DB) error {
 }
 
 func getAdminLocales(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqs, err := faqRepository.AllFAQs()
+	if err != nil {
+		panic(err)
+	}
+	settings, err := faqRepository.AllLocales()
+	if err != nil {
+		panic(err)
+	}
 	data := LocalesPageData{
-		PageTitle: "Admin / Languages",
-		MenuBar:   menuBar("Languages"),
-		Locales:   supportedLocales,
+		PageTitle:          "Admin / Languages",
+		MenuBar:            menuBar("Languages"),
+		Locales:            sortedLocaleSettings(settings),
+		FAQs:               faqs,
+		TranslationLocales: []Locale{},
+		Summaries:          make(map[string]TranslationSummary),
+	}
+	for _, s := range translationSummaries(faqs) {
+		data.TranslationLocales = append(data.TranslationLocales, s.Locale)
+		data.Summaries[s.Locale.Code] = s
 	}
 	mustExecuteTemplate(tmplAdminLocales, w, data)
 }
@@ -873,8 +1367,9 @@ func postAdminLogin(w http.ResponseWriter, r *http.Request, ps httprouter.Params
 	email := r.FormValue("email")
 	password := r.FormValue("password")
 
-	if isAdminFunc(email, password) {
-		cookie := createAuthCookie()
+	user, err := authenticateFunc(email, password)
+	if err == nil {
+		cookie := createAuthCookie(user.Email)
 		http.SetCookie(w, &cookie)
 		http.Redirect(w, r, "/admin/faqs", http.StatusFound)
 	} else {
@@ -882,17 +1377,8 @@ func postAdminLogin(w http.ResponseWriter, r *http.Request, ps httprouter.Params
 	}
 }
 
-func init() {
-	isAdminFunc = isAdminPassword
-}
-
-var isAdminFunc func(string, string) bool
-
-func isAdminPassword(email string, password string) bool {
-	if email != "admin" {
-		return false
-	}
-	err := bcrypt.CompareHashAndPassword([]byte(adminPasswordHash), []byte(password))
+func checkPassword(passwordHash string, password string) bool {
+	err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
 	return err == nil
 }
 
@@ -906,13 +1392,13 @@ const (
 	adminSessionDuration = 24 * time.Hour
 )
 
-func createAuthCookie() http.Cookie {
+func createAuthCookie(email string) http.Cookie {
 	expires := time.Now().Add(adminSessionDuration)
 
 	// https://infosec.mozilla.org/guidelines/web_security#cookies
 	ck := http.Cookie{
 		Name:     authCookieName,
-		Value:    createJWT(expires),
+		Value:    createJWT(email, expires),
 		Path:     "/admin",
 		Expires:  expires,
 		Secure:   !httpAllowed(),
@@ -929,8 +1415,52 @@ func main() {
 	var err error
 	faqRepository, err = NewDB(databaseURL)
 	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, isPostgres := faqRepository.(*DB)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if !isPostgres {
+			log.Fatal("migrations are for Postgres only")
+		}
+		if err = runMigrateCommand(db.DB, os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if isPostgres {
+		n, err := migrateUp(db.DB)
+		if err != nil {
+			log.Panic(err)
+		}
+		if n > 0 {
+			log.Printf("applied %d migrations", n)
+		}
+	}
+	if err = loadLocales(faqRepository); err != nil {
+		log.Panic(err)
+	}
+	if err = seedUsers(faqRepository); err != nil {
 		log.Panic(err)
 	}
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "export":
+			err = runExportCommand(faqRepository, os.Args[2:], os.Stdout)
+		case "import":
+			err = runImportCommand(faqRepository, os.Args[2:], os.Stdout)
+		default:
+			err = fmt.Errorf("unknown command: %v", os.Args[1])
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	go purgeTrashPeriodically(faqRepository, trashPurgeInterval)
+	go updateSearchIndexPeriodically(faqRepository, searchIndexInterval)
+	go reloadLocalesPeriodically(faqRepository, localesReloadInterval)
 
 	router := buildRouter()
 	router.ServeFiles("/static/*filepath", http.Dir("public/static/"))
@@ -953,51 +1483,119 @@ func buildRouter() *httprouter.Router {
 	router.GET("/api/faqs", requireHTTPS(requireAPIAuth(getFAQs)))
 	router.GET("/api/faqs/:id", requireHTTPS(requireAPIAuth(getSingleFAQ)))
 	router.GET("/api/search-faqs", requireHTTPS(requireAPIAuth(getSearchFAQs)))
-
-	router.GET("/admin", requireHTTPS(adminPassword(getAdmin)))
-	router.GET("/admin/faqs", requireHTTPS(adminPassword(getAdminFAQs)))
-	router.GET("/admin/locales", requireHTTPS(adminPassword(getAdminLocales)))
-	router.GET("/admin/faqs/edit/:id", requireHTTPS(adminPassword(getAdminFAQsEdit)))
-	router.GET("/admin/faqs/new", requireHTTPS(adminPassword(getAdminFAQsNew)))
-	router.POST("/admin/faqs/update", requireHTTPS(adminPassword(postAdminFAQsUpdate)))
-	router.POST("/admin/faqs/create", requireHTTPS(adminPassword(postAdminFAQsCreate)))
-	router.POST("/admin/faqs/delete", requireHTTPS(adminPassword(postAdminFAQsDelete)))
+	router.GET("/api/categories", requireHTTPS(requireAPIAuth(getCategories)))
+	router.GET("/api/locales", requireHTTPS(requireAPIAuth(getAPILocales)))
+	router.POST("/api/locales", requireHTTPS(requireAPIRole(roleAdmin, postAPILocale)))
+	router.PUT("/api/locales/:code", requireHTTPS(requireAPIRole(roleAdmin, putAPILocale)))
+	router.POST("/api/faqs", requireHTTPS(requireAPIRole(roleEditor, postAPIFAQ)))
+	router.DELETE("/api/faqs/:id", requireHTTPS(requireAPIRole(roleEditor, deleteAPIFAQ)))
+	router.POST("/api/faqs/:id/restore", requireHTTPS(requireAPIRole(roleEditor, postAPIFAQRestore)))
+	router.GET("/api/trash", requireHTTPS(requireAPIAuth(getAPITrash)))
+	router.GET("/api/export", requireHTTPS(requireAPIAuth(getAPIExport)))
+	router.POST("/api/import", requireHTTPS(requireAPIRole(roleEditor, postAPIImport)))
+	router.GET("/api/translations/:locale/:format", requireHTTPS(requireAPIAuth(getAPITranslations)))
+	router.GET("/api/translation-status", requireHTTPS(requireAPIAuth(getAPITranslationStatus)))
+	router.GET("/api/translations.csv", requireHTTPS(requireAPIAuth(getAPITranslationsCSV)))
+	router.POST("/api/translations/:format", requireHTTPS(requireAPIRole(roleEditor, postAPITranslations)))
+	router.PUT("/api/faqs/:id/texts", requireHTTPS(requireAPIRole(roleEditor, putAPIFAQTexts)))
+	router.PUT("/api/faqs/:id/texts/:locale", requireHTTPS(requireAPIRole(roleEditor, putAPIFAQText)))
+	router.POST("/api/faqs/:id/texts/:locale/publish", requireHTTPS(requireAPIRole(roleEditor, postAPIFAQTextPublish)))
+	router.GET("/api/faqs/:id/texts/:locale/revisions", requireHTTPS(requireAPIAuth(getAPIFAQTextRevisions)))
+	router.GET("/api/faqs/:id/texts/:locale/diff", requireHTTPS(requireAPIAuth(getAPIFAQTextDiff)))
+	router.POST("/api/faqs/:id/texts/:locale/revisions/:revision/restore", requireHTTPS(requireAPIRole(roleEditor, postAPIFAQTextRevisionRestore)))
+	router.DELETE("/api/faqs/:id/texts/:locale", requireHTTPS(requireAPIRole(roleEditor, deleteAPIFAQText)))
+
+	router.GET("/admin", requireHTTPS(requireRole(roleViewer, getAdmin)))
+	router.GET("/admin/faqs", requireHTTPS(requireRole(roleViewer, getAdminFAQs)))
+	router.GET("/admin/locales", requireHTTPS(requireRole(roleViewer, getAdminLocales)))
+	router.POST("/admin/locales/create", requireHTTPS(requireRole(roleAdmin, postAdminLocalesCreate)))
+	router.POST("/admin/locales/update", requireHTTPS(requireRole(roleAdmin, postAdminLocalesUpdate)))
+	router.POST("/admin/locales/move", requireHTTPS(requireRole(roleAdmin, postAdminLocalesMove)))
+	router.GET("/admin/faqs/edit/:id", requireHTTPS(requireRole(roleViewer, getAdminFAQsEdit)))
+	router.GET("/admin/faqs/new", requireHTTPS(requireRole(roleEditor, getAdminFAQsNew)))
+	router.POST("/admin/faqs/update", requireHTTPS(requireRole(roleTranslator, postAdminFAQsUpdate)))
+	router.POST("/admin/faqs/create", requireHTTPS(requireRole(roleEditor, postAdminFAQsCreate)))
+	router.POST("/admin/faqs/delete", requireHTTPS(requireRole(roleEditor, postAdminFAQsDelete)))
+	router.POST("/admin/faqs/publish", requireHTTPS(requireRole(roleEditor, postAdminFAQsPublish)))
+	router.GET("/admin/faqs/revisions/:id/:locale", requireHTTPS(requireRole(roleViewer, getAdminFAQsRevisions)))
+	router.POST("/admin/faqs/revisions/restore", requireHTTPS(requireRole(roleTranslator, postAdminFAQsRevisionsRestore)))
+	router.GET("/admin/faqs/preview/:locale/:id", requireHTTPS(requireRole(roleViewer, getAdminFAQsPreview)))
+	router.POST("/admin/faqs/move", requireHTTPS(requireRole(roleEditor, postAdminFAQsMove)))
+	router.POST("/admin/faqs/category", requireHTTPS(requireRole(roleEditor, postAdminFAQsCategory)))
+	router.GET("/admin/trash", requireHTTPS(requireRole(roleViewer, getAdminTrash)))
+	router.POST("/admin/trash/restore", requireHTTPS(requireRole(roleEditor, postAdminTrashRestore)))
+	router.POST("/admin/trash/purge", requireHTTPS(requireRole(roleEditor, postAdminTrashPurge)))
+	router.GET("/admin/import", requireHTTPS(requireRole(roleViewer, getAdminImport)))
+	router.POST("/admin/import", requireHTTPS(requireRole(roleEditor, postAdminImport)))
+	router.GET("/admin/export", requireHTTPS(requireRole(roleViewer, getAdminExport)))
+	router.GET("/admin/translations", requireHTTPS(requireRole(roleViewer, getAdminTranslations)))
+	router.GET("/admin/translations/:locale/:format", requireHTTPS(requireRole(roleViewer, getAdminTranslationsFile)))
+	router.GET("/admin/translations.csv", requireHTTPS(requireRole(roleViewer, getAdminTranslationsCSV)))
+	router.POST("/admin/translations/import", requireHTTPS(requireRole(roleTranslator, postAdminTranslationsImport)))
+	router.GET("/admin/categories", requireHTTPS(requireRole(roleViewer, getAdminCategories)))
+	router.GET("/admin/categories/edit/:id", requireHTTPS(requireRole(roleViewer, getAdminCategoriesEdit)))
+	router.POST("/admin/categories/create", requireHTTPS(requireRole(roleEditor, postAdminCategoriesCreate)))
+	router.POST("/admin/categories/update", requireHTTPS(requireRole(roleEditor, postAdminCategoriesUpdate)))
+	router.POST("/admin/categories/delete", requireHTTPS(requireRole(roleEditor, postAdminCategoriesDelete)))
+	router.POST("/admin/categories/move", requireHTTPS(requireRole(roleEditor, postAdminCategoriesMove)))
+	router.GET("/admin/users", requireHTTPS(requireRole(roleAdmin, getAdminUsers)))
+	router.POST("/admin/users/create", requireHTTPS(requireRole(roleAdmin, postAdminUsersCreate)))
+	router.POST("/admin/users/update", requireHTTPS(requireRole(roleAdmin, postAdminUsersUpdate)))
+	router.POST("/admin/users/delete", requireHTTPS(requireRole(roleAdmin, postAdminUsersDelete)))
+	router.GET("/admin/audit", requireHTTPS(requireRole(roleAdmin, getAdminAudit)))
 	router.GET("/admin/login", requireHTTPS(getAdminLogin))
 	router.POST("/admin/login", requireHTTPS(postAdminLogin))
 
 	return router
 }
 
-func adminPassword(h httprouter.Handle) httprouter.Handle {
-	if os.Getenv("ADMIN_PASSWORD") == "no-admin-password-required" {
-		return h
-	}
+const apiKeyHeader = "Authorization"
 
-	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-		if !loggedInAsAdmin(r) {
-			redirectToAdminLogin(w, r)
-			return
-		} else {
-			h(w, r, ps)
-		}
+type contextKey string
+
+// apiUserKey holds the email of the user an API request authenticated as.
+const apiUserKey contextKey = "apiUser"
+
+// apiAuthor returns the email of the user an API request authenticated as,
+// or apiKeyAuthor for requests made with the API key.
+func apiAuthor(r *http.Request) string {
+	if email, ok := r.Context().Value(apiUserKey).(string); ok {
+		return email
 	}
+	return apiKeyAuthor
 }
 
-const apiKeyHeader = "Authorization"
-
 func requireAPIAuth(h httprouter.Handle) httprouter.Handle {
-	if os.Getenv("API_KEY") == "no-api-key-required" {
-		return h
-	}
+	return requireAPIRole(roleViewer, h)
+}
+
+// requireAPIRole lets requests with the API key through, as well as those of
+// users with at least the given role who send their email and password with
+// HTTP Basic auth.
+func requireAPIRole(role string, h httprouter.Handle) httprouter.Handle {
+	apiKeyRequired := os.Getenv("API_KEY") != "no-api-key-required"
 
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-		authHeaderOK := r.Header.Get(apiKeyHeader) == apiKey
-		if !authHeaderOK {
+		email, password, ok := r.BasicAuth()
+		if !ok {
+			if apiKeyRequired && r.Header.Get(apiKeyHeader) != apiKey {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h(w, r, ps)
+			return
+		}
+
+		user, err := authenticateFunc(email, password)
+		if err != nil {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
-		} else {
-			h(w, r, ps)
 		}
+		if !user.HasRole(role) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h(w, r.WithContext(context.WithValue(r.Context(), apiUserKey, user.Email)), ps)
 	}
 }
 
@@ -1020,58 +1618,10 @@ func requireHTTPS(h httprouter.Handle) httprouter.Handle {
 	}
 }
 
-var languageMatcher language.Matcher
-var supportedLocales []Locale
-
 func init() {
-	locales := strings.Split(os.Getenv("SUPPORTED_LOCALES"), ",")
-	supportedLocales = []Locale{}
-	for _, code := range locales {
-		locale := localeFromCode(code)
-		if len(locale.NameEnglish) == 0 {
-			panic(fmt.Errorf("unsupported locale: %v", code))
-		}
-		supportedLocales = append(supportedLocales, locale)
-	}
-	if len(supportedLocales) == 0 {
-		panic("SUPPORTED_LOCALES missing or wrong")
-	}
-	languageMatcher = language.NewMatcher(parseLocales(locales))
-
 	rand.Seed(time.Now().UnixNano())
 }
 
-func localeFromCode(languageCode string) Locale {
-	tag, err := language.Parse(languageCode)
-	if err != nil {
-		return Locale{Code: languageCode}
-	}
-	en := display.English.Tags()
-	locale := Locale{
-		Code:        languageCode,
-		NameEnglish: en.Name(tag),
-		NameLocal:   display.Self.Name(tag),
-	}
-	return locale
-}
-
-func getDefaultLocale() Locale {
-	return supportedLocales[0]
-}
-
-func parseLocales(locales []string) []language.Tag {
-	supported := []language.Tag{}
-	for _, loc := range locales {
-		tag, err := language.Parse(loc)
-		if err != nil {
-			panic(nil)
-		}
-		supported = append(supported, tag)
-	}
-
-	return supported
-}
-
 func logError(e error) {
 	log.Println(e)
 }
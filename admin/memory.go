@@ -0,0 +1,767 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryDatabaseURL selects MemoryDB instead of Postgres. All data is lost
+// when the server stops.
+const memoryDatabaseURL = "memory://"
+
+// MemoryDB is an FAQRepository keeping everything in memory, for development
+// and tests without Postgres. Search matches words literally, there is no
+// stemming, fuzzy matching or suggestions.
+type MemoryDB struct {
+	mu         sync.Mutex
+	lastID     int
+	faqs       map[int]*FAQ
+	revisions  []FAQTextRevision
+	categories []Category
+	locales    []LocaleSetting
+	users      []User
+	audit      []AuditEntry
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{faqs: make(map[int]*FAQ)}
+}
+
+func (m *MemoryDB) nextID() int {
+	m.lastID++
+	return m.lastID
+}
+
+// addAuditEntry records a change. The caller must hold m.mu.
+func (m *MemoryDB) addAuditEntry(author string, action string, details string) {
+	entry := AuditEntry{ID: len(m.audit) + 1, Author: author, Action: action, Details: details, CreatedAt: time.Now()}
+	m.audit = append(m.audit, entry)
+}
+
+func (m *MemoryDB) AuditLog(limit int) ([]AuditEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := []AuditEntry{}
+	for i := len(m.audit) - 1; i >= 0 && len(entries) < limit; i-- {
+		entries = append(entries, m.audit[i])
+	}
+	return entries, nil
+}
+
+// copyFAQ returns a copy of faq that callers may modify.
+func copyFAQ(faq *FAQ) FAQ {
+	c := *faq
+	c.Texts = append([]FAQText{}, faq.Texts...)
+	if faq.DeletedAt != nil {
+		deletedAt := *faq.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	return c
+}
+
+func (m *MemoryDB) categoryPosition(categoryID int) (int, bool) {
+	for _, c := range m.categories {
+		if c.ID == categoryID {
+			return c.Position, true
+		}
+	}
+	return 0, false
+}
+
+// sortedFAQs returns copies of the FAQs matching keep in the order of
+// getAllFAQs: by category, uncategorized last, then by position.
+func (m *MemoryDB) sortedFAQs(keep func(*FAQ) bool) []FAQ {
+	faqs := []FAQ{}
+	for _, faq := range m.faqs {
+		if keep(faq) {
+			faqs = append(faqs, copyFAQ(faq))
+		}
+	}
+	sort.Slice(faqs, func(i, j int) bool {
+		a, b := faqs[i], faqs[j]
+		if a.CategoryID != b.CategoryID {
+			posA, okA := m.categoryPosition(a.CategoryID)
+			posB, okB := m.categoryPosition(b.CategoryID)
+			if okA != okB {
+				return okA
+			}
+			if posA != posB {
+				return posA < posB
+			}
+			return a.CategoryID < b.CategoryID
+		}
+		if a.Position != b.Position {
+			return a.Position < b.Position
+		}
+		return a.ID < b.ID
+	})
+	return faqs
+}
+
+func (m *MemoryDB) AllFAQs() ([]FAQ, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sortedFAQs(func(faq *FAQ) bool { return !faq.IsDeleted() }), nil
+}
+
+func (m *MemoryDB) ListFAQs(opts ListOptions) ([]FAQ, int, error) {
+	faqs, _ := m.AllFAQs()
+	page, total := listFAQsFrom(faqs, opts)
+	return page, total, nil
+}
+
+func (m *MemoryDB) FAQById(id int) (*FAQ, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	faq, ok := m.faqs[id]
+	if !ok {
+		return &FAQ{ID: id, Texts: []FAQText{}}, nil
+	}
+	c := copyFAQ(faq)
+	return &c, nil
+}
+
+// searchWords returns a case insensitive regexp for each word of query.
+func searchWords(query string) []*regexp.Regexp {
+	res := []*regexp.Regexp{}
+	for _, word := range strings.Fields(query) {
+		res = append(res, regexp.MustCompile(`(?i)`+regexp.QuoteMeta(word)))
+	}
+	return res
+}
+
+func highlight(s string, words []*regexp.Regexp) string {
+	for _, re := range words {
+		s = re.ReplaceAllString(s, highlightStart+"$0"+highlightStop)
+	}
+	return markHighlights(s)
+}
+
+// SearchFAQs finds published texts containing all words of the query. The
+// rank is the number of matches, with matches in the question counting double.
+func (m *MemoryDB) SearchFAQs(language string, query string) ([]SearchResult, error) {
+	words := searchWords(query)
+	results := []SearchResult{}
+	if len(words) == 0 {
+		return results, nil
+	}
+
+	faqs, _ := m.AllFAQs()
+	for _, faq := range faqs {
+		for _, text := range faq.Texts {
+			if text.Locale.Code != language || !text.IsPublished() {
+				continue
+			}
+			rank := 0
+			for _, re := range words {
+				inQuestion := len(re.FindAllStringIndex(text.PublishedQuestion, -1))
+				inAnswer := len(re.FindAllStringIndex(text.PublishedAnswer, -1))
+				if inQuestion+inAnswer == 0 {
+					rank = 0
+					break
+				}
+				rank += 2*inQuestion + inAnswer
+			}
+			if rank == 0 {
+				continue
+			}
+			results = append(results, SearchResult{
+				FAQID:           faq.ID,
+				Text:            FAQText{Locale: text.Locale, Question: text.PublishedQuestion, Answer: text.PublishedAnswer},
+				QuestionSnippet: highlight(text.PublishedQuestion, words),
+				AnswerSnippet:   highlight(text.PublishedAnswer, words),
+				Rank:            float64(rank),
+			})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Rank > results[j].Rank })
+	return results, nil
+}
+
+func (m *MemoryDB) FuzzySearchFAQs(language string, query string) ([]SearchResult, error) {
+	return []SearchResult{}, nil
+}
+
+func (m *MemoryDB) SearchSuggestion(language string, query string) (string, error) {
+	return "", nil
+}
+
+func (m *MemoryDB) UpdateSearchIndex() error {
+	return nil
+}
+
+func (m *MemoryDB) PublishedLocales(faqIDs []int) (map[int][]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	published := make(map[int][]string)
+	for _, id := range faqIDs {
+		faq, ok := m.faqs[id]
+		if !ok {
+			continue
+		}
+		for _, text := range faq.Texts {
+			if text.IsPublished() && len(text.PublishedQuestion) > 0 {
+				published[id] = append(published[id], text.Locale.Code)
+			}
+		}
+	}
+	return published, nil
+}
+
+func (m *MemoryDB) CreateFAQ() (*FAQ, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	faq := &FAQ{ID: m.nextID(), Texts: []FAQText{}}
+	m.faqs[faq.ID] = faq
+	c := copyFAQ(faq)
+	return &c, nil
+}
+
+func (m *MemoryDB) SaveFAQText(faqID int, text *FAQText) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	faq, ok := m.faqs[faqID]
+	if !ok {
+		return errFAQNotFound
+	}
+	if isStale(faq, text) {
+		return errVersionConflict
+	}
+	m.saveFAQText(faq, text)
+	return nil
+}
+
+func (m *MemoryDB) CreateFAQWithTexts(faq *FAQ) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.categoryPosition(faq.CategoryID); faq.CategoryID > 0 && !ok {
+		return errCategoryNotFound
+	}
+
+	created := &FAQ{ID: m.nextID(), CategoryID: faq.CategoryID, Position: faq.Position, Texts: []FAQText{}}
+	for i := range faq.Texts {
+		m.saveFAQText(created, &faq.Texts[i])
+	}
+	m.faqs[created.ID] = created
+	faq.ID = created.ID
+	return nil
+}
+
+func (m *MemoryDB) SaveFAQTexts(faqID int, texts []FAQText) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	faq, ok := m.faqs[faqID]
+	if !ok {
+		return errFAQNotFound
+	}
+	for i := range texts {
+		if isStale(faq, &texts[i]) {
+			return errVersionConflict
+		}
+	}
+	for i := range texts {
+		m.saveFAQText(faq, &texts[i])
+	}
+	return nil
+}
+
+// isStale tells whether text is based on an older version of the text of faq.
+func isStale(faq *FAQ, text *FAQText) bool {
+	if text.Version == 0 {
+		return false
+	}
+	for _, t := range faq.Texts {
+		if t.Locale.Code == text.Locale.Code {
+			return t.Version != text.Version
+		}
+	}
+	return false
+}
+
+// saveFAQText updates or adds the text of faq, sets its new version and
+// source version and records a revision. The caller must hold m.mu.
+func (m *MemoryDB) saveFAQText(faq *FAQ, text *FAQText) {
+	if !text.keepSourceVersion {
+		text.SourceVersion = 0
+		if text.Locale.Code != getDefaultLocale().Code {
+			text.SourceVersion = faq.TextForLocale(getDefaultLocale().Code).Version
+		}
+	}
+
+	saved := false
+	for i := range faq.Texts {
+		if faq.Texts[i].Locale.Code == text.Locale.Code {
+			faq.Texts[i].Question = text.Question
+			faq.Texts[i].Answer = text.Answer
+			faq.Texts[i].SourceVersion = text.SourceVersion
+			faq.Texts[i].Version++
+			text.Version = faq.Texts[i].Version
+			saved = true
+		}
+	}
+	if !saved {
+		faq.Texts = append(faq.Texts, FAQText{
+			Locale:        localeFromCode(text.Locale.Code),
+			Question:      text.Question,
+			Answer:        text.Answer,
+			Version:       1,
+			SourceVersion: text.SourceVersion,
+		})
+		text.Version = 1
+	}
+
+	m.revisions = append(m.revisions, FAQTextRevision{
+		ID:            m.nextID(),
+		FAQID:         faq.ID,
+		Locale:        localeFromCode(text.Locale.Code),
+		Question:      text.Question,
+		Answer:        text.Answer,
+		Author:        text.Author,
+		SourceVersion: text.SourceVersion,
+		CreatedAt:     time.Now(),
+	})
+}
+
+func (m *MemoryDB) PublishFAQText(faqID int, localeCode string, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if faq, ok := m.faqs[faqID]; ok {
+		for i := range faq.Texts {
+			if faq.Texts[i].Locale.Code == localeCode {
+				faq.Texts[i] = publishedFAQText(faq.Texts[i])
+				m.addAuditEntry(author, auditPublishText, faqTextDetails(faqID, localeCode))
+				return nil
+			}
+		}
+	}
+	return errFAQTextNotFound
+}
+
+func (m *MemoryDB) FAQTextRevisions(faqID int, localeCode string) ([]FAQTextRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	revisions := []FAQTextRevision{}
+	for i := len(m.revisions) - 1; i >= 0; i-- {
+		rev := m.revisions[i]
+		if rev.FAQID == faqID && rev.Locale.Code == localeCode {
+			revisions = append(revisions, rev)
+		}
+	}
+	return revisions, nil
+}
+
+func (m *MemoryDB) FAQTextRevision(revisionID int) (*FAQTextRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rev := range m.revisions {
+		if rev.ID == revisionID {
+			return &rev, nil
+		}
+	}
+	return nil, errRevisionNotFound
+}
+
+func (m *MemoryDB) DeleteFAQ(faqID int, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	faq, ok := m.faqs[faqID]
+	if !ok || faq.IsDeleted() {
+		return errFAQNotFound
+	}
+	now := time.Now()
+	faq.DeletedAt = &now
+	m.addAuditEntry(author, auditDeleteFAQ, faqDetails(faqID))
+	return nil
+}
+
+func (m *MemoryDB) DeleteFAQText(faqID int, localeCode string, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if faq, ok := m.faqs[faqID]; ok {
+		for i := range faq.Texts {
+			if faq.Texts[i].Locale.Code == localeCode {
+				faq.Texts = append(faq.Texts[:i], faq.Texts[i+1:]...)
+				m.addAuditEntry(author, auditDeleteText, faqTextDetails(faqID, localeCode))
+				return nil
+			}
+		}
+	}
+	return errFAQTextNotFound
+}
+
+func (m *MemoryDB) TrashedFAQs() ([]FAQ, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	faqs := m.sortedFAQs(func(faq *FAQ) bool { return faq.IsDeleted() })
+	sort.SliceStable(faqs, func(i, j int) bool { return faqs[i].DeletedAt.After(*faqs[j].DeletedAt) })
+	return faqs, nil
+}
+
+func (m *MemoryDB) RestoreFAQ(faqID int, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	faq, ok := m.faqs[faqID]
+	if !ok || !faq.IsDeleted() {
+		return errFAQNotFound
+	}
+	faq.DeletedAt = nil
+	m.addAuditEntry(author, auditRestoreFAQ, faqDetails(faqID))
+	return nil
+}
+
+func (m *MemoryDB) PurgeFAQ(faqID int, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	faq, ok := m.faqs[faqID]
+	if !ok || !faq.IsDeleted() {
+		return errFAQNotFound
+	}
+	delete(m.faqs, faqID)
+	m.purgeRevisions(faqID)
+	m.addAuditEntry(author, auditPurgeFAQ, faqDetails(faqID))
+	return nil
+}
+
+func (m *MemoryDB) PurgeFAQs(deletedBefore time.Time, author string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for id, faq := range m.faqs {
+		if faq.IsDeleted() && faq.DeletedAt.Before(deletedBefore) {
+			delete(m.faqs, id)
+			m.purgeRevisions(id)
+			n++
+		}
+	}
+	if n > 0 {
+		m.addAuditEntry(author, auditPurgeTrash, purgeDetails(n, deletedBefore))
+	}
+	return n, nil
+}
+
+// purgeRevisions drops the revisions of a purged FAQ. The caller must hold
+// m.mu.
+func (m *MemoryDB) purgeRevisions(faqID int) {
+	revisions := []FAQTextRevision{}
+	for _, rev := range m.revisions {
+		if rev.FAQID != faqID {
+			revisions = append(revisions, rev)
+		}
+	}
+	m.revisions = revisions
+}
+
+// ImportFAQs runs fn on a copy of the database, which replaces it if fn
+// succeeds.
+func (m *MemoryDB) ImportFAQs(fn func(w importWriter) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	tx := m.copy()
+	err := fn(tx)
+	if err != nil {
+		return err
+	}
+	m.lastID, m.faqs, m.revisions, m.categories, m.audit = tx.lastID, tx.faqs, tx.revisions, tx.categories, tx.audit
+	return nil
+}
+
+// copy returns a copy of the FAQs, revisions, categories and audit log. The
+// caller must hold m.mu.
+func (m *MemoryDB) copy() *MemoryDB {
+	c := NewMemoryDB()
+	c.lastID = m.lastID
+	for id, faq := range m.faqs {
+		f := copyFAQ(faq)
+		c.faqs[id] = &f
+	}
+	c.revisions = append([]FAQTextRevision{}, m.revisions...)
+	for _, category := range m.categories {
+		category.Names = append([]CategoryName{}, category.Names...)
+		c.categories = append(c.categories, category)
+	}
+	c.audit = append([]AuditEntry{}, m.audit...)
+	return c
+}
+
+func (m *MemoryDB) AllCategories() ([]Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	categories := []Category{}
+	for _, c := range m.categories {
+		c.Names = append([]CategoryName{}, c.Names...)
+		categories = append(categories, c)
+	}
+	sort.SliceStable(categories, func(i, j int) bool {
+		if categories[i].Position != categories[j].Position {
+			return categories[i].Position < categories[j].Position
+		}
+		return categories[i].ID < categories[j].ID
+	})
+	return categories, nil
+}
+
+func (m *MemoryDB) CreateCategory(slug string, author string) (*Category, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	position := 0
+	for _, c := range m.categories {
+		if c.Slug == slug {
+			return nil, errCategoryExists
+		}
+		if c.Position > position {
+			position = c.Position
+		}
+	}
+	category := Category{ID: m.nextID(), Slug: slug, Position: position + 1, Names: []CategoryName{}}
+	m.categories = append(m.categories, category)
+	m.addAuditEntry(author, auditCreateCategory, categoryDetails(slug))
+	return &category, nil
+}
+
+func (m *MemoryDB) SaveCategory(category *Category, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var saved *Category
+	for i := range m.categories {
+		c := &m.categories[i]
+		if c.ID == category.ID {
+			saved = c
+		} else if c.Slug == category.Slug {
+			return errCategoryExists
+		}
+	}
+	if saved == nil {
+		return errCategoryNotFound
+	}
+
+	saved.Slug = category.Slug
+	saved.Position = category.Position
+	for _, n := range category.Names {
+		names := []CategoryName{}
+		for _, existing := range saved.Names {
+			if existing.Locale.Code != n.Locale.Code {
+				names = append(names, existing)
+			}
+		}
+		if len(n.Name) > 0 {
+			names = append(names, CategoryName{Locale: localeFromCode(n.Locale.Code), Name: n.Name})
+		}
+		saved.Names = names
+	}
+	m.addAuditEntry(author, auditSaveCategory, categoryDetails(category.Slug))
+	return nil
+}
+
+func (m *MemoryDB) DeleteCategory(categoryID int, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, c := range m.categories {
+		if c.ID == categoryID {
+			m.categories = append(m.categories[:i], m.categories[i+1:]...)
+			for _, faq := range m.faqs {
+				if faq.CategoryID == categoryID {
+					faq.CategoryID = 0
+				}
+			}
+			m.addAuditEntry(author, auditDeleteCategory, categoryIDDetails(categoryID))
+			return nil
+		}
+	}
+	return errCategoryNotFound
+}
+
+func (m *MemoryDB) MoveFAQ(faqID int, categoryID int, position int, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.categoryPosition(categoryID); categoryID > 0 && !ok {
+		return errCategoryNotFound
+	}
+	faq, ok := m.faqs[faqID]
+	if !ok {
+		return errFAQNotFound
+	}
+	faq.CategoryID = categoryID
+	faq.Position = position
+	m.addAuditEntry(author, auditMoveFAQ, faqMoveDetails(faqID, categoryID, position))
+	return nil
+}
+
+func (m *MemoryDB) MoveFAQs(categoryID int, faqIDs []int, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.categoryPosition(categoryID); categoryID > 0 && !ok {
+		return errCategoryNotFound
+	}
+	for _, faqID := range faqIDs {
+		if _, ok := m.faqs[faqID]; !ok {
+			return errFAQNotFound
+		}
+	}
+	for i, faqID := range faqIDs {
+		m.faqs[faqID].CategoryID = categoryID
+		m.faqs[faqID].Position = i + 1
+	}
+	m.addAuditEntry(author, auditMoveFAQs, faqsMoveDetails(categoryID, faqIDs))
+	return nil
+}
+
+func (m *MemoryDB) MoveCategories(categoryIDs []int, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, categoryID := range categoryIDs {
+		if _, ok := m.categoryPosition(categoryID); !ok {
+			return errCategoryNotFound
+		}
+	}
+	for i, categoryID := range categoryIDs {
+		for j := range m.categories {
+			if m.categories[j].ID == categoryID {
+				m.categories[j].Position = i + 1
+			}
+		}
+	}
+	m.addAuditEntry(author, auditMoveCategories, categoriesDetails(categoryIDs))
+	return nil
+}
+
+func (m *MemoryDB) AllLocales() ([]LocaleSetting, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sortedLocaleSettings(m.locales), nil
+}
+
+func (m *MemoryDB) CreateLocale(setting *LocaleSetting, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, l := range m.locales {
+		if l.Code == setting.Code {
+			return errLocaleExists
+		}
+	}
+	m.setDefaultLocale(setting)
+	m.locales = append(m.locales, *setting)
+	m.addAuditEntry(author, auditCreateLocale, localeDetails(setting.Code))
+	return nil
+}
+
+func (m *MemoryDB) SaveLocale(setting *LocaleSetting, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.locales {
+		if m.locales[i].Code == setting.Code {
+			m.setDefaultLocale(setting)
+			m.locales[i] = *setting
+			m.addAuditEntry(author, auditSaveLocale, localeDetails(setting.Code))
+			return nil
+		}
+	}
+	return errLocaleNotFound
+}
+
+func (m *MemoryDB) MoveLocales(codes []string, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	indexes := []int{}
+	for _, code := range codes {
+		index := -1
+		for i := range m.locales {
+			if m.locales[i].Code == code {
+				index = i
+			}
+		}
+		if index < 0 {
+			return errLocaleNotFound
+		}
+		indexes = append(indexes, index)
+	}
+	for position, i := range indexes {
+		m.locales[i].Position = position + 1
+	}
+	m.addAuditEntry(author, auditMoveLocales, localesDetails(codes))
+	return nil
+}
+
+// setDefaultLocale unsets the default of other locales if setting is the
+// default. The caller must hold m.mu.
+func (m *MemoryDB) setDefaultLocale(setting *LocaleSetting) {
+	if !setting.Default {
+		return
+	}
+	for i := range m.locales {
+		m.locales[i].Default = false
+	}
+}
+
+func (m *MemoryDB) ClearDB() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.faqs = make(map[int]*FAQ)
+	m.revisions = nil
+	m.categories = nil
+	m.locales = nil
+	m.users = nil
+	m.audit = nil
+	return nil
+}
+
+func (m *MemoryDB) AllUsers() ([]User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	users := append([]User{}, m.users...)
+	sort.SliceStable(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+	return users, nil
+}
+
+func (m *MemoryDB) UserByEmail(email string) (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Email == email {
+			return &u, nil
+		}
+	}
+	return nil, errUserNotFound
+}
+
+func (m *MemoryDB) CreateUser(user *User, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Email == user.Email {
+			return errUserExists
+		}
+	}
+	user.ID = m.nextID()
+	m.users = append(m.users, *user)
+	m.addAuditEntry(author, auditCreateUser, userDetails(user))
+	return nil
+}
+
+func (m *MemoryDB) SaveUser(user *User, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.users {
+		if m.users[i].ID == user.ID {
+			m.users[i] = *user
+			m.addAuditEntry(author, auditSaveUser, userDetails(user))
+			return nil
+		}
+	}
+	return errUserNotFound
+}
+
+func (m *MemoryDB) DeleteUser(id int, author string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.users {
+		if m.users[i].ID == id {
+			m.users = append(m.users[:i], m.users[i+1:]...)
+			m.addAuditEntry(author, auditDeleteUser, userIDDetails(id))
+			return nil
+		}
+	}
+	return errUserNotFound
+}
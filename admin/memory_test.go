@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewRepositoryMemory(t *testing.T) {
+	repo, err := NewDB("memory://")
+	expectNoError(t, err)
+	_, ok := repo.(*MemoryDB)
+	expectIsTrue(t, ok)
+}
+
+func TestMemoryDBWriteFlow(t *testing.T) {
+	faqRepository = NewMemoryDB()
+
+	resp := doRequestWithHeader("POST", "/api/faqs", body(`{"texts":[{"locale":{"code":"en"},"question":"How do I pay?","answer":"By card."}]}`), jsonHeader())
+	expectStatus(t, resp, 201)
+	expectHeader(t, resp, "Location", "/api/faqs/1")
+
+	// Drafts are not served
+	resp = doRequest("GET", "/api/faqs/1", emptyBody())
+	expectErrorJSON(t, resp, 404, "faq not found")
+
+	resp = doRequest("POST", "/api/faqs/1/texts/en/publish", emptyBody())
+	expectStatus(t, resp, 200)
+
+	resp = doRequest("GET", "/api/faqs/1", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"question":"How do I pay?","answer":"By card."`)
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/1/texts/de", body(`{"question":"Wie bezahle ich?","answer":"Per Karte."}`), jsonHeader())
+	expectStatus(t, resp, 200)
+	resp = doRequest("POST", "/api/faqs/1/texts/de/publish", emptyBody())
+	expectStatus(t, resp, 200)
+
+	resp = doRequest("GET", "/api/search-faqs?lang=de&query=karte", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"answer_snippet":"Per \u003cmark\u003eKarte\u003c/mark\u003e."`)
+
+	resp = doRequest("GET", "/api/faqs/1/texts/de/revisions", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"author":"api"`)
+
+	resp = doRequest("DELETE", "/api/faqs/1", emptyBody())
+	expectStatus(t, resp, 204)
+	resp = doRequest("GET", "/api/faqs", emptyBody())
+	expectBodyContains(t, resp, `[]`)
+	expectHeader(t, resp, "X-Total-Count", "0")
+
+	resp = doRequest("POST", "/api/faqs/1/restore", emptyBody())
+	expectStatus(t, resp, 200)
+	resp = doRequest("GET", "/api/faqs", emptyBody())
+	expectHeader(t, resp, "X-Total-Count", "1")
+}
+
+func TestMemoryDBCategories(t *testing.T) {
+	repo := NewMemoryDB()
+
+	c, err := repo.CreateCategory("billing", testAuthor)
+	expectNoError(t, err)
+	_, err = repo.CreateCategory("billing", testAuthor)
+	expectSameError(t, errCategoryExists, err)
+
+	c.Names = []CategoryName{CategoryName{Locale: Locale{Code: "de"}, Name: "Abrechnung"}}
+	expectNoError(t, repo.SaveCategory(c, testAuthor))
+
+	first, _ := repo.CreateFAQ()
+	second, _ := repo.CreateFAQ()
+	expectNoError(t, repo.MoveFAQ(second.ID, c.ID, 1, testAuthor))
+	expectSameError(t, errCategoryNotFound, repo.MoveFAQ(second.ID, 999, 1, testAuthor))
+
+	faqs, err := repo.AllFAQs()
+	expectNoError(t, err)
+	expectSameInt(t, second.ID, faqs[0].ID)
+	expectSameInt(t, first.ID, faqs[1].ID)
+
+	categories, err := repo.AllCategories()
+	expectNoError(t, err)
+	expectSameString(t, "Abrechnung", categories[0].NameForLocale("de"))
+
+	expectNoError(t, repo.DeleteCategory(c.ID, testAuthor))
+	f, _ := repo.FAQById(second.ID)
+	expectSameInt(t, 0, f.CategoryID)
+}
+
+func TestMemoryDBTrash(t *testing.T) {
+	repo := NewMemoryDB()
+
+	f, _ := repo.CreateFAQ()
+	expectNoError(t, repo.SaveFAQText(f.ID, &FAQText{Locale: Locale{Code: "en"}, Question: "q?"}))
+	expectNoError(t, repo.DeleteFAQ(f.ID, testAuthor))
+	expectSameError(t, errFAQNotFound, repo.DeleteFAQ(f.ID, testAuthor))
+
+	trashed, err := repo.TrashedFAQs()
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(trashed))
+
+	n, err := repo.PurgeFAQs(time.Now().Add(-time.Hour), testAuthor)
+	expectNoError(t, err)
+	expectSameInt(t, 0, n)
+	n, err = repo.PurgeFAQs(time.Now().Add(time.Hour), testAuthor)
+	expectNoError(t, err)
+	expectSameInt(t, 1, n)
+
+	revisions, err := repo.FAQTextRevisions(f.ID, "en")
+	expectNoError(t, err)
+	expectSameInt(t, 0, len(revisions))
+
+	expectSameError(t, errFAQNotFound, repo.RestoreFAQ(f.ID, testAuthor))
+	expectSameError(t, errFAQNotFound, repo.SaveFAQText(f.ID, &FAQText{Locale: Locale{Code: "en"}}))
+}
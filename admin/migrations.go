@@ -0,0 +1,518 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// migration is a schema change of the Postgres database. Migrations are
+// applied in order of Version, each in a transaction of its own, and recorded
+// in schema_migrations. Never change a migration once it has been released;
+// add a new one instead. The code of the default locale is available to Up as
+// current_setting('faqaas.default_locale').
+type migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+var migrations = []migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		// The former schema.sql, kept as it was
+		Up: `
+		CREATE TABLE faqs (
+		  id SERIAL PRIMARY KEY,
+		  question TEXT,
+		  answer TEXT
+		);
+
+		CREATE TABLE faq_texts (
+		  id SERIAL PRIMARY KEY,
+		  faq_id INTEGER REFERENCES faqs (id),
+		  locale TEXT,
+		  question TEXT,
+		  answer TEXT,
+		  CONSTRAINT texts_faq_id_locale unique(faq_id,locale)
+		);
+
+		CREATE MATERIALIZED VIEW search_index AS
+		SELECT faq_texts.id,
+		       faq_texts.faq_id,
+		       faq_texts.locale,
+		       setweight(to_tsvector('simple', faq_texts.question), 'A') ||
+		       setweight(to_tsvector('simple', faq_texts.answer), 'B') as document
+		FROM faq_texts;
+
+		CREATE INDEX idx_fts_search ON search_index USING gin(document);
+
+		REFRESH MATERIALIZED VIEW search_index;`,
+		Down: `
+		DROP MATERIALIZED VIEW search_index;
+		DROP TABLE faq_texts;
+		DROP TABLE faqs;`,
+	},
+	{
+		Version: 2,
+		Name:    "categories",
+		Up: `
+		CREATE TABLE categories (
+		  id SERIAL PRIMARY KEY,
+		  slug TEXT NOT NULL,
+		  position INTEGER NOT NULL DEFAULT 0,
+		  CONSTRAINT categories_slug unique(slug)
+		);
+
+		CREATE TABLE category_texts (
+		  id SERIAL PRIMARY KEY,
+		  category_id INTEGER REFERENCES categories (id),
+		  locale TEXT,
+		  name TEXT,
+		  CONSTRAINT category_texts_category_id_locale unique(category_id,locale)
+		);
+
+		ALTER TABLE faqs ADD COLUMN category_id INTEGER REFERENCES categories (id);`,
+		Down: `
+		ALTER TABLE faqs DROP COLUMN category_id;
+		DROP TABLE category_texts;
+		DROP TABLE categories;`,
+	},
+	{
+		Version: 3,
+		Name:    "faq positions",
+		// FAQs at the same position are ordered by id
+		Up: `
+		ALTER TABLE faqs ADD COLUMN position INTEGER NOT NULL DEFAULT 0;`,
+		Down: `
+		ALTER TABLE faqs DROP COLUMN position;`,
+	},
+	{
+		Version: 4,
+		Name:    "published texts",
+		// Existing texts were public, so they are published as they are
+		Up: `
+		ALTER TABLE faq_texts ADD COLUMN published_question TEXT;
+		ALTER TABLE faq_texts ADD COLUMN published_answer TEXT;
+		ALTER TABLE faq_texts ADD COLUMN published_at TIMESTAMP WITH TIME ZONE;
+		UPDATE faq_texts SET published_question = question, published_answer = answer, published_at = now();`,
+		Down: `
+		ALTER TABLE faq_texts DROP COLUMN published_at;
+		ALTER TABLE faq_texts DROP COLUMN published_answer;
+		ALTER TABLE faq_texts DROP COLUMN published_question;`,
+	},
+	{
+		Version: 5,
+		Name:    "text revisions",
+		Up: `
+		CREATE TABLE faq_text_revisions (
+		  id SERIAL PRIMARY KEY,
+		  faq_id INTEGER NOT NULL,
+		  locale TEXT NOT NULL,
+		  question TEXT,
+		  answer TEXT,
+		  author TEXT,
+		  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+		);
+
+		CREATE INDEX idx_faq_text_revisions ON faq_text_revisions (faq_id, locale);`,
+		Down: `
+		DROP TABLE faq_text_revisions;`,
+	},
+	{
+		Version: 6,
+		Name:    "faq trash",
+		Up: `
+		ALTER TABLE faqs ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;`,
+		Down: `
+		ALTER TABLE faqs DROP COLUMN deleted_at;`,
+	},
+	{
+		Version: 7,
+		Name:    "search configs",
+		// Existing texts get the configuration searchConfig picks for their
+		// locale, if the database has it installed
+		Up: `
+		ALTER TABLE faq_texts ADD COLUMN search_config REGCONFIG NOT NULL DEFAULT 'simple';
+		UPDATE faq_texts SET search_config = pg_ts_config.cfgname::regconfig
+		FROM (VALUES ('ar', 'arabic'), ('da', 'danish'), ('de', 'german'), ('en', 'english'),
+		             ('es', 'spanish'), ('fi', 'finnish'), ('fr', 'french'), ('hu', 'hungarian'),
+		             ('it', 'italian'), ('nl', 'dutch'), ('no', 'norwegian'), ('nb', 'norwegian'),
+		             ('nn', 'norwegian'), ('pt', 'portuguese'), ('ro', 'romanian'), ('ru', 'russian'),
+		             ('sv', 'swedish'), ('tr', 'turkish')) AS configs (language, config),
+		     pg_ts_config
+		WHERE lower(substring(faq_texts.locale from '^[A-Za-z]+')) = configs.language
+		AND pg_ts_config.cfgname = configs.config;`,
+		Down: `
+		ALTER TABLE faq_texts DROP COLUMN search_config;`,
+	},
+	{
+		Version: 8,
+		Name:    "search suggestions",
+		Up: `
+		CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+		-- Vocabulary of the published texts for "did you mean" suggestions
+		CREATE MATERIALIZED VIEW search_words AS
+		SELECT DISTINCT faq_texts.locale,
+		       word
+		FROM faq_texts,
+		     regexp_split_to_table(lower(coalesce(faq_texts.published_question, '') || ' ' || coalesce(faq_texts.published_answer, '')), '[^[:alnum:]]+') AS word
+		WHERE faq_texts.published_at IS NOT NULL
+		AND length(word) > 2;
+
+		CREATE UNIQUE INDEX idx_search_words_locale_word ON search_words (locale, word);
+		CREATE INDEX idx_search_words ON search_words USING gin(word gin_trgm_ops);
+
+		REFRESH MATERIALIZED VIEW search_words;`,
+		Down: `
+		DROP MATERIALIZED VIEW search_words;`,
+	},
+	{
+		Version: 9,
+		Name:    "search documents",
+		// Replaces the search_index view, which had to be refreshed to find
+		// newly published texts. Touching every text fills in its document.
+		Up: `
+		ALTER TABLE faq_texts ADD COLUMN document TSVECTOR;
+
+		-- Keeps the search document of a text in sync with its published content
+		CREATE FUNCTION faq_texts_document_trigger() RETURNS trigger AS $$
+		BEGIN
+		  IF NEW.published_at IS NULL THEN
+		    NEW.document := NULL;
+		  ELSE
+		    NEW.document :=
+		      setweight(to_tsvector(NEW.search_config, coalesce(NEW.published_question, '')), 'A') ||
+		      setweight(to_tsvector(NEW.search_config, coalesce(NEW.published_answer, '')), 'B');
+		  END IF;
+		  RETURN NEW;
+		END
+		$$ LANGUAGE plpgsql;
+
+		CREATE TRIGGER faq_texts_document BEFORE INSERT OR UPDATE
+		  ON faq_texts FOR EACH ROW EXECUTE PROCEDURE faq_texts_document_trigger();
+
+		UPDATE faq_texts SET id = id;
+
+		DROP MATERIALIZED VIEW search_index;
+
+		CREATE INDEX idx_fts_search ON faq_texts USING gin(document);`,
+		Down: `
+		DROP INDEX idx_fts_search;
+
+		CREATE MATERIALIZED VIEW search_index AS
+		SELECT faq_texts.id,
+		       faq_texts.faq_id,
+		       faq_texts.locale,
+		       setweight(to_tsvector('simple', faq_texts.question), 'A') ||
+		       setweight(to_tsvector('simple', faq_texts.answer), 'B') as document
+		FROM faq_texts;
+
+		CREATE INDEX idx_fts_search ON search_index USING gin(document);
+
+		REFRESH MATERIALIZED VIEW search_index;
+
+		DROP TRIGGER faq_texts_document ON faq_texts;
+		DROP FUNCTION faq_texts_document_trigger();
+		ALTER TABLE faq_texts DROP COLUMN document;`,
+	},
+	{
+		Version: 10,
+		Name:    "faq text versions",
+		Up: `
+		ALTER TABLE faq_texts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+		Down: `
+		ALTER TABLE faq_texts DROP COLUMN version;`,
+	},
+	{
+		Version: 11,
+		Name:    "source versions of translations",
+		// Existing translations are taken to be up to date with the text in
+		// the default locale. The source version of existing revisions is
+		// unknown.
+		Up: `
+		ALTER TABLE faq_texts ADD COLUMN source_version INTEGER;
+		ALTER TABLE faq_text_revisions ADD COLUMN source_version INTEGER;
+		UPDATE faq_texts SET source_version = (SELECT t.version FROM faq_texts t WHERE t.faq_id = faq_texts.faq_id AND t.locale = current_setting('faqaas.default_locale'))
+		WHERE faq_texts.locale <> current_setting('faqaas.default_locale');`,
+		Down: `
+		ALTER TABLE faq_text_revisions DROP COLUMN source_version;
+		ALTER TABLE faq_texts DROP COLUMN source_version;`,
+	},
+	{
+		Version: 12,
+		Name:    "locales",
+		// Seeded from SUPPORTED_LOCALES on startup
+		Up: `
+		CREATE TABLE locales (
+		  id SERIAL PRIMARY KEY,
+		  code TEXT NOT NULL,
+		  enabled BOOLEAN NOT NULL DEFAULT true,
+		  position INTEGER NOT NULL DEFAULT 0,
+		  is_default BOOLEAN NOT NULL DEFAULT false,
+		  CONSTRAINT locales_code unique(code)
+		);
+
+		CREATE UNIQUE INDEX idx_locales_default ON locales (is_default) WHERE is_default;`,
+		Down: `
+		DROP TABLE locales;`,
+	},
+	{
+		Version: 13,
+		Name:    "locale fallbacks",
+		Up: `
+		ALTER TABLE locales ADD COLUMN fallbacks TEXT NOT NULL DEFAULT '';`,
+		Down: `
+		ALTER TABLE locales DROP COLUMN fallbacks;`,
+	},
+	{
+		Version: 14,
+		Name:    "users",
+		// Seeded with an admin from ADMIN_PASSWORD on startup
+		Up: `
+		CREATE TABLE users (
+		  id SERIAL PRIMARY KEY,
+		  email TEXT NOT NULL,
+		  password_hash TEXT NOT NULL,
+		  role TEXT NOT NULL,
+		  locales TEXT NOT NULL DEFAULT '',
+		  CONSTRAINT users_email unique(email)
+		);`,
+		Down: `
+		DROP TABLE users;`,
+	},
+	{
+		Version: 15,
+		Name:    "audit log",
+		Up: `
+		CREATE TABLE audit_log (
+		  id SERIAL PRIMARY KEY,
+		  author TEXT NOT NULL,
+		  action TEXT NOT NULL,
+		  details TEXT NOT NULL,
+		  created_at TIMESTAMP WITH TIME ZONE NOT NULL
+		);`,
+		Down: `
+		DROP TABLE audit_log;`,
+	},
+}
+
+// MigrationStatus is a migration and when it was applied, if it was.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+}
+
+var errNoMigrationApplied = errors.New("no migration applied")
+
+func createMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+		  version INTEGER PRIMARY KEY,
+		  applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+		);`)
+	if err != nil {
+		logError(err)
+		return err
+	}
+
+	// Databases set up from the former schema.sql are at version 1 and get
+	// all later migrations
+	_, err = db.Exec(`
+		INSERT INTO schema_migrations (version)
+		SELECT 1 WHERE to_regclass('faqs') IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM schema_migrations);`)
+	if err != nil {
+		logError(err)
+	}
+	return err
+}
+
+// migrateUp applies all pending migrations and returns how many it applied.
+// Servers starting at the same time may call it concurrently.
+func migrateUp(db *sql.DB) (int, error) {
+	err := createMigrationsTable(db)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		ok, err := applyMigration(db, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s): %v", m.Version, m.Name, err)
+		}
+		if ok {
+			applied++
+		}
+	}
+	return applied, nil
+}
+
+// applyMigration runs m unless it has been applied already.
+func applyMigration(db *sql.DB, m migration) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE;`)
+	if err != nil {
+		return false, err
+	}
+	var exists bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1);`, m.Version).Scan(&exists)
+	if err != nil || exists {
+		return false, err
+	}
+
+	_, err = tx.Exec(`SELECT set_config('faqaas.default_locale', $1, true);`, getDefaultLocale().Code)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(m.Up)
+	if err != nil {
+		return false, err
+	}
+	_, err = tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1);`, m.Version)
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// migrateDown reverts the latest applied migration and returns it.
+func migrateDown(db *sql.DB) (*migration, error) {
+	err := createMigrationsTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`LOCK TABLE schema_migrations IN EXCLUSIVE MODE;`)
+	if err != nil {
+		return nil, err
+	}
+	var version int
+	err = tx.QueryRow(`SELECT version FROM schema_migrations ORDER BY version DESC LIMIT 1;`).Scan(&version)
+	if err == sql.ErrNoRows {
+		return nil, errNoMigrationApplied
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := findMigration(version)
+	if m == nil {
+		return nil, fmt.Errorf("unknown migration %d", version)
+	}
+	_, err = tx.Exec(m.Down)
+	if err != nil {
+		return nil, fmt.Errorf("migration %d (%s): %v", m.Version, m.Name, err)
+	}
+	_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = $1;`, version)
+	if err != nil {
+		return nil, err
+	}
+	return m, tx.Commit()
+}
+
+func findMigration(version int) *migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+func migrationStatus(db *sql.DB) ([]MigrationStatus, error) {
+	err := createMigrationsTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(`SELECT version, applied_at FROM schema_migrations;`)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	appliedAt := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		err = rows.Scan(&version, &at)
+		if err != nil {
+			return nil, err
+		}
+		appliedAt[version] = at
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := []MigrationStatus{}
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if at, ok := appliedAt[m.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+const migrateUsage = "usage: faqaas migrate up|down|status"
+
+// runMigrateCommand implements "faqaas migrate up|down|status".
+func runMigrateCommand(db *sql.DB, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	switch args[0] {
+	case "up":
+		n, err := migrateUp(db)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "applied %d migrations\n", n)
+	case "down":
+		m, err := migrateDown(db)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "reverted migration %d (%s)\n", m.Version, m.Name)
+	case "status":
+		statuses, err := migrationStatus(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%4d  %-30s  %s\n", s.Version, s.Name, state)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+	return nil
+}
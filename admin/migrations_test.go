@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	for i, m := range migrations {
+		expectSameInt(t, i+1, m.Version)
+		expectIsTrue(t, len(m.Name) > 0)
+		expectIsTrue(t, len(m.Up) > 0)
+		expectIsTrue(t, len(m.Down) > 0)
+	}
+}
+
+func TestMigrateCommandUsage(t *testing.T) {
+	var out bytes.Buffer
+	for _, args := range [][]string{{}, {"sideways"}, {"up", "down"}} {
+		err := runMigrateCommand(nil, args, &out)
+		expectSameString(t, migrateUsage, err.Error())
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	repo := preparePostgresDB(t)
+	var out bytes.Buffer
+
+	err := runMigrateCommand(repo.DB, []string{"status"}, &out)
+	expectNoError(t, err)
+	expectIsTrue(t, strings.Contains(out.String(), "   1  initial schema                  applied "))
+
+	m, err := migrateDown(repo.DB)
+	expectNoError(t, err)
+	expectSameInt(t, len(migrations), m.Version)
+
+	out.Reset()
+	err = runMigrateCommand(repo.DB, []string{"status"}, &out)
+	expectNoError(t, err)
+	expectIsTrue(t, strings.Contains(out.String(), "pending"))
+
+	n, err := migrateUp(repo.DB)
+	expectNoError(t, err)
+	expectSameInt(t, 1, n)
+
+	n, err = migrateUp(repo.DB)
+	expectNoError(t, err)
+	expectSameInt(t, 0, n)
+
+	_, err = repo.AllFAQs()
+	expectNoError(t, err)
+}
+
+func TestMigrateFromBaselineSchema(t *testing.T) {
+	repo := preparePostgresDB(t)
+	for {
+		_, err := migrateDown(repo.DB)
+		if err == errNoMigrationApplied {
+			break
+		}
+		expectNoError(t, err)
+		if err != nil {
+			return
+		}
+	}
+	_, err := repo.DB.Exec(`DROP TABLE schema_migrations;`)
+	expectNoError(t, err)
+
+	// A database set up from schema.sql before there were migrations
+	schema, err := ioutil.ReadFile("testdata/baseline_schema.sql")
+	expectNoError(t, err)
+	_, err = repo.DB.Exec(string(schema))
+	expectNoError(t, err)
+	var id int
+	err = repo.DB.QueryRow(`INSERT INTO faqs (question, answer) VALUES ('', '') RETURNING id;`).Scan(&id)
+	expectNoError(t, err)
+	_, err = repo.DB.Exec(`INSERT INTO faq_texts (faq_id, locale, question, answer) VALUES ($1, 'en', 'How do I make a payment?', 'By card.'), ($1, 'de', 'Wie leiste ich eine Zahlung?', 'Per Karte.');`, id)
+	expectNoError(t, err)
+
+	n, err := migrateUp(repo.DB)
+	expectNoError(t, err)
+	expectSameInt(t, len(migrations)-1, n)
+
+	faq, err := repo.FAQById(id)
+	expectNoError(t, err)
+	expectSameInt(t, 2, len(faq.Texts))
+	for _, text := range faq.Texts {
+		if text.Locale.Code == getDefaultLocale().Code {
+			expectSameInt(t, 0, text.SourceVersion)
+		} else {
+			expectSameInt(t, 1, text.SourceVersion)
+		}
+	}
+	results, err := repo.SearchFAQs("en", "payments")
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(results))
+	results, err = repo.SearchFAQs("de", "Zahlungen")
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(results))
+
+	var searchIndex *string
+	err = repo.DB.QueryRow(`SELECT to_regclass('search_index')::text;`).Scan(&searchIndex)
+	expectNoError(t, err)
+	expectIsTrue(t, searchIndex == nil)
+
+	expectNoError(t, repo.ClearDB())
+}
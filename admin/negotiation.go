@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// langCookie remembers the language a visitor picked.
+const langCookie = "lang"
+
+// negotiateLocale resolves the locale of a request through the language
+// matcher. The lang param takes precedence over the lang cookie, which
+// takes precedence over the Accept-Language header. ok is false if the
+// request names no language at all, in which case the default locale is
+// returned.
+func negotiateLocale(r *http.Request) (localeCode string, ok bool) {
+	preferences := pickedLanguages(r)
+	if accept := r.Header.Get("Accept-Language"); len(accept) > 0 {
+		preferences = append(preferences, accept)
+	}
+	return matchLocale(preferences...), len(preferences) > 0
+}
+
+// pickedLanguages returns the lang param and the lang cookie of a request,
+// the languages a visitor picked on purpose.
+func pickedLanguages(r *http.Request) []string {
+	preferences := []string{}
+	if lang := strings.TrimSpace(r.FormValue("lang")); len(lang) > 0 {
+		preferences = append(preferences, lang)
+	}
+	if cookie, err := r.Cookie(langCookie); err == nil && len(cookie.Value) > 0 {
+		preferences = append(preferences, cookie.Value)
+	}
+	return preferences
+}
+
+// requestedLocale returns the locale of a request for endpoints that return
+// all locales unless one is requested. An exact locale param must be
+// supported. Otherwise the lang param or cookie is resolved through the
+// language matcher. Accept-Language, which browsers always send, is ignored
+// so that it doesn't narrow the response. ok is false if no locale is
+// requested.
+func requestedLocale(r *http.Request) (localeCode string, ok bool, err error) {
+	localeCode = strings.TrimSpace(r.FormValue("locale"))
+	if len(localeCode) == 0 {
+		preferences := pickedLanguages(r)
+		if len(preferences) == 0 {
+			return "", false, nil
+		}
+		return matchLocale(preferences...), true, nil
+	}
+	if !isSupportedLocale(localeCode) {
+		return "", false, fmt.Errorf("unsupported locale: %v", localeCode)
+	}
+	return localeCode, true, nil
+}
+
+// setContentLanguage sets Content-Language to the distinct locales served,
+// or localeCode if there are none.
+func setContentLanguage(w http.ResponseWriter, served []string, localeCode string) {
+	codes := []string{}
+	for _, code := range served {
+		if len(code) > 0 && !containsString(codes, code) {
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		codes = append(codes, localeCode)
+	}
+	w.Header().Set("Content-Language", strings.Join(codes, ", "))
+}
+
+// setVaryLanguage tells caches that the response depends on the
+// Accept-Language header and the lang cookie, as with negotiateLocale.
+func setVaryLanguage(w http.ResponseWriter) {
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Add("Vary", "Cookie")
+}
+
+// setVaryPickedLanguage tells caches that the response depends on the lang
+// cookie, as with requestedLocale.
+func setVaryPickedLanguage(w http.ResponseWriter) {
+	w.Header().Add("Vary", "Cookie")
+}
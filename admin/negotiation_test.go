@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func negotiate(uri string, header http.Header) (string, bool) {
+	r := httptest.NewRequest("GET", uri, nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	return negotiateLocale(r)
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	code, ok := negotiate("/api/faqs", nil)
+	expectSameString(t, "en", code)
+	expectIsTrue(t, !ok)
+
+	code, ok = negotiate("/api/faqs?lang=de", http.Header{"Accept-Language": {"fr"}})
+	expectSameString(t, "de", code)
+	expectIsTrue(t, ok)
+
+	code, _ = negotiate("/api/faqs", http.Header{"Accept-Language": {"fr"}, "Cookie": {"lang=es"}})
+	expectSameString(t, "es", code)
+
+	// Unsupported preferences are skipped
+	code, _ = negotiate("/api/faqs?lang=xx", http.Header{"Accept-Language": {"ja, fr;q=0.8"}})
+	expectSameString(t, "fr", code)
+
+	code, _ = negotiate("/api/faqs", http.Header{"Accept-Language": {"pt-BR, pt;q=0.9"}})
+	expectSameString(t, "pt-BR", code)
+	code, _ = negotiate("/api/faqs", http.Header{"Accept-Language": {"de-CH"}})
+	expectSameString(t, "de", code)
+
+	code, ok = negotiate("/api/faqs", http.Header{"Accept-Language": {"ja"}})
+	expectSameString(t, "en", code)
+	expectIsTrue(t, ok)
+}
+
+func expectVaryLanguage(t *testing.T, resp *httptest.ResponseRecorder) {
+	vary := strings.Join(resp.Header()["Vary"], ", ")
+	if !strings.Contains(vary, "Accept-Language") || !strings.Contains(vary, "Cookie") {
+		t.Errorf("wrong header Vary: %v", vary)
+	}
+}
+
+func TestRedirectToFAQsNegotiates(t *testing.T) {
+	resp := doRequestWithHeader("GET", "/", emptyBody(), &http.Header{"Cookie": {"lang=fr"}, "Accept-Language": {"de"}})
+	expectHeader(t, resp, "Location", "/faqs/fr")
+	expectVaryLanguage(t, resp)
+
+	resp = doRequest("GET", "/?lang=pt-BR", emptyBody())
+	expectHeader(t, resp, "Location", "/faqs/pt-BR")
+}
+
+func TestGetAPIFAQsNegotiates(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+	expectNoError(t, faqRepository.PublishFAQText(2, "de", testAuthor))
+
+	resp := doRequestWithHeader("GET", "/api/faqs?lang=de", emptyBody(), &http.Header{"Accept-Language": {"en"}})
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "de")
+	expectHeader(t, resp, "Vary", "Cookie")
+	expectBodyContains(t, resp, `"texts":[{"locale":{"code":"de","name_en":"German","name_local":"Deutsch"},"question":"Wie bezahle ich?"`)
+
+	// Accept-Language alone doesn't narrow the texts
+	resp = doRequestWithHeader("GET", "/api/faqs", emptyBody(), &http.Header{"Accept-Language": {"de-DE, en;q=0.5"}})
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "")
+	expectBodyContains(t, resp, `"texts":[{"locale":{"code":"en","name_en":"English","name_local":"English"},"question":"How do I pay?"`)
+	expectBodyContains(t, resp, `{"locale":{"code":"de","name_en":"German","name_local":"Deutsch"},"question":"Wie bezahle ich?"`)
+
+	resp = doRequestWithHeader("GET", "/api/faqs/2", emptyBody(), &http.Header{"Accept-Language": {"de"}})
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "")
+	expectBodyContains(t, resp, `"question":"How do I pay?"`)
+	expectBodyContains(t, resp, `"question":"Wie bezahle ich?"`)
+
+	resp = doRequest("GET", "/api/faqs", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "")
+	expectHeader(t, resp, "Vary", "Cookie")
+
+	resp = doRequestWithHeader("GET", "/api/faqs/2?lang=fr", emptyBody(), &http.Header{"Accept-Language": {"de"}})
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "en")
+	expectBodyContains(t, resp, `"resolved_locale":"en"}`)
+
+	resp = doRequestWithHeader("GET", "/api/faqs/2", emptyBody(), &http.Header{"Cookie": {"lang=de"}})
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "de")
+	expectHeader(t, resp, "Vary", "Cookie")
+}
+
+func TestGetAPICategoriesNegotiates(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+
+	resp := doRequestWithHeader("GET", "/api/categories", emptyBody(), &http.Header{"Cookie": {"lang=de"}})
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "en")
+	expectHeader(t, resp, "Vary", "Cookie")
+	expectBodyContains(t, resp, `"names":[{"locale":{"code":"en","name_en":"English","name_local":"English"},"name":"Billing"}]`)
+
+	resp = doRequestWithHeader("GET", "/api/categories", emptyBody(), &http.Header{"Accept-Language": {"de"}})
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "")
+	expectHeader(t, resp, "Vary", "Cookie")
+
+	resp = doRequest("GET", "/api/categories?locale=xx", emptyBody())
+	expectErrorJSON(t, resp, 400, "unsupported locale: xx")
+}
+
+func TestGetAPISearchFAQsNegotiates(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("GET", "/api/search-faqs?query=Frage", emptyBody(), &http.Header{"Accept-Language": {"de"}})
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "de")
+	expectVaryLanguage(t, resp)
+	expectBodyContains(t, resp, `"question":"Frage?"`)
+}
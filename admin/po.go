@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Gettext PO files hold the translations of one locale. msgid is the text
+// in the default locale and msgctxt names the unit and field, as in
+// "faq-12/question".
+const poContentType = "text/x-gettext-translation; charset=utf-8"
+
+var poEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// poString formats s as a PO string, split after each newline.
+func poString(keyword, s string) string {
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") {
+		return fmt.Sprintf("%s \"%s\"\n", keyword, poEscaper.Replace(s))
+	}
+	lines := []string{keyword + ` ""`}
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if len(line) > 0 {
+			lines = append(lines, `"`+poEscaper.Replace(line)+`"`)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func encodePO(w io.Writer, localeCode string, units []TranslationUnit) error {
+	header := fmt.Sprintf("Language: %s\nMIME-Version: 1.0\nContent-Type: text/plain; charset=UTF-8\nContent-Transfer-Encoding: 8bit\nX-Source-Language: %s\n",
+		localeCode, getDefaultLocale().Code)
+	_, err := io.WriteString(w, poString("msgid", "")+poString("msgstr", header))
+	if err != nil {
+		return err
+	}
+	for _, unit := range units {
+		for _, field := range [][3]string{
+			{"question", unit.SourceQuestion, unit.Question},
+			{"answer", unit.SourceAnswer, unit.Answer},
+		} {
+			entry := "\n" + poString("msgctxt", unit.ID+"/"+field[0]) + poString("msgid", field[1]) + poString("msgstr", field[2])
+			_, err = io.WriteString(w, entry)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type poEntry struct {
+	Context string
+	ID      string
+	Str     string
+	Fuzzy   bool
+	hasStr  bool
+}
+
+// parsePO returns the entries of a PO file, including the header entry.
+// Obsolete entries and plural forms are not supported.
+func parsePO(r io.Reader) ([]poEntry, error) {
+	entries := []poEntry{}
+	entry := poEntry{}
+	var field *string
+	started := false
+	flush := func() {
+		if started {
+			entries = append(entries, entry)
+		}
+		entry, field, started = poEntry{}, nil, false
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportSize)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		keyword := line
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			keyword = line[:i]
+		}
+		if entry.hasStr && (keyword == "msgctxt" || keyword == "msgid" || strings.HasPrefix(line, "#")) {
+			flush()
+		}
+
+		rest := line[len(keyword):]
+		switch {
+		case len(line) == 0:
+			flush()
+			continue
+		case strings.HasPrefix(line, "#,"):
+			entry.Fuzzy = entry.Fuzzy || strings.Contains(line, "fuzzy")
+			continue
+		case strings.HasPrefix(line, "#"):
+			continue
+		case keyword == "msgctxt":
+			field = &entry.Context
+		case keyword == "msgid":
+			field = &entry.ID
+		case keyword == "msgstr":
+			field, entry.hasStr = &entry.Str, true
+		case strings.HasPrefix(line, `"`):
+			if field == nil {
+				return nil, fmt.Errorf("invalid PO: line %d: string outside of an entry", n)
+			}
+			rest = line
+		default:
+			return nil, fmt.Errorf("invalid PO: line %d: unsupported keyword %v", n, keyword)
+		}
+
+		rest = strings.TrimSpace(rest)
+		s, err := strconv.Unquote(rest)
+		if err != nil || !strings.HasPrefix(rest, `"`) {
+			return nil, fmt.Errorf("invalid PO: line %d: invalid string", n)
+		}
+		*field += s
+		started = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid PO: %v", err)
+	}
+	flush()
+	return entries, nil
+}
+
+// poHeader returns the fields of the header entry.
+func poHeader(entries []poEntry) map[string]string {
+	header := make(map[string]string)
+	for _, e := range entries {
+		if len(e.Context) > 0 || len(e.ID) > 0 {
+			continue
+		}
+		for _, line := range strings.Split(e.Str, "\n") {
+			if i := strings.Index(line, ":"); i > 0 {
+				header[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+			}
+		}
+	}
+	return header
+}
+
+// readPO decodes a translated PO file and returns its locale and units.
+// Fuzzy translations are treated as untranslated.
+func readPO(r io.Reader) (string, []TranslationUnit, error) {
+	entries, err := parsePO(r)
+	if err != nil {
+		return "", nil, err
+	}
+	header := poHeader(entries)
+	localeCode := strings.Replace(header["Language"], "_", "-", -1)
+	if source, ok := header["X-Source-Language"]; ok && source != getDefaultLocale().Code {
+		return "", nil, fmt.Errorf("source language must be %v, not %v", getDefaultLocale().Code, source)
+	}
+	if err := validateTargetLocale(localeCode); err != nil {
+		return "", nil, err
+	}
+
+	b := unitBuilder{}
+	for _, e := range entries {
+		if len(e.Context) == 0 && len(e.ID) == 0 {
+			continue
+		}
+		id, field := e.Context, ""
+		if i := strings.LastIndex(e.Context, "/"); i >= 0 {
+			id, field = e.Context[:i], e.Context[i+1:]
+		}
+		target := e.Str
+		if e.Fuzzy {
+			target = ""
+		}
+		b.set(id, field, e.ID, target)
+	}
+	return localeCode, b.units, nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodePO(t *testing.T) {
+	units := []TranslationUnit{TranslationUnit{
+		ID:             "faq-2",
+		FAQID:          2,
+		SourceQuestion: `How do I "pay"?`,
+		SourceAnswer:   "By card.\nOr by invoice.",
+		Question:       "Wie bezahle ich?",
+	}}
+	var po bytes.Buffer
+	expectNoError(t, encodePO(&po, "de", units))
+	expectSameString(t, `msgid ""
+msgstr ""
+"Language: de\n"
+"MIME-Version: 1.0\n"
+"Content-Type: text/plain; charset=UTF-8\n"
+"Content-Transfer-Encoding: 8bit\n"
+"X-Source-Language: en\n"
+
+msgctxt "faq-2/question"
+msgid "How do I \"pay\"?"
+msgstr "Wie bezahle ich?"
+
+msgctxt "faq-2/answer"
+msgid ""
+"By card.\n"
+"Or by invoice."
+msgstr ""
+`, po.String())
+
+	localeCode, read, err := readPO(&po)
+	expectNoError(t, err)
+	expectSameString(t, "de", localeCode)
+	expectSameInt(t, 1, len(read))
+	expectSameInt(t, 2, read[0].FAQID)
+	expectSameString(t, units[0].SourceQuestion, read[0].SourceQuestion)
+	expectSameString(t, units[0].SourceAnswer, read[0].SourceAnswer)
+	expectSameString(t, units[0].Question, read[0].Question)
+	expectSameString(t, "", read[0].Answer)
+}
+
+func TestReadPO(t *testing.T) {
+	po := `# Translators: Anne
+msgid ""
+msgstr "Language: fr_FR\n"
+"X-Source-Language: en\n"
+
+#. An FAQ
+msgctxt "faq-2/question"
+msgid "How do I pay?"
+msgstr "Comment payer ?"
+#, fuzzy
+msgctxt "faq-2/answer"
+msgid "By card."
+msgstr "Par carte."
+
+msgctxt "about"
+msgid "About"
+msgstr "À propos"
+`
+	_, _, err := readPO(strings.NewReader(po))
+	expectSameString(t, "unsupported locale: fr-FR", err.Error())
+
+	localeCode, units, err := readPO(strings.NewReader(strings.Replace(po, "fr_FR", "fr", 1)))
+	expectNoError(t, err)
+	expectSameString(t, "fr", localeCode)
+	expectSameInt(t, 2, len(units))
+	expectSameString(t, "Comment payer ?", units[0].Question)
+	expectSameString(t, "By card.", units[0].SourceAnswer)
+	expectSameString(t, "", units[0].Answer)
+	expectSameString(t, "about", units[1].ID)
+	expectSameInt(t, 0, units[1].FAQID)
+
+	tests := []struct {
+		po  string
+		err string
+	}{
+		{`msgid "a`, "invalid PO: line 1: invalid string"},
+		{`"a"`, "invalid PO: line 1: string outside of an entry"},
+		{"msgid \"a\"\nmsgid_plural \"as\"", "invalid PO: line 2: unsupported keyword msgid_plural"},
+		{"msgid \"\"\nmsgstr \"Language: de\\nX-Source-Language: fr\\n\"", "source language must be en, not fr"},
+		{"msgid \"\"\nmsgstr \"Language: en\\n\"", "cannot translate into the default locale: en"},
+	}
+	for _, tt := range tests {
+		_, _, err := readPO(strings.NewReader(tt.po))
+		if err == nil {
+			t.Errorf("expected error %q for %s", tt.err, tt.po)
+			continue
+		}
+		expectSameString(t, tt.err, err.Error())
+	}
+}
+
+func TestPORoundTrip(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+
+	resp := doRequest("GET", "/api/translations/fr/po", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeaderMatches(t, resp, "Content-Type", `^text/x-gettext-translation; charset=utf-8$`)
+	po := strings.Replace(resp.Body.String(), "msgid \"How do I pay?\"\nmsgstr \"\"", "msgid \"How do I pay?\"\nmsgstr \"Comment payer ?\"", 1)
+	po = strings.Replace(po, "msgid \"By card.\"\nmsgstr \"\"", "msgid \"By card.\"\nmsgstr \"Par carte.\"", 1)
+
+	resp = doRequest("POST", "/api/translations/po", body(po))
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"locale":"fr","saved":[2],"skipped":[{"unit":"faq-5","reason":"not translated"}],"conflicts":[]}`)
+	faq, _ := faqRepository.FAQById(2)
+	expectSameString(t, "Par carte.", faq.TextForLocale("fr").Answer)
+
+	resp = doRequest("GET", "/api/translations/fr/mo", emptyBody())
+	expectErrorJSON(t, resp, 404, "unsupported format")
+	resp = doRequest("POST", "/api/translations/mo", body(po))
+	expectErrorJSON(t, resp, 404, "unsupported format")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// publishedFAQText returns text with its current content marked as published.
+func publishedFAQText(text FAQText) FAQText {
+	now := time.Now()
+	text.PublishedQuestion = text.Question
+	text.PublishedAnswer = text.Answer
+	text.PublishedAt = &now
+	return text
+}
+
+func (db *DB) PublishFAQText(faqID int, localeCode string, author string) error {
+	return withAudit(db.DB, author, auditPublishText, faqTextDetails(faqID, localeCode), func(tx *sql.Tx) error {
+		return publishFAQText(tx, faqID, localeCode)
+	})
+}
+
+func (mdb *mockDB) PublishFAQText(faqID int, localeCode string, author string) error {
+	return nil
+}
+
+func (mdb *brokenDB) PublishFAQText(faqID int, localeCode string, author string) error {
+	return errors.New(someDBError)
+}
+
+func publishFAQText(db dbtx, faqID int, localeCode string) error {
+	sqlStatement := `
+		UPDATE faq_texts SET
+		  published_question = question,
+		  published_answer = answer,
+		  published_at = now()
+		WHERE faq_id = $1 AND locale = $2;`
+	res, err := db.Exec(sqlStatement, faqID, localeCode)
+	if err != nil {
+		logError(err)
+		return err
+	}
+	return expectRowsAffected(res, errFAQTextNotFound)
+}
+
+func postAPIFAQTextPublish(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, ok := faqIDParam(ps)
+	if !ok {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+
+	err := faqRepository.PublishFAQText(faqID, ps.ByName("locale"), apiAuthor(r))
+	if err == errFAQTextNotFound {
+		writeJSONErr(w, http.StatusNotFound, "faq text not found")
+		return
+	}
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	faq, err := faqRepository.FAQById(faqID)
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeJSON(w, faq.Published().TextForLocale(ps.ByName("locale")))
+}
+
+func postAdminFAQsPublish(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, err := strconv.Atoi(r.FormValue("faqID"))
+	if err != nil {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+
+	err = faqRepository.PublishFAQText(faqID, r.FormValue("localeCode"), currentAuthor(r))
+	if err == errFAQTextNotFound {
+		http.Error(w, "faq text not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/admin/faqs/edit/%d", faqID)
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// getAdminFAQsPreview renders the public FAQ page with the current drafts.
+func getAdminFAQsPreview(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	localeCode := ps.ByName("locale")
+	id, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+
+	faq, err := faqRepository.FAQById(id)
+	if err != nil {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+
+	text := faq.ResolvedText(localeCode)
+	data := FAQPageData{
+		PageTitle: text.Question,
+		Text:      text,
+		FAQ:       faq,
+		Locale:    localeFromCode(localeCode),
+		Preview:   true,
+	}
+	mustExecuteTemplateNoLayout(tmplFAQ, w, data)
+}
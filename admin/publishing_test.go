@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+)
+
+// draftDB has unpublished changes in the German text of FAQ 123 and a
+// French text that was never published.
+type draftDB struct {
+	mockDB
+}
+
+func (ddb *draftDB) FAQById(id int) (*FAQ, error) {
+	faq, err := ddb.mockDB.FAQById(id)
+	if err != nil {
+		return nil, err
+	}
+	faq.Texts[1].Question = "Neue Frage?"
+	faq.Texts = append(faq.Texts, FAQText{Locale: Locale{Code: "fr", NameLocal: "français"}, Question: "Question?", Answer: "Réponse!"})
+	return faq, nil
+}
+
+func TestFAQPublished(t *testing.T) {
+	faq, _ := (&draftDB{}).FAQById(123)
+	expectIsTrue(t, faq.HasUnpublishedChanges())
+
+	published := faq.Published()
+	expectSameInt(t, 2, len(published.Texts))
+	expectSameString(t, "Frage?", published.TextForLocale("de").Question)
+	expectSameString(t, "", published.TextForLocale("fr").Question)
+	expectIsTrue(t, !published.HasUnpublishedChanges())
+
+	// The original keeps its drafts
+	expectSameString(t, "Neue Frage?", faq.TextForLocale("de").Question)
+}
+
+func TestGetAPISingleFAQServesPublishedTexts(t *testing.T) {
+	faqRepository = &draftDB{}
+
+	resp := doRequest("GET", "/api/faqs/123", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"id":123,"texts":[{"locale":{"code":"en","name_local":"English"},"question":"question?","answer":"answer!"},{"locale":{"code":"de","name_local":"Deutsch"},"question":"Frage?","answer":"Antwort!"}]}`)
+}
+
+func TestGetSingleFAQHTMLServesPublishedTexts(t *testing.T) {
+	faqRepository = &draftDB{}
+
+	resp := doRequest("GET", "/faq/de/123", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<h1 class="jumbotron-heading">Frage?</h1>`)
+
+	// The unpublished French text falls back to the default locale
+	resp = doRequest("GET", "/faq/fr/123", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<h1 class="jumbotron-heading">question?</h1>`)
+	expectHeader(t, resp, "Content-Language", "en")
+}
+
+func TestGetAdminFAQsPreview(t *testing.T) {
+	faqRepository = &draftDB{}
+
+	resp := doRequest("GET", "/admin/faqs/preview/de/123", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `Preview of unpublished drafts`)
+	expectBodyContains(t, resp, `<h1 class="jumbotron-heading">Neue Frage?</h1>`)
+
+	resp = doRequest("GET", "/admin/faqs/preview/fr/123", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<h1 class="jumbotron-heading">Question?</h1>`)
+}
+
+func TestGetAdminFAQsEditShowsPublishState(t *testing.T) {
+	faqRepository = &draftDB{}
+
+	resp := doRequest("GET", "/admin/faqs/edit/123", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<span class="badge badge-success">Published</span>`)
+	expectBodyContains(t, resp, `<span class="badge badge-warning">Unpublished changes</span>`)
+	expectBodyContains(t, resp, `<span class="badge badge-secondary">Draft</span>`)
+	expectBodyContains(t, resp, `<form action="/admin/faqs/publish" method="post" class="mb-4">`)
+	expectBodyContains(t, resp, `href="/admin/faqs/preview/de/123"`)
+}
+
+func TestPostAdminFAQsPublish(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("POST", "/admin/faqs/publish", body("faqID=123&localeCode=de"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/faqs/edit/123")
+
+	faqRepository = &brokenDB{}
+	resp = doRequestWithHeader("POST", "/admin/faqs/publish", body("faqID=123&localeCode=de"), formHeader())
+	expectStatus(t, resp, 500)
+}
+
+func TestPostAPIFAQTextPublish(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("POST", "/api/faqs/123/texts/de/publish", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"locale":{"code":"de","name_local":"Deutsch"},"question":"Frage?","answer":"Antwort!"}`)
+
+	resp = doRequest("POST", "/api/faqs/abc/texts/de/publish", emptyBody())
+	expectErrorJSON(t, resp, 404, "faq not found")
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("POST", "/api/faqs/123/texts/de/publish", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestPublishFAQText(t *testing.T) {
+	repo := prepareDB()
+
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+
+	err = repo.PublishFAQText(f.ID, "en", testAuthor)
+	expectSameError(t, errFAQTextNotFound, err)
+
+	txt := FAQText{Question: "question", Answer: "answer", Locale: Locale{Code: "en"}}
+	err = repo.SaveFAQText(f.ID, &txt)
+	expectNoError(t, err)
+
+	f2, err := repo.FAQById(f.ID)
+	expectNoError(t, err)
+	expectIsTrue(t, !f2.Texts[0].IsPublished())
+	expectSameInt(t, 0, len(f2.Published().Texts))
+
+	err = repo.PublishFAQText(f.ID, "en", testAuthor)
+	expectNoError(t, err)
+
+	txt.Question = "new question"
+	err = repo.SaveFAQText(f.ID, &txt)
+	expectNoError(t, err)
+
+	f2, err = repo.FAQById(f.ID)
+	expectNoError(t, err)
+	expectSameString(t, "new question", f2.Texts[0].Question)
+	expectSameString(t, "question", f2.Published().Texts[0].Question)
+}
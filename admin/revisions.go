@@ -0,0 +1,462 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// FAQTextRevision is an entry in the append-only history of an FAQ text.
+// Every save of a text adds one.
+type FAQTextRevision struct {
+	ID        int       `json:"id"`
+	FAQID     int       `json:"faq_id"`
+	Locale    Locale    `json:"locale"`
+	Question  string    `json:"question"`
+	Answer    string    `json:"answer"`
+	Author    string    `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// SourceVersion is the source version of the text as saved, 0 if
+	// unknown.
+	SourceVersion int `json:"source_version,omitempty"`
+}
+
+var errRevisionNotFound = errors.New("revision not found")
+
+func (db *DB) FAQTextRevisions(faqID int, localeCode string) ([]FAQTextRevision, error) {
+	return getFAQTextRevisions(db.DB, faqID, localeCode)
+}
+
+func (db *DB) FAQTextRevision(revisionID int) (*FAQTextRevision, error) {
+	return getFAQTextRevision(db.DB, revisionID)
+}
+
+func (mdb *mockDB) FAQTextRevisions(faqID int, localeCode string) ([]FAQTextRevision, error) {
+	created := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	loc := Locale{Code: "de", NameLocal: "Deutsch"}
+	return []FAQTextRevision{
+		FAQTextRevision{ID: 2, FAQID: 123, Locale: loc, Question: "Frage?", Answer: "Antwort!", Author: "admin", CreatedAt: created.Add(time.Hour)},
+		FAQTextRevision{ID: 1, FAQID: 123, Locale: loc, Question: "Frage", Answer: "Eine Antwort", Author: "api", CreatedAt: created},
+	}, nil
+}
+
+func (mdb *mockDB) FAQTextRevision(revisionID int) (*FAQTextRevision, error) {
+	revisions, _ := mdb.FAQTextRevisions(123, "de")
+	for _, rev := range revisions {
+		if rev.ID == revisionID {
+			return &rev, nil
+		}
+	}
+	return nil, errRevisionNotFound
+}
+
+func (mdb *brokenDB) FAQTextRevisions(faqID int, localeCode string) ([]FAQTextRevision, error) {
+	return nil, errors.New(someDBError)
+}
+
+func (mdb *brokenDB) FAQTextRevision(revisionID int) (*FAQTextRevision, error) {
+	return nil, errors.New(someDBError)
+}
+
+const revisionColumns = `id, faq_id, locale, question, answer, author, created_at, source_version`
+
+func scanRevision(row interface {
+	Scan(dest ...interface{}) error
+}) (*FAQTextRevision, error) {
+	rev := FAQTextRevision{}
+	var localeCode string
+	var author sql.NullString
+	var sourceVersion sql.NullInt64
+	err := row.Scan(&rev.ID, &rev.FAQID, &localeCode, &rev.Question, &rev.Answer, &author, &rev.CreatedAt, &sourceVersion)
+	if err != nil {
+		return nil, err
+	}
+	rev.Locale = localeFromCode(localeCode)
+	rev.Author = author.String
+	rev.SourceVersion = int(sourceVersion.Int64)
+	return &rev, nil
+}
+
+func getFAQTextRevisions(db *sql.DB, faqID int, localeCode string) ([]FAQTextRevision, error) {
+	rows, err := db.Query(`
+		SELECT `+revisionColumns+`
+		FROM faq_text_revisions
+		WHERE faq_id = $1 AND locale = $2
+		ORDER BY id DESC;`, faqID, localeCode)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []FAQTextRevision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			logError(err)
+			return nil, err
+		}
+		revisions = append(revisions, *rev)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+func getFAQTextRevision(db *sql.DB, revisionID int) (*FAQTextRevision, error) {
+	row := db.QueryRow(`SELECT `+revisionColumns+` FROM faq_text_revisions WHERE id = $1;`, revisionID)
+	rev, err := scanRevision(row)
+	if err == sql.ErrNoRows {
+		return nil, errRevisionNotFound
+	}
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	return rev, nil
+}
+
+///// Diffs
+
+const (
+	diffEqual  = "equal"
+	diffInsert = "insert"
+	diffDelete = "delete"
+)
+
+type DiffChunk struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+func (c DiffChunk) IsInsert() bool { return c.Op == diffInsert }
+func (c DiffChunk) IsDelete() bool { return c.Op == diffDelete }
+
+type RevisionDiff struct {
+	From     int         `json:"from"`
+	To       int         `json:"to"`
+	Question []DiffChunk `json:"question"`
+	Answer   []DiffChunk `json:"answer"`
+}
+
+func diffRevisions(from *FAQTextRevision, to *FAQTextRevision) RevisionDiff {
+	return RevisionDiff{
+		From:     from.ID,
+		To:       to.ID,
+		Question: diffWords(from.Question, to.Question),
+		Answer:   diffWords(from.Answer, to.Answer),
+	}
+}
+
+// maxDiffCells caps the size of the LCS table of diffWords, about 8 MB.
+// Larger changes are shown as a replacement of the whole changed part.
+const maxDiffCells = 1 << 20
+
+// diffWords computes a word level diff of a and b based on their longest
+// common subsequence. Runs of whitespace count as words of their own. Common
+// leading and trailing words are matched before the LCS is computed for the
+// words in between.
+func diffWords(a string, b string) []DiffChunk {
+	x := splitWords(a)
+	y := splitWords(b)
+
+	chunks := []DiffChunk{}
+	add := func(op string, word string) {
+		if n := len(chunks); n > 0 && chunks[n-1].Op == op {
+			chunks[n-1].Text += word
+			return
+		}
+		chunks = append(chunks, DiffChunk{Op: op, Text: word})
+	}
+
+	prefix := 0
+	for prefix < len(x) && prefix < len(y) && x[prefix] == y[prefix] {
+		add(diffEqual, x[prefix])
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(x)-prefix && suffix < len(y)-prefix && x[len(x)-1-suffix] == y[len(y)-1-suffix] {
+		suffix++
+	}
+
+	diffWordsLCS(x[prefix:len(x)-suffix], y[prefix:len(y)-suffix], add)
+	for _, word := range x[len(x)-suffix:] {
+		add(diffEqual, word)
+	}
+	return chunks
+}
+
+func diffWordsLCS(x []string, y []string, add func(op string, word string)) {
+	if int64(len(x))*int64(len(y)) > maxDiffCells {
+		for _, word := range x {
+			add(diffDelete, word)
+		}
+		for _, word := range y {
+			add(diffInsert, word)
+		}
+		return
+	}
+
+	// lcs[i][j] is the length of the LCS of x[i:] and y[j:]
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			add(diffEqual, x[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			add(diffDelete, x[i])
+			i++
+		default:
+			add(diffInsert, y[j])
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		add(diffDelete, x[i])
+	}
+	for ; j < len(y); j++ {
+		add(diffInsert, y[j])
+	}
+}
+
+func splitWords(s string) []string {
+	words := []string{}
+	start := 0
+	inSpace := false
+	for i, r := range s {
+		isSpace := strings.ContainsRune(" \t\r\n", r)
+		if i > start && isSpace != inSpace {
+			words = append(words, s[start:i])
+			start = i
+		}
+		inSpace = isSpace
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+///// Revision handlers
+
+// revisionForText loads a revision and checks that it belongs to the text.
+func revisionForText(revisionID int, faqID int, localeCode string) (*FAQTextRevision, error) {
+	rev, err := faqRepository.FAQTextRevision(revisionID)
+	if err != nil {
+		return nil, err
+	}
+	if rev.FAQID != faqID || rev.Locale.Code != localeCode {
+		return nil, errRevisionNotFound
+	}
+	return rev, nil
+}
+
+// restoreRevision saves the content of rev as the current draft, which in
+// turn records a new revision. A restored translation keeps the source version
+// of rev, so it is outdated if the source has been saved since.
+func restoreRevision(rev *FAQTextRevision, author string) (*FAQText, error) {
+	text := FAQText{
+		Locale:            localeFromCode(rev.Locale.Code),
+		Question:          rev.Question,
+		Answer:            rev.Answer,
+		Author:            author,
+		SourceVersion:     rev.SourceVersion,
+		keepSourceVersion: true,
+	}
+	err := faqRepository.SaveFAQText(rev.FAQID, &text)
+	if err != nil {
+		return nil, err
+	}
+	return &text, nil
+}
+
+func getAPIFAQTextRevisions(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, ok := faqIDParam(ps)
+	if !ok {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+
+	revisions, err := faqRepository.FAQTextRevisions(faqID, ps.ByName("locale"))
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeJSON(w, revisions)
+}
+
+func getAPIFAQTextDiff(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, ok := faqIDParam(ps)
+	if !ok {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+	fromID, err := strconv.Atoi(r.FormValue("from"))
+	if err != nil {
+		writeJSONErr(w, http.StatusBadRequest, "from param invalid")
+		return
+	}
+	toID, err := strconv.Atoi(r.FormValue("to"))
+	if err != nil {
+		writeJSONErr(w, http.StatusBadRequest, "to param invalid")
+		return
+	}
+
+	revisions := []*FAQTextRevision{}
+	for _, id := range []int{fromID, toID} {
+		rev, err := revisionForText(id, faqID, ps.ByName("locale"))
+		if err == errRevisionNotFound {
+			writeJSONErr(w, http.StatusNotFound, "revision not found")
+			return
+		}
+		if err != nil {
+			writeJSONErr(w, http.StatusInternalServerError, internalError)
+			return
+		}
+		revisions = append(revisions, rev)
+	}
+
+	writeJSON(w, diffRevisions(revisions[0], revisions[1]))
+}
+
+func postAPIFAQTextRevisionRestore(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, ok := faqIDParam(ps)
+	if !ok {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+	revisionID, err := strconv.Atoi(ps.ByName("revision"))
+	if err != nil {
+		writeJSONErr(w, http.StatusNotFound, "revision not found")
+		return
+	}
+
+	rev, err := revisionForText(revisionID, faqID, ps.ByName("locale"))
+	if err == errRevisionNotFound {
+		writeJSONErr(w, http.StatusNotFound, "revision not found")
+		return
+	}
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	text, err := restoreRevision(rev, apiAuthor(r))
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeJSON(w, text)
+}
+
+type RevisionsPageData struct {
+	PageTitle string
+	MenuBar   []MenuEntry
+	FAQID     int
+	Locale    Locale
+	Revisions []FAQTextRevision
+	Diff      *RevisionDiff
+}
+
+func getAdminFAQsRevisions(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, err := strconv.Atoi(ps.ByName("id"))
+	if err != nil {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+	localeCode := ps.ByName("locale")
+
+	revisions, err := faqRepository.FAQTextRevisions(faqID, localeCode)
+	if err != nil {
+		panic(err)
+	}
+
+	data := RevisionsPageData{
+		PageTitle: "Admin / Revisions",
+		MenuBar:   menuBar("FAQs"),
+		FAQID:     faqID,
+		Locale:    localeFromCode(localeCode),
+		Revisions: revisions,
+	}
+
+	fromID, fromErr := strconv.Atoi(r.FormValue("from"))
+	toID, toErr := strconv.Atoi(r.FormValue("to"))
+	if fromErr == nil && toErr == nil {
+		var from, to *FAQTextRevision
+		for i := range revisions {
+			if revisions[i].ID == fromID {
+				from = &revisions[i]
+			}
+			if revisions[i].ID == toID {
+				to = &revisions[i]
+			}
+		}
+		if from != nil && to != nil {
+			diff := diffRevisions(from, to)
+			data.Diff = &diff
+		}
+	}
+
+	mustExecuteTemplate(tmplAdminRevisions, w, data)
+}
+
+func postAdminFAQsRevisionsRestore(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	revisionID, err := strconv.Atoi(r.FormValue("revisionID"))
+	if err != nil {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+
+	rev, err := faqRepository.FAQTextRevision(revisionID)
+	if err == errRevisionNotFound {
+		http.Error(w, "revision not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	if !canEditLocale(r, rev.Locale.Code) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	_, err = restoreRevision(rev, currentAuthor(r))
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/admin/faqs/edit/%d", rev.FAQID)
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected []DiffChunk
+	}{
+		{a: "", b: "", expected: []DiffChunk{}},
+		{a: "same text", b: "same text", expected: []DiffChunk{{Op: diffEqual, Text: "same text"}}},
+		{a: "", b: "new", expected: []DiffChunk{{Op: diffInsert, Text: "new"}}},
+		{a: "old", b: "", expected: []DiffChunk{{Op: diffDelete, Text: "old"}}},
+		{
+			a: "Pay by card or cash.",
+			b: "Pay by card or invoice.",
+			expected: []DiffChunk{
+				{Op: diffEqual, Text: "Pay by card or "},
+				{Op: diffDelete, Text: "cash."},
+				{Op: diffInsert, Text: "invoice."},
+			},
+		},
+		{
+			a: "one two three",
+			b: "one three four",
+			expected: []DiffChunk{
+				{Op: diffEqual, Text: "one "},
+				{Op: diffDelete, Text: "two "},
+				{Op: diffEqual, Text: "three"},
+				{Op: diffInsert, Text: " four"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		chunks := diffWords(test.a, test.b)
+		if !reflect.DeepEqual(test.expected, chunks) {
+			t.Errorf("diffWords(%q, %q) = %v, wanted %v", test.a, test.b, chunks, test.expected)
+		}
+	}
+}
+
+func TestDiffWordsLargeTexts(t *testing.T) {
+	a := strings.TrimSpace(strings.Repeat("old ", 2000))
+	b := strings.TrimSpace(strings.Repeat("new ", 2000))
+	chunks := diffWords("Start "+a+" end.", "Start "+b+" end.")
+	expected := []DiffChunk{
+		{Op: diffEqual, Text: "Start "},
+		{Op: diffDelete, Text: a},
+		{Op: diffInsert, Text: b},
+		{Op: diffEqual, Text: " end."},
+	}
+	if !reflect.DeepEqual(expected, chunks) {
+		t.Errorf("unexpected diff of large texts with %d chunks", len(chunks))
+	}
+}
+
+func TestGetAPIFAQTextRevisions(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("GET", "/api/faqs/123/texts/de/revisions", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Type", "application/json")
+	expectBodyContains(t, resp, `[{"id":2,"faq_id":123,"locale":{"code":"de","name_local":"Deutsch"},"question":"Frage?","answer":"Antwort!","author":"admin","created_at":"2018-06-01T13:00:00Z"},`)
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("GET", "/api/faqs/123/texts/de/revisions", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestGetAPIFAQTextDiff(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("GET", "/api/faqs/123/texts/de/diff?from=1&to=2", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"from":1,"to":2,"question":[{"op":"delete","text":"Frage"},{"op":"insert","text":"Frage?"}],"answer":[{"op":"delete","text":"Eine Antwort"},{"op":"insert","text":"Antwort!"}]}`)
+
+	resp = doRequest("GET", "/api/faqs/123/texts/de/diff?from=1", emptyBody())
+	expectErrorJSON(t, resp, 400, "to param invalid")
+
+	resp = doRequest("GET", "/api/faqs/123/texts/de/diff?from=1&to=3", emptyBody())
+	expectErrorJSON(t, resp, 404, "revision not found")
+
+	// Revisions of another text
+	resp = doRequest("GET", "/api/faqs/123/texts/en/diff?from=1&to=2", emptyBody())
+	expectErrorJSON(t, resp, 404, "revision not found")
+}
+
+func TestPostAPIFAQTextRevisionRestore(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("POST", "/api/faqs/123/texts/de/revisions/1/restore", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"locale":{"code":"de","name_en":"German","name_local":"Deutsch"},"question":"Frage","answer":"Eine Antwort"}`)
+
+	resp = doRequest("POST", "/api/faqs/456/texts/de/revisions/1/restore", emptyBody())
+	expectErrorJSON(t, resp, 404, "revision not found")
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("POST", "/api/faqs/123/texts/de/revisions/1/restore", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestGetAdminFAQsRevisions(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("GET", "/admin/faqs/revisions/123/de", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<title>Admin / Revisions</title>`)
+	expectBodyContains(t, resp, `<td>2018-06-01 13:00</td>`)
+	expectBodyContains(t, resp, `<td>api</td>`)
+	expectBodyContains(t, resp, `<input type="hidden" name="revisionID" value="1">`)
+
+	resp = doRequest("GET", "/admin/faqs/revisions/123/de?from=1&to=2", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<h3>Changes from #1 to #2</h3>`)
+	expectBodyContains(t, resp, `<del class="bg-danger text-white">Eine Antwort</del><ins class="bg-success text-white">Antwort!</ins>`)
+}
+
+func TestPostAdminFAQsRevisionsRestore(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("POST", "/admin/faqs/revisions/restore", body("revisionID=1"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/faqs/edit/123")
+
+	resp = doRequestWithHeader("POST", "/admin/faqs/revisions/restore", body("revisionID=9"), formHeader())
+	expectStatus(t, resp, 404)
+}
+
+func TestRevisions(t *testing.T) {
+	repo := prepareDB()
+
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+
+	txt := FAQText{Question: "question", Answer: "answer", Locale: Locale{Code: "en"}, Author: "alice"}
+	err = repo.SaveFAQText(f.ID, &txt)
+	expectNoError(t, err)
+
+	txt = FAQText{Question: "question", Answer: "better answer", Locale: Locale{Code: "en"}, Author: "bob"}
+	err = repo.SaveFAQText(f.ID, &txt)
+	expectNoError(t, err)
+
+	revisions, err := repo.FAQTextRevisions(f.ID, "en")
+	expectNoError(t, err)
+	expectSameInt(t, 2, len(revisions))
+	expectSameString(t, "better answer", revisions[0].Answer)
+	expectSameString(t, "bob", revisions[0].Author)
+	expectSameString(t, "answer", revisions[1].Answer)
+	expectSameString(t, "alice", revisions[1].Author)
+
+	rev, err := repo.FAQTextRevision(revisions[1].ID)
+	expectNoError(t, err)
+	expectSameString(t, "answer", rev.Answer)
+
+	_, err = repo.FAQTextRevision(revisions[0].ID + 1000)
+	expectSameError(t, errRevisionNotFound, err)
+
+	// Revisions outlive their FAQ
+	err = repo.DeleteFAQ(f.ID, testAuthor)
+	expectNoError(t, err)
+	revisions, err = repo.FAQTextRevisions(f.ID, "en")
+	expectNoError(t, err)
+	expectSameInt(t, 2, len(revisions))
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"golang.org/x/text/language"
+)
+
+// defaultSearchConfig is the Postgres text search configuration used for
+// languages without stemming support. It only lowercases words.
+const defaultSearchConfig = "simple"
+
+// searchConfigs maps base languages to the text search configurations
+// shipped with Postgres.
+var searchConfigs = map[string]string{
+	"ar": "arabic",
+	"da": "danish",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fi": "finnish",
+	"fr": "french",
+	"hu": "hungarian",
+	"it": "italian",
+	"nl": "dutch",
+	"no": "norwegian",
+	"nb": "norwegian",
+	"nn": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+}
+
+// installedSearchConfigs is the set of configurations available in the
+// connected database. Older Postgres versions lack some of searchConfigs.
+var installedSearchConfigs map[string]bool
+
+// SearchConfig returns the Postgres text search configuration for the locale.
+func (l *Locale) SearchConfig() string {
+	return searchConfig(l.Code)
+}
+
+func searchConfig(localeCode string) string {
+	tag, err := language.Parse(localeCode)
+	if err != nil {
+		return defaultSearchConfig
+	}
+	base, _ := tag.Base()
+	config, ok := searchConfigs[base.String()]
+	if !ok {
+		return defaultSearchConfig
+	}
+	if installedSearchConfigs != nil && !installedSearchConfigs[config] {
+		return defaultSearchConfig
+	}
+	return config
+}
+
+func loadSearchConfigs(db *sql.DB) error {
+	rows, err := db.Query(`SELECT cfgname FROM pg_ts_config;`)
+	if err != nil {
+		logError(err)
+		return err
+	}
+	defer rows.Close()
+
+	configs := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return err
+		}
+		configs[name] = true
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+
+	installedSearchConfigs = configs
+	return nil
+}
+
+// searchIndexInterval is how often the vocabulary for search suggestions is
+// rebuilt. Newly published words are suggested after at most this delay.
+const searchIndexInterval = 5 * time.Minute
+
+func updateSearchIndexPeriodically(repo FAQRepository, interval time.Duration) {
+	for {
+		time.Sleep(interval)
+		repo.UpdateSearchIndex()
+	}
+}
+
+// maxFuzzyResults limits fuzzy searches, which match far more loosely than
+// full-text searches.
+const maxFuzzyResults = 20
+
+// Matches are delimited by characters from the Unicode private use area in
+// the database, so that snippets can be HTML escaped before the delimiters
+// are turned into <mark> tags.
+const (
+	highlightStart = "\ue000"
+	highlightStop  = "\ue001"
+)
+
+// Options for ts_headline. Questions are highlighted in full, answers are
+// shortened to the fragments around the matches.
+const (
+	questionHeadlineOptions = "HighlightAll=true, StartSel=\"" + highlightStart + "\", StopSel=\"" + highlightStop + "\""
+	answerHeadlineOptions   = "MaxFragments=2, MaxWords=25, MinWords=10, FragmentDelimiter=\" … \", StartSel=\"" + highlightStart + "\", StopSel=\"" + highlightStop + "\""
+)
+
+// markHighlights HTML escapes snippet and wraps its highlighted matches in
+// <mark> tags.
+func markHighlights(snippet string) string {
+	s := html.EscapeString(snippet)
+	s = strings.Replace(s, highlightStart, "<mark>", -1)
+	return strings.Replace(s, highlightStop, "</mark>", -1)
+}
+
+// SearchResult is a published FAQ text matching a search query. The snippets
+// are HTML escaped text with matched words wrapped in <mark> tags.
+type SearchResult struct {
+	FAQID           int     `json:"faq_id"`
+	Text            FAQText `json:"text"`
+	QuestionSnippet string  `json:"question_snippet"`
+	AnswerSnippet   string  `json:"answer_snippet"`
+	Rank            float64 `json:"rank"`
+	ResolvedLocale  string  `json:"resolved_locale,omitempty"` // Locale of Text
+}
+
+// SearchResponse is the body of /api/search-faqs. Fuzzy is set when the
+// results come from trigram matching instead of the full-text index.
+type SearchResponse struct {
+	Results    []SearchResult `json:"results"`
+	Fuzzy      bool           `json:"fuzzy"`
+	DidYouMean string         `json:"did_you_mean,omitempty"`
+}
+
+// searchWithFallbacks runs search in each locale of the fallback chain of
+// localeCode. Results in a fallback locale are kept for FAQs that are served
+// in that locale, so that each FAQ is found in the language it is shown in.
+// Results are ordered by locale first, then by rank.
+func searchWithFallbacks(search func(language string, query string) ([]SearchResult, error), localeCode string, query string) ([]SearchResult, error) {
+	chain := fallbackChain(localeCode)
+	found := make([][]SearchResult, len(chain))
+	fallbackIDs := []int{}
+	for i, code := range chain {
+		var err error
+		found[i], err = search(code, query)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			continue
+		}
+		for _, result := range found[i] {
+			fallbackIDs = append(fallbackIDs, result.FAQID)
+		}
+	}
+
+	served := map[int]string{} // Locale served by FAQ id
+	if len(fallbackIDs) > 0 {
+		var err error
+		served, err = servedLocales(localeCode, fallbackIDs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := []SearchResult{}
+	for i, code := range chain {
+		for _, result := range found[i] {
+			if i == 0 || served[result.FAQID] == code {
+				result.ResolvedLocale = code
+				results = append(results, result)
+			}
+		}
+	}
+	return results, nil
+}
+
+// servedLocales returns the locale of the published text served for
+// localeCode by FAQ id, for the FAQs with faqIDs.
+func servedLocales(localeCode string, faqIDs []int) (map[int]string, error) {
+	published, err := faqRepository.PublishedLocales(faqIDs)
+	if err != nil {
+		return nil, err
+	}
+	served := make(map[int]string)
+	for id, codes := range published {
+		for _, code := range fallbackChain(localeCode) {
+			if containsString(codes, code) {
+				served[id] = code
+				break
+			}
+		}
+	}
+	return served, nil
+}
+
+func (db *DB) PublishedLocales(faqIDs []int) (map[int][]string, error) {
+	return getPublishedLocales(db.DB, faqIDs)
+}
+
+func (db *DB) FuzzySearchFAQs(language string, query string) ([]SearchResult, error) {
+	return fuzzySearchFAQs(db.DB, language, query)
+}
+
+func (db *DB) SearchSuggestion(language string, query string) (string, error) {
+	return searchSuggestion(db.DB, language, query)
+}
+
+func (mdb *mockDB) FuzzySearchFAQs(language string, query string) ([]SearchResult, error) {
+	return mdb.SearchFAQs(language, query)
+}
+
+func (mdb *mockDB) SearchSuggestion(language string, query string) (string, error) {
+	return "", nil
+}
+
+func (mdb *mockDB) PublishedLocales(faqIDs []int) (map[int][]string, error) {
+	published := make(map[int][]string)
+	for _, id := range faqIDs {
+		faq, err := mdb.FAQById(id)
+		if err != nil {
+			continue
+		}
+		for _, text := range faq.Published().Texts {
+			if len(text.Question) > 0 {
+				published[id] = append(published[id], text.Locale.Code)
+			}
+		}
+	}
+	return published, nil
+}
+
+func (mdb *brokenDB) PublishedLocales(faqIDs []int) (map[int][]string, error) {
+	return nil, errors.New(someDBError)
+}
+
+func (mdb *brokenDB) FuzzySearchFAQs(language string, query string) ([]SearchResult, error) {
+	return nil, errors.New(someDBError)
+}
+
+func (mdb *brokenDB) SearchSuggestion(language string, query string) (string, error) {
+	return "", errors.New(someDBError)
+}
+
+func searchFAQs(db *sql.DB, lang string, query string) ([]SearchResult, error) {
+	rows, err := db.Query(`
+		SELECT faq_texts.faq_id, faq_texts.locale,
+		       faq_texts.published_question, faq_texts.published_answer,
+		       ts_headline($3::regconfig, coalesce(faq_texts.published_question, ''), query, $4),
+		       ts_headline($3::regconfig, coalesce(faq_texts.published_answer, ''), query, $5),
+		       ts_rank(faq_texts.document, query) AS rank
+		FROM faq_texts
+		JOIN faqs ON faqs.id = faq_texts.faq_id AND faqs.deleted_at IS NULL,
+		     plainto_tsquery($3::regconfig, $1) query
+		WHERE faq_texts.document @@ query
+		AND faq_texts.locale = $2
+		ORDER BY rank DESC;`, query, lang, searchConfig(lang), questionHeadlineOptions, answerHeadlineOptions)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSearchResults(rows)
+}
+
+// getPublishedLocales returns the locales with a published question by FAQ
+// id, for the FAQs with faqIDs.
+func getPublishedLocales(db *sql.DB, faqIDs []int) (map[int][]string, error) {
+	ids := make([]int64, len(faqIDs))
+	for i, id := range faqIDs {
+		ids[i] = int64(id)
+	}
+
+	rows, err := db.Query(`
+		SELECT faq_id, locale FROM faq_texts
+		WHERE faq_id = ANY($1) AND published_at IS NOT NULL AND published_question <> '';`, pq.Array(ids))
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPublishedLocales(rows)
+}
+
+// scanPublishedLocales reads rows of faq_id and locale, by FAQ id.
+func scanPublishedLocales(rows *sql.Rows) (map[int][]string, error) {
+	published := make(map[int][]string)
+	for rows.Next() {
+		var faqID int
+		var localeCode string
+		err := rows.Scan(&faqID, &localeCode)
+		if err != nil {
+			logError(err)
+			return nil, err
+		}
+		published[faqID] = append(published[faqID], localeCode)
+	}
+	return published, rows.Err()
+}
+
+// fuzzySearchFAQs finds FAQs whose published text contains words similar to
+// the query, e.g. "pasword" finds "password". The rank is the trigram
+// similarity.
+func fuzzySearchFAQs(db *sql.DB, lang string, query string) ([]SearchResult, error) {
+	rows, err := db.Query(`
+		SELECT faq_texts.faq_id, faq_texts.locale,
+		       faq_texts.published_question, faq_texts.published_answer,
+		       ts_headline($4::regconfig, coalesce(faq_texts.published_question, ''), plainto_tsquery($4::regconfig, $1), $5),
+		       ts_headline($4::regconfig, coalesce(faq_texts.published_answer, ''), plainto_tsquery($4::regconfig, $1), $6),
+		       word_similarity($1, coalesce(faq_texts.published_question, '') || ' ' || coalesce(faq_texts.published_answer, '')) AS rank
+		FROM faq_texts
+		JOIN faqs ON faqs.id = faq_texts.faq_id AND faqs.deleted_at IS NULL
+		WHERE faq_texts.published_at IS NOT NULL
+		AND faq_texts.locale = $2
+		AND $1 <% (coalesce(faq_texts.published_question, '') || ' ' || coalesce(faq_texts.published_answer, ''))
+		ORDER BY rank DESC
+		LIMIT $3;`, query, lang, maxFuzzyResults, searchConfig(lang), questionHeadlineOptions, answerHeadlineOptions)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSearchResults(rows)
+}
+
+func scanSearchResults(rows *sql.Rows) ([]SearchResult, error) {
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		var localeCode string
+		var question, answer sql.NullString
+		err := rows.Scan(&result.FAQID, &localeCode, &question, &answer,
+			&result.QuestionSnippet, &result.AnswerSnippet, &result.Rank)
+		if err != nil {
+			return nil, err
+		}
+		result.QuestionSnippet = markHighlights(result.QuestionSnippet)
+		result.AnswerSnippet = markHighlights(result.AnswerSnippet)
+		result.Text = FAQText{
+			Locale:   localeFromCode(localeCode),
+			Question: question.String,
+			Answer:   answer.String,
+		}
+		results = append(results, result)
+	}
+
+	err := rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// searchSuggestion replaces each word of the query with the most similar word
+// of the published texts in that language. It returns an empty string if no
+// word needs correcting.
+func searchSuggestion(db *sql.DB, lang string, query string) (string, error) {
+	words := strings.Fields(strings.ToLower(query))
+	corrected := false
+	for i, word := range words {
+		var suggestion string
+		err := db.QueryRow(`
+			SELECT word FROM search_words
+			WHERE locale = $2 AND word % $1
+			ORDER BY similarity(word, $1) DESC, word
+			LIMIT 1;`, word, lang).Scan(&suggestion)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			logError(err)
+			return "", err
+		}
+		if suggestion != word {
+			words[i] = suggestion
+			corrected = true
+		}
+	}
+
+	if !corrected {
+		return "", nil
+	}
+	return strings.Join(words, " "), nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestSearchConfig(t *testing.T) {
+	tests := []struct {
+		localeCode string
+		expected   string
+	}{
+		{"en", "english"},
+		{"de", "german"},
+		{"pt-BR", "portuguese"},
+		{"no", "norwegian"},
+		{"zh", "simple"},
+		{"not a locale", "simple"},
+	}
+
+	for _, test := range tests {
+		expectSameString(t, test.expected, searchConfig(test.localeCode))
+	}
+}
+
+func TestSearchConfigNotInstalled(t *testing.T) {
+	installedSearchConfigs = map[string]bool{"simple": true, "english": true}
+	defer func() { installedSearchConfigs = nil }()
+
+	expectSameString(t, "english", searchConfig("en"))
+	expectSameString(t, "simple", searchConfig("ar"))
+}
+
+func TestGetAdminLocalesShowsSearchConfig(t *testing.T) {
+	faqRepository = &mockDB{}
+	resp := doRequest("GET", "/admin/locales", emptyBody())
+
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<td>german</td>`)
+}
+
+func TestLanguageAwareSearch(t *testing.T) {
+	repo := preparePostgresDB(t)
+
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+
+	en := FAQText{Question: "How do I make a payment?", Answer: "By card.", Locale: Locale{Code: "en"}}
+	err = repo.SaveFAQText(f.ID, &en)
+	expectNoError(t, err)
+	de := FAQText{Question: "Wie leiste ich eine Zahlung?", Answer: "Per Karte.", Locale: Locale{Code: "de"}}
+	err = repo.SaveFAQText(f.ID, &de)
+	expectNoError(t, err)
+	expectNoError(t, repo.PublishFAQText(f.ID, "en", testAuthor))
+	expectNoError(t, repo.PublishFAQText(f.ID, "de", testAuthor))
+
+	results, err := repo.SearchFAQs("en", "payments")
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(results))
+	expectSameString(t, "How do I make a <mark>payment</mark>?", results[0].QuestionSnippet)
+
+	results, err = repo.SearchFAQs("de", "Zahlungen")
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(results))
+}
+
+// misspelledDB finds nothing with full-text search but knows the word
+// "password".
+type misspelledDB struct {
+	mockDB
+}
+
+func (mdb *misspelledDB) SearchFAQs(language string, query string) ([]SearchResult, error) {
+	return []SearchResult{}, nil
+}
+
+func (mdb *misspelledDB) SearchSuggestion(language string, query string) (string, error) {
+	return "password", nil
+}
+
+func TestGetAPISearchFAQFuzzyFallback(t *testing.T) {
+	faqRepository = &misspelledDB{}
+
+	resp := doRequest("GET", "/api/search-faqs?lang=en&query=pasword", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"fuzzy":true,"did_you_mean":"password"}`)
+	expectBodyContains(t, resp, `{"results":[{"faq_id":123,`)
+}
+
+func TestGetAPISearchFAQFuzzyRequested(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequest("GET", "/api/search-faqs?lang=en&query=bar&fuzzy=true", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"fuzzy":true}`)
+}
+
+func TestFuzzySearch(t *testing.T) {
+	repo := preparePostgresDB(t)
+
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+	txt := FAQText{Question: "How do I reset my password?", Answer: "Request a refund link.", Locale: Locale{Code: "en"}}
+	err = repo.SaveFAQText(f.ID, &txt)
+	expectNoError(t, err)
+	expectNoError(t, repo.PublishFAQText(f.ID, "en", testAuthor))
+	expectNoError(t, repo.UpdateSearchIndex())
+
+	results, err := repo.SearchFAQs("en", "pasword")
+	expectNoError(t, err)
+	expectSameInt(t, 0, len(results))
+
+	results, err = repo.FuzzySearchFAQs("en", "pasword")
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(results))
+
+	suggestion, err := repo.SearchSuggestion("en", "reset pasword")
+	expectNoError(t, err)
+	expectSameString(t, "reset password", suggestion)
+
+	suggestion, err = repo.SearchSuggestion("en", "password")
+	expectNoError(t, err)
+	expectSameString(t, "", suggestion)
+}
+
+func testSearchEscapesSnippets(t *testing.T, repo FAQRepository) {
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+	txt := FAQText{Question: "Is <b>bold</b> allowed?", Answer: `No, <script>alert("payment")</script> is escaped.`, Locale: Locale{Code: "en"}}
+	expectNoError(t, repo.SaveFAQText(f.ID, &txt))
+	expectNoError(t, repo.PublishFAQText(f.ID, "en", testAuthor))
+
+	results, err := repo.SearchFAQs("en", "bold payment")
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(results))
+	expectSameString(t, "Is &lt;b&gt;<mark>bold</mark>&lt;/b&gt; allowed?", results[0].QuestionSnippet)
+	expectIsTrue(t, strings.Contains(results[0].AnswerSnippet, "&lt;script&gt;alert(&#34;<mark>payment</mark>&#34;)&lt;/script&gt;"))
+
+	fuzzy, err := repo.FuzzySearchFAQs("en", "bolt")
+	expectNoError(t, err)
+	for _, result := range fuzzy {
+		expectIsTrue(t, !strings.Contains(result.AnswerSnippet, "<script>"))
+	}
+}
+
+func TestSearchEscapesSnippets(t *testing.T) {
+	testSearchEscapesSnippets(t, NewMemoryDB())
+}
+
+func TestSearchEscapesSnippetsInDB(t *testing.T) {
+	testSearchEscapesSnippets(t, prepareDB())
+}
+
+func testPublishedLocales(t *testing.T, repo FAQRepository) {
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+	for _, code := range []string{"en", "de", "fr"} {
+		txt := FAQText{Question: "Q " + code, Answer: "A", Locale: Locale{Code: code}}
+		expectNoError(t, repo.SaveFAQText(f.ID, &txt))
+	}
+	expectNoError(t, repo.PublishFAQText(f.ID, "en", testAuthor))
+	expectNoError(t, repo.PublishFAQText(f.ID, "de", testAuthor))
+	other, err := repo.CreateFAQ()
+	expectNoError(t, err)
+	txt := FAQText{Question: "Q", Answer: "A", Locale: Locale{Code: "en"}}
+	expectNoError(t, repo.SaveFAQText(other.ID, &txt))
+	expectNoError(t, repo.PublishFAQText(other.ID, "en", testAuthor))
+
+	published, err := repo.PublishedLocales([]int{f.ID})
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(published))
+	sort.Strings(published[f.ID])
+	expectSameString(t, "de,en", strings.Join(published[f.ID], ","))
+}
+
+func TestPublishedLocales(t *testing.T) {
+	testPublishedLocales(t, NewMemoryDB())
+}
+
+func TestPublishedLocalesInDB(t *testing.T) {
+	testPublishedLocales(t, prepareDB())
+}
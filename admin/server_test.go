@@ -62,7 +62,7 @@ func TestGetAdminLogin(t *testing.T) {
 
 func TestPostAdminLogin(t *testing.T) {
 	body := body("email=admin&password=secret")
-	isAdminFunc = alwaysAdminFunc
+	authenticateFunc = alwaysAdminFunc
 	header := http.Header{}
 	header.Set("Content-Type", "application/x-www-form-urlencoded")
 
@@ -74,7 +74,7 @@ func TestPostAdminLogin(t *testing.T) {
 }
 
 func TestPostAdminLoginWrongPassword(t *testing.T) {
-	isAdminFunc = func(string, string) bool { return false }
+	authenticateFunc = func(string, string) (*User, error) { return nil, errUserNotFound }
 	resp := doRequest("POST", "/admin/login", emptyBody())
 
 	expectStatus(t, resp, 302)
@@ -139,7 +139,7 @@ func TestPostAdminFAQsCreate(t *testing.T) {
 func TestPostAdminFAQsUpdate(t *testing.T) {
 	faqRepository = &mockDB{}
 	body := body("faqID=111&localeCode=fr&question=questionFr&answer=answerFr")
-	isAdminFunc = alwaysAdminFunc
+	authenticateFunc = alwaysAdminFunc
 	header := http.Header{}
 	header.Set("Content-Type", "application/x-www-form-urlencoded")
 
@@ -149,9 +149,33 @@ func TestPostAdminFAQsUpdate(t *testing.T) {
 	expectHeader(t, resp, "Location", "/admin/faqs/edit/111")
 }
 
+func TestPostAdminFAQsUpdateConflict(t *testing.T) {
+	repo := NewMemoryDB()
+	faqRepository = repo
+	authenticateFunc = alwaysAdminFunc
+	faq := FAQ{Texts: []FAQText{FAQText{Locale: Locale{Code: "en"}, Question: "How do I pay?", Answer: "By card."}}}
+	expectNoError(t, repo.CreateFAQWithTexts(&faq))
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp := doRequestWithHeader("POST", "/admin/faqs/update", body("faqID=1&localeCode=en&version=1&question=How+do+I+pay%3F&answer=By+cash."), &header)
+	expectStatus(t, resp, 302)
+
+	resp = doRequestWithHeader("POST", "/admin/faqs/update", body("faqID=1&localeCode=en&version=1&question=How+do+I+pay%3F&answer=By+invoice."), &header)
+	expectStatus(t, resp, 409)
+	expectBodyContains(t, resp, "Someone else saved this text")
+	expectBodyContains(t, resp, `By <del class="bg-danger text-white">cash.</del><ins class="bg-success text-white">invoice.</ins>`)
+	expectBodyContains(t, resp, `<input type="hidden" name="version" value="2">`)
+	expectBodyContains(t, resp, "By invoice.</textarea>")
+
+	saved, err := repo.FAQById(1)
+	expectNoError(t, err)
+	expectSameString(t, "By cash.", saved.Texts[0].Answer)
+}
+
 func TestPostAdminFAQsDelete(t *testing.T) {
 	faqRepository = &mockDB{}
-	isAdminFunc = alwaysAdminFunc
+	authenticateFunc = alwaysAdminFunc
 
 	body := body("faqID=333")
 	header := http.Header{}
@@ -203,17 +227,19 @@ func TestGetAPISingleFAQ(t *testing.T) {
 }
 
 func TestGetAPISearchFAQ(t *testing.T) {
-	resp := doRequest("GET", "/api/search-faqs?query=bar", emptyBody())
-	expectErrorJSON(t, resp, 400, "lang param empty")
-
-	resp = doRequest("GET", "/api/search-faqs?lang=en", emptyBody())
+	resp := doRequest("GET", "/api/search-faqs?lang=en", emptyBody())
 	expectErrorJSON(t, resp, 400, "query param empty")
 
 	faqRepository = &mockDB{}
+	// Without any language the default locale is searched
+	resp = doRequest("GET", "/api/search-faqs?query=bar", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeader(t, resp, "Content-Language", "en")
+
 	resp = doRequest("GET", "/api/search-faqs?lang=en&query=bar", emptyBody())
 	expectStatus(t, resp, 200)
 	expectHeader(t, resp, "Content-Type", "application/json")
-	expectBodyContains(t, resp, `[{"id":123,"texts":[{"locale":{"code":"en","name_local":"English"},"question":"question?","answer":"answer!"},{"locale":{"code":"de","name_local":"Deutsch"},"question":"Frage?","answer":"Antwort!"}]},{"id":456,"texts":null},{"id":789,"texts":null}]`)
+	expectBodyContains(t, resp, `{"results":[{"faq_id":123,"text":{"locale":{"code":"en","name_local":"English"},"question":"question?","answer":"answer!"},"question_snippet":"\u003cmark\u003equestion?\u003c/mark\u003e","answer_snippet":"answer!","rank":0.6,"resolved_locale":"en"}],"fuzzy":false}`)
 }
 
 func TestGetAPISearchFAQWithBrokenDB(t *testing.T) {
@@ -255,6 +281,43 @@ func TestSaveAndGet(t *testing.T) {
 	expectSameString(t, "answer", txt2.Answer)
 }
 
+func TestSaveWithVersion(t *testing.T) {
+	repo := prepareDB()
+
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+
+	txt := FAQText{Question: "question", Answer: "answer", Locale: Locale{Code: "en"}}
+	err = repo.SaveFAQText(f.ID, &txt)
+	expectNoError(t, err)
+	expectSameInt(t, 1, txt.Version)
+
+	edit := FAQText{Question: "question", Answer: "edited answer", Locale: Locale{Code: "en"}, Version: 1}
+	err = repo.SaveFAQText(f.ID, &edit)
+	expectNoError(t, err)
+	expectSameInt(t, 2, edit.Version)
+
+	stale := FAQText{Question: "question", Answer: "stale answer", Locale: Locale{Code: "en"}, Version: 1}
+	err = repo.SaveFAQText(f.ID, &stale)
+	expectSameError(t, errVersionConflict, err)
+
+	texts := []FAQText{
+		FAQText{Question: "Frage", Answer: "Antwort", Locale: Locale{Code: "de"}},
+		FAQText{Question: "question", Answer: "stale answer", Locale: Locale{Code: "en"}, Version: 1},
+	}
+	err = repo.SaveFAQTexts(f.ID, texts)
+	expectSameError(t, errVersionConflict, err)
+
+	f2, err := repo.FAQById(f.ID)
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(f2.Texts))
+	expectSameString(t, "edited answer", f2.Texts[0].Answer)
+	expectSameInt(t, 2, f2.Texts[0].Version)
+	revisions, err := repo.FAQTextRevisions(f.ID, "en")
+	expectNoError(t, err)
+	expectSameInt(t, 2, len(revisions))
+}
+
 func TestSaveAndDelete(t *testing.T) {
 	repo := prepareDB()
 
@@ -267,7 +330,7 @@ func TestSaveAndDelete(t *testing.T) {
 	err = repo.SaveFAQText(f.ID, &txt)
 	expectNoError(t, err)
 
-	err = repo.DeleteFAQ(f.ID)
+	err = repo.DeleteFAQ(f.ID, testAuthor)
 	expectNoError(t, err)
 
 	f2, err := repo.FAQById(f.ID)
@@ -315,38 +378,38 @@ func TestSimpleSearch(t *testing.T) {
 	err = repo.SaveFAQText(f.ID, &txt)
 	expectNoError(t, err)
 
+	// Drafts are not searchable
+	results, err := repo.SearchFAQs("en", "answer")
+	expectNoError(t, err)
+	expectSameInt(t, 0, len(results))
+
+	err = repo.PublishFAQText(f.ID, "en", testAuthor)
+	expectNoError(t, err)
+
 	// Failed search
-	faqs, err := repo.SearchFAQs("de", "foobar")
+	results, err = repo.SearchFAQs("de", "foobar")
 	expectNoError(t, err)
-	expectNoFAQs(t, faqs)
+	expectSameInt(t, 0, len(results))
 
 	// Successful search
-	faqs, err = repo.SearchFAQs("en", "answer")
+	results, err = repo.SearchFAQs("en", "answer")
 	expectNoError(t, err)
 
-	t2 := faqs[0].Texts[0]
+	t2 := results[0].Text
 	expectSameString(t, "en", t2.Locale.Code)
 	expectSameString(t, "question", t2.Question)
 	expectSameString(t, "answer", t2.Answer)
+	expectSameString(t, "<mark>answer</mark>", results[0].AnswerSnippet)
+	expectIsTrue(t, results[0].Rank > 0)
 }
 
 func TestCreateAndCheckAdminJWT(t *testing.T) {
 	expires := time.Now().Add(adminSessionDuration)
-	jwtToken := createJWT(expires)
+	jwtToken := createJWT("admin", expires)
 	isValid := isValidAdminJWT(jwtToken)
 	expectIsTrue(t, isValid)
 }
 
-func TestLoggedInAsAdmin(t *testing.T) {
-	request, err := http.NewRequest("GET", "/does/not/matter", strings.NewReader(""))
-	expectNoError(t, err)
-	expectIsTrue(t, !loggedInAsAdmin(request))
-
-	cookie := createAuthCookie()
-	request.AddCookie(&cookie)
-	expectIsTrue(t, loggedInAsAdmin(request))
-}
-
 func TestLocaleFromCode(t *testing.T) {
 	tests := []struct {
 		code        string
@@ -379,16 +442,31 @@ func TestLocaleFromCode(t *testing.T) {
 
 }
 
-func prepareDB() *DB {
+func prepareDB() FAQRepository {
 	repo, err := NewDB(os.Getenv("DATABASE_URL"))
 	if err != nil {
 		panic(err)
 	}
+	if db, ok := repo.(*DB); ok {
+		if _, err = migrateUp(db.DB); err != nil {
+			panic(err)
+		}
+	}
 	repo.ClearDB()
 	repo.UpdateSearchIndex()
 	return repo
 }
 
+// preparePostgresDB is prepareDB for tests of features only Postgres has. It
+// skips the test when DATABASE_URL selects another backend.
+func preparePostgresDB(tb testing.TB) *DB {
+	repo, ok := prepareDB().(*DB)
+	if !ok {
+		tb.Skip("requires Postgres")
+	}
+	return repo
+}
+
 func doRequest(method, uri string, body *bytes.Buffer) *httptest.ResponseRecorder {
 	return doRequestWithHeader(method, uri, body, nil)
 }
@@ -471,6 +549,12 @@ func expectSameString(t *testing.T, str1 string, str2 string) {
 	}
 }
 
+func expectSameError(t *testing.T, expected error, actual error) {
+	if expected != actual {
+		t.Errorf("expected error %v, but got: %v", expected, actual)
+	}
+}
+
 func expectNoFAQs(t *testing.T, faqs []FAQ) {
 	if len(faqs) != 0 {
 		t.Errorf("expected empty slice but got: %v", faqs)
@@ -505,4 +589,64 @@ func emptyBody() *bytes.Buffer {
 	return bytes.NewBufferString("hello")
 }
 
-func alwaysAdminFunc(string, string) bool { return true }
+func alwaysAdminFunc(email string, password string) (*User, error) {
+	return &User{Email: email, Role: roleAdmin}, nil
+}
+
+func seedFAQs(b *testing.B, repo *DB, n int) {
+	for i := 0; i < n; i++ {
+		f, err := repo.CreateFAQ()
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, code := range []string{"en", "de"} {
+			txt := FAQText{Question: fmt.Sprintf("question %d", i), Answer: "answer", Locale: Locale{Code: code}}
+			if err = saveFAQText(repo.DB, f.ID, &txt); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// BenchmarkAllFAQs loads FAQs and their texts with two queries.
+func BenchmarkAllFAQs(b *testing.B) {
+	repo := preparePostgresDB(b)
+	seedFAQs(b, repo, 200)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.AllFAQs(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAllFAQsTextsPerFAQ loads the texts with one query per FAQ, as
+// getAllFAQs used to, for comparison with BenchmarkAllFAQs.
+func BenchmarkAllFAQsTextsPerFAQ(b *testing.B) {
+	repo := preparePostgresDB(b)
+	seedFAQs(b, repo, 200)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		rows, err := repo.Query(`SELECT id FROM faqs ORDER BY id;`)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ids := []int{}
+		for rows.Next() {
+			var id int
+			if err = rows.Scan(&id); err != nil {
+				b.Fatal(err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+
+		for _, id := range ids {
+			if _, err = getTextForFAQ(repo.DB, id); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
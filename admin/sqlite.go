@@ -0,0 +1,614 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteScheme selects SQLiteDB, e.g. sqlite:///var/lib/faqaas.db. Search
+// needs FTS5, so build with -tags sqlite_fts5.
+const sqliteScheme = "sqlite://"
+
+// sqliteSchema is created when opening a SQLite database. Published texts are
+// indexed with FTS5, kept up to date by triggers on faq_texts.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS categories (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  slug TEXT NOT NULL UNIQUE,
+  position INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS category_texts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  category_id INTEGER REFERENCES categories (id),
+  locale TEXT,
+  name TEXT,
+  UNIQUE (category_id, locale)
+);
+
+CREATE TABLE IF NOT EXISTS faqs (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  question TEXT,
+  answer TEXT,
+  category_id INTEGER REFERENCES categories (id),
+  position INTEGER NOT NULL DEFAULT 0,
+  deleted_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS faq_texts (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  faq_id INTEGER REFERENCES faqs (id),
+  locale TEXT,
+  question TEXT,
+  answer TEXT,
+  published_question TEXT,
+  published_answer TEXT,
+  published_at TIMESTAMP,
+  UNIQUE (faq_id, locale)
+);
+
+CREATE TABLE IF NOT EXISTS faq_text_revisions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  faq_id INTEGER NOT NULL,
+  locale TEXT NOT NULL,
+  question TEXT,
+  answer TEXT,
+  author TEXT,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_faq_text_revisions ON faq_text_revisions (faq_id, locale);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS faq_texts_fts USING fts5(question, answer, tokenize = 'porter unicode61 remove_diacritics 2');
+
+CREATE TRIGGER IF NOT EXISTS faq_texts_fts_insert AFTER INSERT ON faq_texts
+WHEN NEW.published_at IS NOT NULL BEGIN
+  INSERT INTO faq_texts_fts (rowid, question, answer) VALUES (NEW.id, NEW.published_question, NEW.published_answer);
+END;
+
+CREATE TRIGGER IF NOT EXISTS faq_texts_fts_update AFTER UPDATE ON faq_texts BEGIN
+  DELETE FROM faq_texts_fts WHERE rowid = OLD.id;
+  INSERT INTO faq_texts_fts (rowid, question, answer)
+  SELECT NEW.id, NEW.published_question, NEW.published_answer WHERE NEW.published_at IS NOT NULL;
+END;
+
+CREATE TRIGGER IF NOT EXISTS faq_texts_fts_delete AFTER DELETE ON faq_texts BEGIN
+  DELETE FROM faq_texts_fts WHERE rowid = OLD.id;
+END;
+`
+
+// sqliteMigrations change the schema of databases created from sqliteSchema.
+// The number of migrations applied is kept in PRAGMA user_version. The code of
+// the default locale is available in the temporary table migration_settings.
+var sqliteMigrations = []string{
+	`ALTER TABLE faq_texts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+	`ALTER TABLE faq_texts ADD COLUMN source_version INTEGER;
+	ALTER TABLE faq_text_revisions ADD COLUMN source_version INTEGER;
+	UPDATE faq_texts SET source_version = (SELECT t.version FROM faq_texts t WHERE t.faq_id = faq_texts.faq_id AND t.locale = (SELECT default_locale FROM migration_settings))
+	WHERE faq_texts.locale <> (SELECT default_locale FROM migration_settings);`,
+	`CREATE TABLE locales (
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  code TEXT NOT NULL UNIQUE,
+	  enabled BOOLEAN NOT NULL DEFAULT true,
+	  position INTEGER NOT NULL DEFAULT 0,
+	  is_default BOOLEAN NOT NULL DEFAULT false
+	);
+	CREATE UNIQUE INDEX idx_locales_default ON locales (is_default) WHERE is_default;`,
+	`ALTER TABLE locales ADD COLUMN fallbacks TEXT NOT NULL DEFAULT '';`,
+	`CREATE TABLE users (
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  email TEXT NOT NULL UNIQUE,
+	  password_hash TEXT NOT NULL,
+	  role TEXT NOT NULL,
+	  locales TEXT NOT NULL DEFAULT ''
+	);`,
+	`CREATE TABLE audit_log (
+	  id INTEGER PRIMARY KEY AUTOINCREMENT,
+	  author TEXT NOT NULL,
+	  action TEXT NOT NULL,
+	  details TEXT NOT NULL,
+	  created_at TIMESTAMP NOT NULL
+	);`,
+}
+
+var errSQLiteNoFTS5 = errors.New("SQLite lacks FTS5, build with -tags sqlite_fts5")
+
+// SQLiteDB is an FAQRepository for small deployments without Postgres. Words
+// of all locales are stemmed with the English porter stemmer, and there is no
+// fuzzy search.
+type SQLiteDB struct {
+	*sql.DB
+}
+
+func NewSQLiteDB(path string) (*SQLiteDB, error) {
+	dsn := path + "?_foreign_keys=on"
+	if strings.Contains(path, "?") {
+		dsn = path + "&_foreign_keys=on"
+	}
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, and every connection to :memory: would
+	// open a database of its own.
+	db.SetMaxOpenConns(1)
+	if _, err = db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		if strings.Contains(err.Error(), "no such module: fts5") {
+			return nil, errSQLiteNoFTS5
+		}
+		return nil, err
+	}
+	if err = migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteDB{db}, nil
+}
+
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	err := db.QueryRow(`PRAGMA user_version;`).Scan(&version)
+	if err != nil {
+		return err
+	}
+	if version == len(sqliteMigrations) {
+		return nil
+	}
+
+	_, err = db.Exec(`CREATE TEMP TABLE migration_settings AS SELECT $1 AS default_locale;`, getDefaultLocale().Code)
+	if err != nil {
+		return err
+	}
+	defer db.Exec(`DROP TABLE temp.migration_settings;`)
+	for ; version < len(sqliteMigrations); version++ {
+		err = withTx(db, func(tx *sql.Tx) error {
+			_, err := tx.Exec(sqliteMigrations[version])
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d;`, version+1))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteNow returns the current time as stored in TIMESTAMP columns. UTC
+// keeps the text representation sortable.
+func sqliteNow() time.Time {
+	return time.Now().UTC()
+}
+
+func (db *SQLiteDB) AllFAQs() ([]FAQ, error) {
+	return sqliteFAQs(db.DB, `
+		SELECT faqs.id, faqs.category_id, faqs.position, faqs.deleted_at
+		FROM faqs
+		LEFT JOIN categories ON categories.id = faqs.category_id
+		WHERE faqs.deleted_at IS NULL
+		ORDER BY categories.position NULLS LAST, categories.id NULLS LAST, faqs.position, faqs.id;`)
+}
+
+// sqliteListFAQsWhere selects the FAQs of ListOptions like listFAQsWhere.
+// The locales are passed as a JSON array.
+const sqliteListFAQsWhere = `
+		WHERE faqs.deleted_at IS NULL
+		AND (?1 = 0 OR faqs.category_id = ?1)
+		AND (NOT ?2 OR EXISTS (
+		  SELECT 1 FROM faq_texts
+		  WHERE faq_texts.faq_id = faqs.id AND faq_texts.published_at IS NOT NULL
+		  AND (json_array_length(?3) = 0
+		    OR faq_texts.locale IN (SELECT value FROM json_each(?3)) AND faq_texts.published_question <> '')))`
+
+func (db *SQLiteDB) ListFAQs(opts ListOptions) ([]FAQ, int, error) {
+	locales, err := json.Marshal(append([]string{}, opts.Locales...))
+	if err != nil {
+		return nil, 0, err
+	}
+	var total int
+	err = db.QueryRow(`
+		SELECT count(*) FROM faqs`+sqliteListFAQsWhere+`;`,
+		opts.CategoryID, opts.Published, string(locales)).Scan(&total)
+	if err != nil {
+		logError(err)
+		return nil, 0, err
+	}
+
+	orderBy, ok := faqSorts[opts.Sort]
+	if !ok {
+		orderBy = faqSorts["position"]
+	}
+	faqs, err := sqliteFAQs(db.DB, `
+		SELECT faqs.id, faqs.category_id, faqs.position, faqs.deleted_at
+		FROM faqs
+		LEFT JOIN categories ON categories.id = faqs.category_id`+sqliteListFAQsWhere+`
+		ORDER BY `+orderBy+`
+		LIMIT CASE ?4 WHEN 0 THEN -1 ELSE ?4 END OFFSET ?5;`,
+		opts.CategoryID, opts.Published, string(locales), opts.Limit, opts.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return faqs, total, nil
+}
+
+func (db *SQLiteDB) FAQById(id int) (*FAQ, error) {
+	faqs, err := sqliteFAQs(db.DB, `
+		SELECT id, category_id, position, deleted_at FROM faqs WHERE id = ?;`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(faqs) == 0 {
+		return &FAQ{ID: id, Texts: []FAQText{}}, nil
+	}
+	return &faqs[0], nil
+}
+
+// SearchFAQs matches all words of the query, ranked with bm25. Matches in
+// the question weigh double.
+func (db *SQLiteDB) SearchFAQs(language string, query string) ([]SearchResult, error) {
+	match := sqliteMatchQuery(query)
+	if len(match) == 0 {
+		return []SearchResult{}, nil
+	}
+	rows, err := db.Query(`
+		SELECT faq_texts.faq_id, faq_texts.locale,
+		       faq_texts.published_question, faq_texts.published_answer,
+		       highlight(faq_texts_fts, 0, '`+highlightStart+`', '`+highlightStop+`'),
+		       snippet(faq_texts_fts, 1, '`+highlightStart+`', '`+highlightStop+`', ' … ', 25),
+		       -bm25(faq_texts_fts, 2.0, 1.0) AS rank
+		FROM faq_texts_fts
+		JOIN faq_texts ON faq_texts.id = faq_texts_fts.rowid
+		JOIN faqs ON faqs.id = faq_texts.faq_id AND faqs.deleted_at IS NULL
+		WHERE faq_texts_fts MATCH ?
+		AND faq_texts.locale = ?
+		ORDER BY rank DESC;`, match, language)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSearchResults(rows)
+}
+
+// sqliteMatchQuery quotes each word of query, so FTS5 operators typed by
+// users are searched for literally.
+func sqliteMatchQuery(query string) string {
+	words := []string{}
+	for _, word := range strings.Fields(query) {
+		words = append(words, `"`+strings.Replace(word, `"`, `""`, -1)+`"`)
+	}
+	return strings.Join(words, " ")
+}
+
+func (db *SQLiteDB) PublishedLocales(faqIDs []int) (map[int][]string, error) {
+	if len(faqIDs) == 0 {
+		return map[int][]string{}, nil
+	}
+	ids := make([]interface{}, len(faqIDs))
+	for i, id := range faqIDs {
+		ids[i] = id
+	}
+
+	rows, err := db.Query(`
+		SELECT faq_id, locale FROM faq_texts
+		WHERE faq_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`) AND published_at IS NOT NULL AND published_question <> '';`, ids...)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+	return scanPublishedLocales(rows)
+}
+
+func (db *SQLiteDB) FuzzySearchFAQs(language string, query string) ([]SearchResult, error) {
+	return []SearchResult{}, nil
+}
+
+func (db *SQLiteDB) SearchSuggestion(language string, query string) (string, error) {
+	return "", nil
+}
+
+func (db *SQLiteDB) UpdateSearchIndex() error {
+	return nil
+}
+
+func (db *SQLiteDB) CreateFAQ() (*FAQ, error) {
+	return createFAQ(db.DB)
+}
+
+func (db *SQLiteDB) SaveFAQText(faqID int, text *FAQText) error {
+	return withTx(db.DB, func(tx *sql.Tx) error {
+		return sqliteSaveFAQText(tx, faqID, text)
+	})
+}
+
+func (db *SQLiteDB) CreateFAQWithTexts(faq *FAQ) error {
+	return withTx(db.DB, func(tx *sql.Tx) error {
+		return createFAQWithTexts(tx, faq, sqliteSaveFAQText)
+	})
+}
+
+func (db *SQLiteDB) SaveFAQTexts(faqID int, texts []FAQText) error {
+	return withTx(db.DB, func(tx *sql.Tx) error {
+		return saveFAQTexts(tx, faqID, texts, sqliteSaveFAQText)
+	})
+}
+
+// sqliteSaveFAQText upserts the text and records a revision, like
+// saveFAQText. Run it in a transaction.
+func sqliteSaveFAQText(tx dbtx, faqID int, text *FAQText) error {
+	var sourceVersion sql.NullInt64
+	err := tx.QueryRow(`
+		INSERT INTO faq_texts (faq_id, locale, question, answer, source_version)
+		VALUES (?1, ?2, ?3, ?4,
+		  CASE WHEN ?7 THEN ?8
+		  ELSE (SELECT version FROM faq_texts WHERE faq_id = ?1 AND locale = ?6 AND ?2 <> ?6) END)
+		ON CONFLICT (faq_id, locale)
+		  DO UPDATE SET question = excluded.question, answer = excluded.answer,
+		    source_version = excluded.source_version, version = version + 1
+		  WHERE ?5 = 0 OR version = ?5
+		RETURNING version, source_version;`,
+		faqID, text.Locale.Code, text.Question, text.Answer, text.Version, getDefaultLocale().Code,
+		text.keepSourceVersion, nullInt(text.SourceVersion)).Scan(&text.Version, &sourceVersion)
+	text.SourceVersion = int(sourceVersion.Int64)
+	if isForeignKeyViolation(err) {
+		return errFAQNotFound
+	}
+	if err == sql.ErrNoRows {
+		return errVersionConflict
+	}
+	if err != nil {
+		logError(err)
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO faq_text_revisions (faq_id, locale, question, answer, author, source_version, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?);`,
+		faqID, text.Locale.Code, text.Question, text.Answer, text.Author, sourceVersion, sqliteNow())
+	if err != nil {
+		logError(err)
+	}
+	return err
+}
+
+func (db *SQLiteDB) PublishFAQText(faqID int, localeCode string, author string) error {
+	return withAudit(db.DB, author, auditPublishText, faqTextDetails(faqID, localeCode), func(tx *sql.Tx) error {
+		return sqlitePublishFAQText(tx, faqID, localeCode)
+	})
+}
+
+func sqlitePublishFAQText(db dbtx, faqID int, localeCode string) error {
+	res, err := db.Exec(`
+		UPDATE faq_texts SET
+		  published_question = question,
+		  published_answer = answer,
+		  published_at = ?
+		WHERE faq_id = ? AND locale = ?;`, sqliteNow(), faqID, localeCode)
+	if err != nil {
+		logError(err)
+		return err
+	}
+	return expectRowsAffected(res, errFAQTextNotFound)
+}
+
+func (db *SQLiteDB) FAQTextRevisions(faqID int, localeCode string) ([]FAQTextRevision, error) {
+	return getFAQTextRevisions(db.DB, faqID, localeCode)
+}
+
+func (db *SQLiteDB) FAQTextRevision(revisionID int) (*FAQTextRevision, error) {
+	return getFAQTextRevision(db.DB, revisionID)
+}
+
+func (db *SQLiteDB) DeleteFAQ(faqID int, author string) error {
+	return withAudit(db.DB, author, auditDeleteFAQ, faqDetails(faqID), func(tx *sql.Tx) error {
+		res, err := tx.Exec(`UPDATE faqs SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL;`, sqliteNow(), faqID)
+		if err != nil {
+			logError(err)
+			return err
+		}
+		return expectRowsAffected(res, errFAQNotFound)
+	})
+}
+
+func (db *SQLiteDB) DeleteFAQText(faqID int, localeCode string, author string) error {
+	return withAudit(db.DB, author, auditDeleteText, faqTextDetails(faqID, localeCode), func(tx *sql.Tx) error {
+		return deleteFAQText(tx, faqID, localeCode)
+	})
+}
+
+func (db *SQLiteDB) TrashedFAQs() ([]FAQ, error) {
+	return sqliteFAQs(db.DB, `
+		SELECT id, category_id, position, deleted_at FROM faqs
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id;`)
+}
+
+func (db *SQLiteDB) RestoreFAQ(faqID int, author string) error {
+	return withAudit(db.DB, author, auditRestoreFAQ, faqDetails(faqID), func(tx *sql.Tx) error {
+		return restoreFAQ(tx, faqID)
+	})
+}
+
+func (db *SQLiteDB) PurgeFAQ(faqID int, author string) error {
+	return withAudit(db.DB, author, auditPurgeFAQ, faqDetails(faqID), func(tx *sql.Tx) error {
+		return purgeFAQ(tx, faqID)
+	})
+}
+
+func (db *SQLiteDB) PurgeFAQs(deletedBefore time.Time, author string) (int, error) {
+	return purgeFAQs(db.DB, deletedBefore.UTC(), author)
+}
+
+func (db *SQLiteDB) ImportFAQs(fn func(w importWriter) error) error {
+	return withTx(db.DB, func(tx *sql.Tx) error {
+		return fn(&txWriter{tx: tx, save: sqliteSaveFAQText, publish: sqlitePublishFAQText})
+	})
+}
+
+func (db *SQLiteDB) AllCategories() ([]Category, error) {
+	return getAllCategories(db.DB)
+}
+
+func (db *SQLiteDB) CreateCategory(slug string, author string) (*Category, error) {
+	var category *Category
+	err := withAudit(db.DB, author, auditCreateCategory, categoryDetails(slug), func(tx *sql.Tx) error {
+		var err error
+		category, err = createCategory(tx, slug)
+		return err
+	})
+	return category, err
+}
+
+func (db *SQLiteDB) SaveCategory(category *Category, author string) error {
+	return withAudit(db.DB, author, auditSaveCategory, categoryDetails(category.Slug), func(tx *sql.Tx) error {
+		return saveCategory(tx, category)
+	})
+}
+
+func (db *SQLiteDB) DeleteCategory(categoryID int, author string) error {
+	return withAudit(db.DB, author, auditDeleteCategory, categoryIDDetails(categoryID), func(tx *sql.Tx) error {
+		return deleteCategory(tx, categoryID)
+	})
+}
+
+func (db *SQLiteDB) MoveFAQ(faqID int, categoryID int, position int, author string) error {
+	return withAudit(db.DB, author, auditMoveFAQ, faqMoveDetails(faqID, categoryID, position), func(tx *sql.Tx) error {
+		return moveFAQ(tx, faqID, categoryID, position)
+	})
+}
+
+func (db *SQLiteDB) MoveFAQs(categoryID int, faqIDs []int, author string) error {
+	return withAudit(db.DB, author, auditMoveFAQs, faqsMoveDetails(categoryID, faqIDs), func(tx *sql.Tx) error {
+		return moveFAQs(tx, categoryID, faqIDs)
+	})
+}
+
+func (db *SQLiteDB) MoveCategories(categoryIDs []int, author string) error {
+	return withAudit(db.DB, author, auditMoveCategories, categoriesDetails(categoryIDs), func(tx *sql.Tx) error {
+		return moveCategories(tx, categoryIDs)
+	})
+}
+
+func (db *SQLiteDB) AllLocales() ([]LocaleSetting, error) {
+	return getAllLocales(db.DB)
+}
+
+func (db *SQLiteDB) CreateLocale(setting *LocaleSetting, author string) error {
+	return withAudit(db.DB, author, auditCreateLocale, localeDetails(setting.Code), func(tx *sql.Tx) error {
+		return createLocale(tx, setting)
+	})
+}
+
+func (db *SQLiteDB) SaveLocale(setting *LocaleSetting, author string) error {
+	return withAudit(db.DB, author, auditSaveLocale, localeDetails(setting.Code), func(tx *sql.Tx) error {
+		return saveLocale(tx, setting)
+	})
+}
+
+func (db *SQLiteDB) MoveLocales(codes []string, author string) error {
+	return withAudit(db.DB, author, auditMoveLocales, localesDetails(codes), func(tx *sql.Tx) error {
+		return moveLocales(tx, codes)
+	})
+}
+
+func (db *SQLiteDB) AllUsers() ([]User, error) {
+	return getAllUsers(db.DB)
+}
+
+func (db *SQLiteDB) UserByEmail(email string) (*User, error) {
+	return getUserByEmail(db.DB, email)
+}
+
+func (db *SQLiteDB) CreateUser(user *User, author string) error {
+	return withAudit(db.DB, author, auditCreateUser, userDetails(user), func(tx *sql.Tx) error {
+		return createUser(tx, user)
+	})
+}
+
+func (db *SQLiteDB) SaveUser(user *User, author string) error {
+	return withAudit(db.DB, author, auditSaveUser, userDetails(user), func(tx *sql.Tx) error {
+		return saveUser(tx, user)
+	})
+}
+
+func (db *SQLiteDB) DeleteUser(id int, author string) error {
+	return withAudit(db.DB, author, auditDeleteUser, userIDDetails(id), func(tx *sql.Tx) error {
+		return deleteUser(tx, id)
+	})
+}
+
+func (db *SQLiteDB) AuditLog(limit int) ([]AuditEntry, error) {
+	return getAuditLog(db.DB, limit)
+}
+
+func (db *SQLiteDB) ClearDB() error {
+	return withTx(db.DB, func(tx *sql.Tx) error {
+		return clearDB(tx)
+	})
+}
+
+// sqliteFAQs runs query, which selects id, category_id, position and
+// deleted_at of FAQs, and loads their texts with a second query.
+func sqliteFAQs(db *sql.DB, query string, args ...interface{}) ([]FAQ, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	faqs := []FAQ{}
+	ids := []interface{}{}
+	for rows.Next() {
+		var id int
+		var categoryID sql.NullInt64
+		var position int
+		var deletedAt pq.NullTime
+		err = rows.Scan(&id, &categoryID, &position, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		faq := FAQ{ID: id, CategoryID: int(categoryID.Int64), Position: position}
+		if deletedAt.Valid {
+			faq.DeletedAt = &deletedAt.Time
+		}
+		faqs = append(faqs, faq)
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+	if len(faqs) == 0 {
+		return faqs, nil
+	}
+
+	textRows, err := db.Query(`
+		SELECT faq_id, locale, question, answer, version, source_version, published_question, published_answer, published_at
+		FROM faq_texts WHERE faq_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)
+		ORDER BY faq_id, id;`, ids...)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer textRows.Close()
+	texts, err := scanFAQTexts(textRows)
+	if err != nil {
+		return nil, err
+	}
+
+	setTexts(faqs, texts)
+	return faqs, nil
+}
@@ -0,0 +1,21 @@
+//go:build cgo
+// +build cgo
+
+package main
+
+import (
+	"github.com/mattn/go-sqlite3"
+)
+
+func isSQLiteError(err error, code sqlite3.ErrNoExtended) bool {
+	sqliteErr, ok := err.(sqlite3.Error)
+	return ok && sqliteErr.ExtendedCode == code
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+	return isSQLiteError(err, sqlite3.ErrConstraintUnique)
+}
+
+func isSQLiteForeignKeyViolation(err error) bool {
+	return isSQLiteError(err, sqlite3.ErrConstraintForeignKey)
+}
@@ -0,0 +1,15 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+// Without cgo the SQLite driver is a stub that fails to open databases, so
+// there are no SQLite errors to recognize.
+
+func isSQLiteUniqueViolation(err error) bool {
+	return false
+}
+
+func isSQLiteForeignKeyViolation(err error) bool {
+	return false
+}
@@ -0,0 +1,39 @@
+//go:build sqlite_fts5
+// +build sqlite_fts5
+
+package main
+
+import (
+	"testing"
+)
+
+func TestNewDBSQLite(t *testing.T) {
+	repo, err := NewDB("sqlite://:memory:")
+	expectNoError(t, err)
+	_, ok := repo.(*SQLiteDB)
+	expectIsTrue(t, ok)
+}
+
+func TestSQLiteSearchQuotesOperators(t *testing.T) {
+	repo, err := NewSQLiteDB(":memory:")
+	expectNoError(t, err)
+
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+	txt := FAQText{Question: "Can I pay by card OR cash?", Answer: "Both work.", Locale: Locale{Code: "en"}}
+	expectNoError(t, repo.SaveFAQText(f.ID, &txt))
+	expectNoError(t, repo.PublishFAQText(f.ID, "en", testAuthor))
+
+	results, err := repo.SearchFAQs("en", `card OR "cash`)
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(results))
+	expectSameString(t, "Can I pay by <mark>card</mark> <mark>OR</mark> <mark>cash</mark>?", results[0].QuestionSnippet)
+
+	results, err = repo.SearchFAQs("en", "paying")
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(results))
+
+	results, err = repo.SearchFAQs("de", "card")
+	expectNoError(t, err)
+	expectSameInt(t, 0, len(results))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Translation status of an FAQ in a locale besides the default locale.
+const (
+	translationMissing  = "missing"
+	translationOutdated = "outdated"
+	translationUpToDate = "up-to-date"
+)
+
+// TranslationStatus tells whether faq is translated into localeCode and
+// whether the translation is based on the current text in the default
+// locale. It is empty for the default locale.
+func (f FAQ) TranslationStatus(localeCode string) string {
+	if localeCode == getDefaultLocale().Code {
+		return ""
+	}
+	text := f.TextForLocale(localeCode)
+	if len(text.Question) == 0 {
+		return translationMissing
+	}
+	source := f.TextForLocale(getDefaultLocale().Code)
+	if len(source.Question) > 0 && text.SourceVersion < source.Version {
+		return translationOutdated
+	}
+	return translationUpToDate
+}
+
+// LocalesWithStatus returns the codes of the supported locales in which faq
+// has the given translation status.
+func (f FAQ) LocalesWithStatus(status string) []string {
+	codes := []string{}
+	for _, l := range getSupportedLocales() {
+		if f.TranslationStatus(l.Code) == status {
+			codes = append(codes, l.Code)
+		}
+	}
+	return codes
+}
+
+// TranslationSummary counts the FAQs by translation status in a locale.
+type TranslationSummary struct {
+	Locale   Locale `json:"locale"`
+	UpToDate int    `json:"up_to_date"`
+	Outdated int    `json:"outdated"`
+	Missing  int    `json:"missing"`
+}
+
+// Complete returns the share of FAQs with an up-to-date translation in
+// percent.
+func (s TranslationSummary) Complete() int {
+	total := s.UpToDate + s.Outdated + s.Missing
+	if total == 0 {
+		return 100
+	}
+	return s.UpToDate * 100 / total
+}
+
+// translationSummaries returns a summary for each supported locale besides
+// the default locale.
+func translationSummaries(faqs []FAQ) []TranslationSummary {
+	summaries := []TranslationSummary{}
+	for _, l := range getSupportedLocales() {
+		if l.IsDefaultLocale() {
+			continue
+		}
+		s := TranslationSummary{Locale: l}
+		for _, faq := range faqs {
+			switch faq.TranslationStatus(l.Code) {
+			case translationUpToDate:
+				s.UpToDate++
+			case translationOutdated:
+				s.Outdated++
+			case translationMissing:
+				s.Missing++
+			}
+		}
+		summaries = append(summaries, s)
+	}
+	return summaries
+}
+
+type apiTranslationStatus struct {
+	Locales []TranslationSummary `json:"locales"`
+	FAQs    []apiFAQStatus       `json:"faqs"`
+}
+
+type apiFAQStatus struct {
+	ID       int               `json:"id"`
+	Question string            `json:"question"` // In the default locale
+	Status   map[string]string `json:"status"`   // By locale code
+}
+
+func getAPITranslationStatus(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqs, err := faqRepository.AllFAQs()
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	result := apiTranslationStatus{Locales: translationSummaries(faqs), FAQs: []apiFAQStatus{}}
+	for _, faq := range faqs {
+		status := make(map[string]string)
+		for _, l := range getSupportedLocales() {
+			if !l.IsDefaultLocale() {
+				status[l.Code] = faq.TranslationStatus(l.Code)
+			}
+		}
+		result.FAQs = append(result.FAQs, apiFAQStatus{
+			ID:       faq.ID,
+			Question: faq.TextForLocale(getDefaultLocale().Code).Question,
+			Status:   status,
+		})
+	}
+	writeJSON(w, result)
+}
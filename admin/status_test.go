@@ -0,0 +1,115 @@
+package main
+
+import (
+	"testing"
+)
+
+// testTranslationStatus saves and translates an FAQ in repo, then changes
+// its source text.
+func testTranslationStatus(t *testing.T, repo FAQRepository) {
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+
+	texts := []FAQText{
+		FAQText{Locale: Locale{Code: "en"}, Question: "How do I pay?", Answer: "By card."},
+		FAQText{Locale: Locale{Code: "de"}, Question: "Wie bezahle ich?", Answer: "Per Karte."},
+	}
+	expectNoError(t, repo.SaveFAQTexts(f.ID, texts))
+	expectSameInt(t, 0, texts[0].SourceVersion)
+	expectSameInt(t, 1, texts[1].SourceVersion)
+
+	faq, err := repo.FAQById(f.ID)
+	expectNoError(t, err)
+	expectSameString(t, translationUpToDate, faq.TranslationStatus("de"))
+	expectSameString(t, translationMissing, faq.TranslationStatus("fr"))
+	expectSameString(t, "", faq.TranslationStatus("en"))
+
+	source := FAQText{Locale: Locale{Code: "en"}, Question: "How do I pay?", Answer: "By card or invoice."}
+	expectNoError(t, repo.SaveFAQText(f.ID, &source))
+	faq, _ = repo.FAQById(f.ID)
+	expectSameString(t, translationOutdated, faq.TranslationStatus("de"))
+	expectSameInt(t, 1, faq.TextForLocale("de").SourceVersion)
+
+	translation := FAQText{Locale: Locale{Code: "de"}, Question: "Wie bezahle ich?", Answer: "Per Karte oder Rechnung."}
+	expectNoError(t, repo.SaveFAQText(f.ID, &translation))
+	expectSameInt(t, 2, translation.SourceVersion)
+	faq, _ = repo.FAQById(f.ID)
+	expectSameString(t, translationUpToDate, faq.TranslationStatus("de"))
+
+	revisions, err := repo.FAQTextRevisions(f.ID, "de")
+	expectNoError(t, err)
+	expectSameInt(t, 2, len(revisions))
+	expectSameInt(t, 1, revisions[1].SourceVersion)
+	faqRepository = repo
+	restored, err := restoreRevision(&revisions[1], "")
+	expectNoError(t, err)
+	expectSameInt(t, 1, restored.SourceVersion)
+	faq, _ = repo.FAQById(f.ID)
+	expectSameString(t, translationOutdated, faq.TranslationStatus("de"))
+}
+
+func TestTranslationStatus(t *testing.T) {
+	testTranslationStatus(t, NewMemoryDB())
+}
+
+func TestTranslationStatusInDB(t *testing.T) {
+	testTranslationStatus(t, prepareDB())
+}
+
+func TestTranslationSummaries(t *testing.T) {
+	repo := seedTransferDB(t)
+	source := FAQText{Locale: Locale{Code: "en"}, Question: "How do I pay?", Answer: "By card or invoice."}
+	expectNoError(t, repo.SaveFAQText(2, &source))
+	faqs, _ := repo.AllFAQs()
+
+	summaries := translationSummaries(faqs)
+	expectSameInt(t, len(getSupportedLocales())-1, len(summaries))
+	de := summaries[0]
+	expectSameString(t, "de", de.Locale.Code)
+	expectSameInt(t, 0, de.UpToDate)
+	expectSameInt(t, 1, de.Outdated)
+	expectSameInt(t, 1, de.Missing)
+	expectSameInt(t, 0, de.Complete())
+	expectSameInt(t, 100, TranslationSummary{}.Complete())
+	expectSameString(t, "de", faqs[0].LocalesWithStatus(translationOutdated)[0])
+	expectSameInt(t, len(getSupportedLocales())-2, len(faqs[0].LocalesWithStatus(translationMissing)))
+}
+
+func TestGetAPITranslationStatus(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+
+	resp := doRequest("GET", "/api/translation-status", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"locales":[{"locale":{"code":"de","name_en":"German","name_local":"Deutsch"},"up_to_date":1,"outdated":0,"missing":1},`)
+	expectBodyContains(t, resp, `{"id":2,"question":"How do I pay?","status":{"ar":"missing","da":"missing","de":"up-to-date",`)
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("GET", "/api/translation-status", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestGetAdminTranslationStatus(t *testing.T) {
+	repo := seedTransferDB(t)
+	source := FAQText{Locale: Locale{Code: "en"}, Question: "How do I pay?", Answer: "By card or invoice."}
+	expectNoError(t, repo.SaveFAQText(2, &source))
+	faqRepository = repo
+	authenticateFunc = alwaysAdminFunc
+
+	resp := doRequest("GET", "/admin/locales", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<td>0</td>
+            <td>1</td>
+            <td>1</td>
+            <td>0%</td>`)
+	expectBodyContains(t, resp, `<td><a href="/admin/faqs/edit/2">How do I pay?</a></td>`)
+	expectBodyContains(t, resp, `<td class="table-warning">outdated</td>`)
+
+	resp = doRequest("GET", "/admin/faqs", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<span class="badge badge-danger">Outdated: de</span>`)
+	expectBodyContains(t, resp, `title="fr, es, it, nl, pt, pt-BR, da, sv, no, ru, ar, zh">12 missing</span>`)
+
+	resp = doRequest("GET", "/admin/faqs/edit/2", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<span class="badge badge-danger">Outdated</span>`)
+}
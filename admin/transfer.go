@@ -0,0 +1,673 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// exportFormatVersion is the version of ExportDocument. Documents of other
+// versions are not imported.
+const exportFormatVersion = 1
+
+// maxImportSize limits the size of documents accepted for import.
+const maxImportSize = 32 << 20
+
+// cliAuthor is recorded as the author of texts imported on the command line.
+const cliAuthor = "cli"
+
+// ExportDocument holds all categories and FAQs outside the trash. FAQs refer
+// to their category by slug, so a document can be imported into another
+// installation.
+type ExportDocument struct {
+	Version    int              `json:"version"`
+	ExportedAt time.Time        `json:"exported_at"`
+	Categories []ExportCategory `json:"categories"`
+	FAQs       []ExportFAQ      `json:"faqs"`
+}
+
+type ExportCategory struct {
+	Slug     string            `json:"slug"`
+	Position int               `json:"position"`
+	Names    map[string]string `json:"names"` // By locale code
+}
+
+type ExportFAQ struct {
+	ID       int          `json:"id"` // ID in the exporting installation, not used on import
+	Category string       `json:"category,omitempty"`
+	Position int          `json:"position"`
+	Texts    []ExportText `json:"texts"`
+}
+
+// ExportText is the current draft of an FAQ text. Published is set if the
+// draft is published as it is.
+type ExportText struct {
+	Locale    string `json:"locale"`
+	Question  string `json:"question"`
+	Answer    string `json:"answer"`
+	Published bool   `json:"published"`
+}
+
+// Actions taken for an imported FAQ.
+const (
+	importCreate    = "create"
+	importUpdate    = "update"
+	importUnchanged = "unchanged"
+)
+
+// ImportResult lists what an import did or, for a dry run, would do.
+type ImportResult struct {
+	DryRun            bool          `json:"dry_run"`
+	CategoriesCreated []string      `json:"categories_created"`
+	CategoriesUpdated []string      `json:"categories_updated"`
+	FAQs              []ImportedFAQ `json:"faqs"`
+}
+
+// ImportedFAQ is an FAQ of an imported document. Locales lists the texts
+// saved or published.
+type ImportedFAQ struct {
+	ID       int      `json:"id,omitempty"` // Zero for FAQs a dry run would create
+	Question string   `json:"question"`
+	Action   string   `json:"action"`
+	Locales  []string `json:"locales,omitempty"`
+}
+
+// Count returns the number of FAQs imported with the given action.
+func (r *ImportResult) Count(action string) int {
+	n := 0
+	for _, faq := range r.FAQs {
+		if faq.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+func exportFAQs(repo FAQRepository) (*ExportDocument, error) {
+	categories, err := repo.AllCategories()
+	if err != nil {
+		return nil, err
+	}
+	faqs, err := repo.AllFAQs()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := ExportDocument{
+		Version:    exportFormatVersion,
+		ExportedAt: time.Now().UTC(),
+		Categories: []ExportCategory{},
+		FAQs:       []ExportFAQ{},
+	}
+	slugs := make(map[int]string)
+	for _, c := range categories {
+		names := make(map[string]string)
+		for _, n := range c.Names {
+			names[n.Locale.Code] = n.Name
+		}
+		doc.Categories = append(doc.Categories, ExportCategory{Slug: c.Slug, Position: c.Position, Names: names})
+		slugs[c.ID] = c.Slug
+	}
+	for _, faq := range faqs {
+		f := ExportFAQ{ID: faq.ID, Category: slugs[faq.CategoryID], Position: faq.Position, Texts: []ExportText{}}
+		for i := range faq.Texts {
+			t := &faq.Texts[i]
+			f.Texts = append(f.Texts, ExportText{
+				Locale:    t.Locale.Code,
+				Question:  t.Question,
+				Answer:    t.Answer,
+				Published: !t.HasUnpublishedChanges(),
+			})
+		}
+		doc.FAQs = append(doc.FAQs, f)
+	}
+	return &doc, nil
+}
+
+// validateImport checks doc before anything is imported. Categories of FAQs
+// must be part of the document or exist already.
+func validateImport(repo FAQRepository, doc *ExportDocument) error {
+	if doc.Version != exportFormatVersion {
+		return fmt.Errorf("unsupported version: %v", doc.Version)
+	}
+
+	categories, err := repo.AllCategories()
+	if err != nil {
+		return err
+	}
+	slugs := make(map[string]bool)
+	for _, c := range categories {
+		slugs[c.Slug] = true
+	}
+	seen := make(map[string]bool)
+	for _, c := range doc.Categories {
+		if !isValidSlug(c.Slug) {
+			return fmt.Errorf("invalid category slug: %v", c.Slug)
+		}
+		if seen[c.Slug] {
+			return fmt.Errorf("duplicate category: %v", c.Slug)
+		}
+		seen[c.Slug] = true
+		slugs[c.Slug] = true
+		for code := range c.Names {
+			if !isSupportedLocale(code) {
+				return fmt.Errorf("unsupported locale: %v", code)
+			}
+		}
+	}
+
+	for i, faq := range doc.FAQs {
+		if len(faq.Category) > 0 && !slugs[faq.Category] {
+			return fmt.Errorf("faq %d: category not found: %v", i+1, faq.Category)
+		}
+		if err := validateFAQTexts(importTexts(faq, "")); err != nil {
+			return fmt.Errorf("faq %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// importTexts returns the texts of faq to be saved by author.
+func importTexts(faq ExportFAQ, author string) []FAQText {
+	texts := []FAQText{}
+	for _, t := range faq.Texts {
+		texts = append(texts, FAQText{
+			Locale:   localeFromCode(t.Locale),
+			Question: strings.TrimSpace(t.Question),
+			Answer:   strings.TrimSpace(t.Answer),
+			Author:   author,
+		})
+	}
+	return texts
+}
+
+// importFAQs creates or updates the categories and FAQs of doc, which must
+// have been validated. Categories are matched by slug. An FAQ is matched by
+// the exact question of one of its texts, so an FAQ whose questions were all
+// reworded is created anew and the old one is left as it is. Texts marked as
+// published are published. Everything is saved in one transaction. A dry
+// run takes the same steps without saving anything.
+func importFAQs(repo FAQRepository, doc *ExportDocument, author string, dryRun bool) (*ImportResult, error) {
+	categories, err := repo.AllCategories()
+	if err != nil {
+		return nil, err
+	}
+	faqs, err := repo.AllFAQs()
+	if err != nil {
+		return nil, err
+	}
+
+	var result *ImportResult
+	run := func(w importWriter) error {
+		result = &ImportResult{DryRun: dryRun, CategoriesCreated: []string{}, CategoriesUpdated: []string{}, FAQs: []ImportedFAQ{}}
+		return writeImport(w, doc, categories, faqs, author, result)
+	}
+	if dryRun {
+		err = run(&dryRunWriter{})
+	} else {
+		err = repo.ImportFAQs(run)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// writeImport saves the categories and FAQs of doc with w, given the
+// categories and FAQs before the import, and records what it did in result.
+func writeImport(w importWriter, doc *ExportDocument, categories []Category, faqs []FAQ, author string, result *ImportResult) error {
+	categoryIDs, err := importCategories(w, doc, categories, author, result)
+	if err != nil {
+		return err
+	}
+
+	byQuestion := make(map[string]*FAQ)
+	for i := range faqs {
+		for _, t := range faqs[i].Texts {
+			byQuestion[t.Locale.Code+"\n"+t.Question] = &faqs[i]
+		}
+	}
+
+	for _, f := range doc.FAQs {
+		texts := importTexts(f, author)
+		var existing *FAQ
+		for _, t := range texts {
+			if faq, ok := byQuestion[t.Locale.Code+"\n"+t.Question]; ok {
+				existing = faq
+				break
+			}
+		}
+
+		imported := ImportedFAQ{Question: texts[0].Question}
+		if existing == nil {
+			imported.Action = importCreate
+			err = createImportedFAQ(w, f, texts, categoryIDs[f.Category], author, &imported)
+		} else {
+			imported.ID = existing.ID
+			err = updateImportedFAQ(w, existing, f, texts, categoryIDs[f.Category], author, &imported)
+		}
+		if err != nil {
+			return err
+		}
+		result.FAQs = append(result.FAQs, imported)
+	}
+	return nil
+}
+
+// importCategories creates or updates the categories of doc and returns the
+// IDs of all categories by slug.
+func importCategories(w importWriter, doc *ExportDocument, categories []Category, author string, result *ImportResult) (map[string]int, error) {
+	ids := make(map[string]int)
+	bySlug := make(map[string]Category)
+	for _, c := range categories {
+		ids[c.Slug] = c.ID
+		bySlug[c.Slug] = c
+	}
+
+	for _, c := range doc.Categories {
+		category, ok := bySlug[c.Slug]
+		if ok && !categoryChanged(category, c) {
+			continue
+		}
+		if ok {
+			result.CategoriesUpdated = append(result.CategoriesUpdated, c.Slug)
+		} else {
+			result.CategoriesCreated = append(result.CategoriesCreated, c.Slug)
+			created, err := w.CreateCategory(c.Slug, author)
+			if err != nil {
+				return nil, err
+			}
+			category = *created
+			ids[c.Slug] = category.ID
+		}
+
+		category.Position = c.Position
+		category.Names = []CategoryName{}
+		for code, name := range c.Names {
+			category.Names = append(category.Names, CategoryName{Locale: Locale{Code: code}, Name: name})
+		}
+		err := w.SaveCategory(&category, author)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+func categoryChanged(category Category, c ExportCategory) bool {
+	if category.Position != c.Position {
+		return true
+	}
+	names := make(map[string]string)
+	for _, n := range category.Names {
+		names[n.Locale.Code] = n.Name
+	}
+	for code, name := range c.Names {
+		if names[code] != name {
+			return true
+		}
+	}
+	return false
+}
+
+func createImportedFAQ(w importWriter, f ExportFAQ, texts []FAQText, categoryID int, author string, imported *ImportedFAQ) error {
+	for _, t := range texts {
+		imported.Locales = append(imported.Locales, t.Locale.Code)
+	}
+
+	faq := FAQ{Texts: texts, CategoryID: categoryID, Position: f.Position}
+	err := w.CreateFAQWithTexts(&faq)
+	if err != nil {
+		return err
+	}
+	imported.ID = faq.ID
+	return publishImportedTexts(w, faq.ID, f, nil, author)
+}
+
+func updateImportedFAQ(w importWriter, existing *FAQ, f ExportFAQ, texts []FAQText, categoryID int, author string, imported *ImportedFAQ) error {
+	current := make(map[string]*FAQText)
+	for i := range existing.Texts {
+		current[existing.Texts[i].Locale.Code] = &existing.Texts[i]
+	}
+
+	changed := []FAQText{}
+	for i, t := range texts {
+		c, ok := current[t.Locale.Code]
+		if !ok || c.Question != t.Question || c.Answer != t.Answer {
+			changed = append(changed, t)
+			imported.Locales = append(imported.Locales, t.Locale.Code)
+		} else if f.Texts[i].Published && c.HasUnpublishedChanges() {
+			imported.Locales = append(imported.Locales, t.Locale.Code)
+		}
+	}
+	moved := existing.CategoryID != categoryID || (categoryID != 0 && existing.Position != f.Position)
+
+	imported.Action = importUnchanged
+	if len(imported.Locales) > 0 || moved {
+		imported.Action = importUpdate
+	}
+	if imported.Action == importUnchanged {
+		return nil
+	}
+
+	if len(changed) > 0 {
+		err := w.SaveFAQTexts(existing.ID, changed)
+		if err != nil {
+			return err
+		}
+	}
+	if moved {
+		err := w.MoveFAQ(existing.ID, categoryID, f.Position, author)
+		if err != nil {
+			return err
+		}
+	}
+	return publishImportedTexts(w, existing.ID, f, current, author)
+}
+
+// publishImportedTexts publishes the texts marked as published in f, unless
+// their current version was published before the import.
+func publishImportedTexts(w importWriter, faqID int, f ExportFAQ, current map[string]*FAQText, author string) error {
+	for _, t := range f.Texts {
+		if !t.Published {
+			continue
+		}
+		if c, ok := current[t.Locale]; ok && !c.HasUnpublishedChanges() &&
+			c.Question == strings.TrimSpace(t.Question) && c.Answer == strings.TrimSpace(t.Answer) {
+			continue
+		}
+		err := w.PublishFAQText(faqID, t.Locale, author)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+///// Import persistence
+
+// importWriter saves the changes of an import. FAQRepository.ImportFAQs
+// passes one writing in a transaction.
+type importWriter interface {
+	CreateCategory(slug string, author string) (*Category, error)
+	SaveCategory(category *Category, author string) error
+	CreateFAQWithTexts(faq *FAQ) error
+	SaveFAQTexts(faqID int, texts []FAQText) error
+	MoveFAQ(faqID int, categoryID int, position int, author string) error
+	PublishFAQText(faqID int, localeCode string, author string) error
+}
+
+// dryRunWriter saves nothing. Categories it creates get negative IDs, so that
+// FAQs moved into them are told apart from uncategorized ones.
+type dryRunWriter struct {
+	categories int
+}
+
+func (w *dryRunWriter) CreateCategory(slug string, author string) (*Category, error) {
+	w.categories++
+	return &Category{ID: -w.categories, Slug: slug, Names: []CategoryName{}}, nil
+}
+
+func (w *dryRunWriter) SaveCategory(category *Category, author string) error {
+	return nil
+}
+
+func (w *dryRunWriter) CreateFAQWithTexts(faq *FAQ) error {
+	return nil
+}
+
+func (w *dryRunWriter) SaveFAQTexts(faqID int, texts []FAQText) error {
+	return nil
+}
+
+func (w *dryRunWriter) MoveFAQ(faqID int, categoryID int, position int, author string) error {
+	return nil
+}
+
+func (w *dryRunWriter) PublishFAQText(faqID int, localeCode string, author string) error {
+	return nil
+}
+
+// txWriter writes to a SQL transaction, saving and publishing texts with the
+// statements of the database.
+type txWriter struct {
+	tx      dbtx
+	save    func(dbtx, int, *FAQText) error
+	publish func(dbtx, int, string) error
+}
+
+func (w *txWriter) CreateCategory(slug string, author string) (*Category, error) {
+	category, err := createCategory(w.tx, slug)
+	if err != nil {
+		return nil, err
+	}
+	return category, addAuditEntry(w.tx, author, auditCreateCategory, categoryDetails(slug))
+}
+
+func (w *txWriter) SaveCategory(category *Category, author string) error {
+	err := saveCategory(w.tx, category)
+	if err != nil {
+		return err
+	}
+	return addAuditEntry(w.tx, author, auditSaveCategory, categoryDetails(category.Slug))
+}
+
+func (w *txWriter) CreateFAQWithTexts(faq *FAQ) error {
+	return createFAQWithTexts(w.tx, faq, w.save)
+}
+
+func (w *txWriter) SaveFAQTexts(faqID int, texts []FAQText) error {
+	return saveFAQTexts(w.tx, faqID, texts, w.save)
+}
+
+func (w *txWriter) MoveFAQ(faqID int, categoryID int, position int, author string) error {
+	err := moveFAQ(w.tx, faqID, categoryID, position)
+	if err != nil {
+		return err
+	}
+	return addAuditEntry(w.tx, author, auditMoveFAQ, faqMoveDetails(faqID, categoryID, position))
+}
+
+func (w *txWriter) PublishFAQText(faqID int, localeCode string, author string) error {
+	err := w.publish(w.tx, faqID, localeCode)
+	if err != nil {
+		return err
+	}
+	return addAuditEntry(w.tx, author, auditPublishText, faqTextDetails(faqID, localeCode))
+}
+
+func (db *DB) ImportFAQs(fn func(w importWriter) error) error {
+	return withTx(db.DB, func(tx *sql.Tx) error {
+		return fn(&txWriter{tx: tx, save: saveFAQText, publish: publishFAQText})
+	})
+}
+
+func (mdb *mockDB) ImportFAQs(fn func(w importWriter) error) error {
+	return fn(mdb)
+}
+
+func (mdb *brokenDB) ImportFAQs(fn func(w importWriter) error) error {
+	return errors.New(someDBError)
+}
+
+// readImport decodes and validates a document to be imported.
+func readImport(repo FAQRepository, r io.Reader) (*ExportDocument, error) {
+	doc := ExportDocument{}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, errors.New("invalid JSON")
+	}
+	if err := validateImport(repo, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+///// Import and export handlers
+
+func getAPIExport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	doc, err := exportFAQs(faqRepository)
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeJSON(w, doc)
+}
+
+func postAPIImport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	doc, err := readImport(faqRepository, http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := importFAQs(faqRepository, doc, apiAuthor(r), r.FormValue("dry_run") == "true")
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeJSON(w, result)
+}
+
+type ImportPageData struct {
+	PageTitle string
+	MenuBar   []MenuEntry
+	Result    *ImportResult
+	Document  string // Previewed document, to be imported on confirmation
+	Error     string
+}
+
+func getAdminImport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	data := ImportPageData{
+		PageTitle: "Admin / Import & Export",
+		MenuBar:   menuBar("Import & Export"),
+	}
+	mustExecuteTemplate(tmplAdminImport, w, data)
+}
+
+func getAdminExport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	doc, err := exportFAQs(faqRepository)
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	filename := fmt.Sprintf("faqaas-%s.json", doc.ExportedAt.Format("2006-01-02"))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	writeJSON(w, doc)
+}
+
+// postAdminImport imports an uploaded document, or the document field of a
+// previewed import.
+func postAdminImport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	data := ImportPageData{
+		PageTitle: "Admin / Import & Export",
+		MenuBar:   menuBar("Import & Export"),
+	}
+
+	var document []byte
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, _, err := r.FormFile("document")
+	if err == nil {
+		defer file.Close()
+		document, err = ioutil.ReadAll(file)
+	} else {
+		document = []byte(r.FormValue("document"))
+		err = nil
+	}
+
+	var doc *ExportDocument
+	if err == nil {
+		doc, err = readImport(faqRepository, bytes.NewReader(document))
+	}
+	if err != nil {
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		mustExecuteTemplate(tmplAdminImport, w, data)
+		return
+	}
+
+	dryRun := r.FormValue("dryRun") == "true"
+	result, err := importFAQs(faqRepository, doc, currentAuthor(r), dryRun)
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	data.Result = result
+	if dryRun {
+		data.Document = string(document)
+	}
+	mustExecuteTemplate(tmplAdminImport, w, data)
+}
+
+///// Import and export commands
+
+// runExportCommand implements "faqaas export", writing the document to out.
+func runExportCommand(repo FAQRepository, args []string, out io.Writer) error {
+	if len(args) != 0 {
+		return errors.New("usage: faqaas export > faqs.json")
+	}
+	doc, err := exportFAQs(repo)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// runImportCommand implements "faqaas import [-dry-run] faqs.json".
+func runImportCommand(repo FAQRepository, args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(out)
+	dryRun := flags.Bool("dry-run", false, "show what would be imported without saving anything")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: faqaas import [-dry-run] faqs.json")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	doc, err := readImport(repo, file)
+	if err != nil {
+		return err
+	}
+
+	result, err := importFAQs(repo, doc, cliAuthor, *dryRun)
+	if err != nil {
+		return err
+	}
+	for _, slug := range result.CategoriesCreated {
+		fmt.Fprintf(out, "create category  %s\n", slug)
+	}
+	for _, slug := range result.CategoriesUpdated {
+		fmt.Fprintf(out, "update category  %s\n", slug)
+	}
+	for _, faq := range result.FAQs {
+		fmt.Fprintf(out, "%-9s  %s", faq.Action, faq.Question)
+		if len(faq.Locales) > 0 {
+			fmt.Fprintf(out, " (%s)", strings.Join(faq.Locales, ", "))
+		}
+		fmt.Fprintln(out)
+	}
+	fmt.Fprintf(out, "%d created, %d updated, %d unchanged\n",
+		result.Count(importCreate), result.Count(importUpdate), result.Count(importUnchanged))
+	if *dryRun {
+		fmt.Fprintln(out, "dry run, nothing was saved")
+	}
+	return nil
+}
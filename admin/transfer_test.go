@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// seedTransferDB returns a MemoryDB with a category and two FAQs, one of
+// them published in English.
+func seedTransferDB(t *testing.T) *MemoryDB {
+	repo := NewMemoryDB()
+	expectNoError(t, loadLocales(repo))
+	c, err := repo.CreateCategory("billing", testAuthor)
+	expectNoError(t, err)
+	c.Names = []CategoryName{CategoryName{Locale: Locale{Code: "en"}, Name: "Billing"}}
+	expectNoError(t, repo.SaveCategory(c, testAuthor))
+
+	paying := FAQ{CategoryID: c.ID, Position: 1, Texts: []FAQText{
+		FAQText{Locale: Locale{Code: "en"}, Question: "How do I pay?", Answer: "By card."},
+		FAQText{Locale: Locale{Code: "de"}, Question: "Wie bezahle ich?", Answer: "Per Karte."},
+	}}
+	expectNoError(t, repo.CreateFAQWithTexts(&paying))
+	expectNoError(t, repo.PublishFAQText(paying.ID, "en", testAuthor))
+
+	contact := FAQ{Texts: []FAQText{FAQText{Locale: Locale{Code: "en"}, Question: "How do I contact you?", Answer: "By mail."}}}
+	expectNoError(t, repo.CreateFAQWithTexts(&contact))
+	return repo
+}
+
+func TestExportFAQs(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+
+	resp := doRequest("GET", "/api/export", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"version":1,`)
+	expectBodyContains(t, resp, `"categories":[{"slug":"billing","position":1,"names":{"en":"Billing"}}]`)
+	expectBodyContains(t, resp, `{"id":2,"category":"billing","position":1,"texts":[{"locale":"en","question":"How do I pay?","answer":"By card.","published":true},{"locale":"de","question":"Wie bezahle ich?","answer":"Per Karte.","published":false}]}`)
+	expectBodyContains(t, resp, `{"id":5,"position":0,"texts":[{"locale":"en","question":"How do I contact you?","answer":"By mail.","published":false}]}`)
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("GET", "/api/export", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestImportFAQs(t *testing.T) {
+	doc, err := exportFAQs(seedTransferDB(t))
+	expectNoError(t, err)
+
+	repo := NewMemoryDB()
+	expectNoError(t, validateImport(repo, doc))
+	result, err := importFAQs(repo, doc, "importer", true)
+	expectNoError(t, err)
+	expectSameInt(t, 2, result.Count(importCreate))
+	expectSameString(t, "billing", strings.Join(result.CategoriesCreated, ","))
+	faqs, _ := repo.AllFAQs()
+	expectNoFAQs(t, faqs)
+
+	result, err = importFAQs(repo, doc, "importer", false)
+	expectNoError(t, err)
+	expectSameInt(t, 2, result.Count(importCreate))
+	faqs, _ = repo.AllFAQs()
+	expectSameInt(t, 2, len(faqs))
+	expectSameString(t, "How do I pay?", faqs[0].Texts[0].Question)
+	expectIsTrue(t, faqs[0].Texts[0].IsPublished())
+	expectIsTrue(t, !faqs[0].Texts[1].IsPublished())
+	expectIsTrue(t, faqs[0].CategoryID > 0)
+	revisions, _ := repo.FAQTextRevisions(faqs[0].ID, "en")
+	expectSameString(t, "importer", revisions[0].Author)
+
+	result, err = importFAQs(repo, doc, "importer", false)
+	expectNoError(t, err)
+	expectSameInt(t, 2, result.Count(importUnchanged))
+	expectSameInt(t, 0, len(result.CategoriesCreated))
+
+	doc.FAQs[0].Texts[1].Answer = "Per Karte oder Rechnung."
+	doc.FAQs[0].Texts[1].Published = true
+	result, err = importFAQs(repo, doc, "importer", false)
+	expectNoError(t, err)
+	expectSameString(t, importUpdate, result.FAQs[0].Action)
+	expectSameString(t, "de", strings.Join(result.FAQs[0].Locales, ","))
+	expectSameString(t, importUnchanged, result.FAQs[1].Action)
+	f, _ := repo.FAQById(faqs[0].ID)
+	expectSameString(t, "Per Karte oder Rechnung.", f.Texts[1].PublishedAnswer)
+}
+
+func TestImportFAQsDryRunMatchesImport(t *testing.T) {
+	repo := seedTransferDB(t)
+	doc, err := exportFAQs(repo)
+	expectNoError(t, err)
+	doc.Categories = append(doc.Categories, ExportCategory{Slug: "contact", Position: 2})
+	doc.FAQs[1].Category = "contact"
+	doc.FAQs[1].Position = 1
+
+	preview, err := importFAQs(repo, doc, "importer", true)
+	expectNoError(t, err)
+	result, err := importFAQs(repo, doc, "importer", false)
+	expectNoError(t, err)
+	expectSameString(t, "contact", strings.Join(preview.CategoriesCreated, ","))
+	expectSameString(t, importUpdate, preview.FAQs[1].Action)
+	expectSameString(t, importUpdate, result.FAQs[1].Action)
+}
+
+var errPublishFailed = errors.New("publish failed")
+
+// failingImportDB fails imports when publishing the first text.
+type failingImportDB struct {
+	FAQRepository
+}
+
+func (db *failingImportDB) ImportFAQs(fn func(w importWriter) error) error {
+	return db.FAQRepository.ImportFAQs(func(w importWriter) error {
+		return fn(&failingPublishWriter{w})
+	})
+}
+
+type failingPublishWriter struct {
+	importWriter
+}
+
+func (w *failingPublishWriter) PublishFAQText(faqID int, localeCode string, author string) error {
+	return errPublishFailed
+}
+
+func testImportFAQsRollback(t *testing.T, repo FAQRepository) {
+	doc := ExportDocument{Version: exportFormatVersion,
+		Categories: []ExportCategory{ExportCategory{Slug: "billing", Position: 1}},
+		FAQs: []ExportFAQ{
+			ExportFAQ{Category: "billing", Texts: []ExportText{ExportText{Locale: "en", Question: "How do I pay?", Answer: "By card."}}},
+			ExportFAQ{Texts: []ExportText{ExportText{Locale: "en", Question: "Why?", Answer: "Because.", Published: true}}},
+		}}
+
+	_, err := importFAQs(&failingImportDB{repo}, &doc, "importer", false)
+	expectSameError(t, errPublishFailed, err)
+	faqs, _ := repo.AllFAQs()
+	expectNoFAQs(t, faqs)
+	categories, _ := repo.AllCategories()
+	expectSameInt(t, 0, len(categories))
+	entries, _ := repo.AuditLog(auditPageSize)
+	expectSameInt(t, 0, len(entries))
+
+	result, err := importFAQs(repo, &doc, "importer", false)
+	expectNoError(t, err)
+	expectSameInt(t, 2, result.Count(importCreate))
+	faqs, _ = repo.AllFAQs()
+	expectSameInt(t, 2, len(faqs))
+	entries, _ = repo.AuditLog(auditPageSize)
+	expectSameString(t, "importer", entries[0].Author)
+	expectSameString(t, auditPublishText, entries[0].Action)
+}
+
+func TestImportFAQsRollback(t *testing.T) {
+	testImportFAQsRollback(t, NewMemoryDB())
+}
+
+func TestImportFAQsRollbackInDB(t *testing.T) {
+	testImportFAQsRollback(t, prepareDB())
+}
+
+func TestPostAPIImport(t *testing.T) {
+	faqRepository = NewMemoryDB()
+
+	doc := `{"version":1,"categories":[],"faqs":[{"texts":[{"locale":"en","question":"Why?","answer":"Because.","published":true}]}]}`
+	resp := doRequestWithHeader("POST", "/api/import?dry_run=true", body(doc), jsonHeader())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"dry_run":true,"categories_created":[],"categories_updated":[],"faqs":[{"question":"Why?","action":"create","locales":["en"]}]}`)
+
+	resp = doRequestWithHeader("POST", "/api/import", body(doc), jsonHeader())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `"faqs":[{"id":1,"question":"Why?","action":"create","locales":["en"]}]`)
+	resp = doRequest("GET", "/api/faqs/1", emptyBody())
+	expectStatus(t, resp, 200)
+
+	resp = doRequestWithHeader("POST", "/api/import", body(`{"version":2}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "unsupported version: 2")
+	resp = doRequestWithHeader("POST", "/api/import", body(`{"version":1,"faqs":[{"category":"billing","texts":[{"locale":"en","question":"q","answer":"a"}]}]}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "faq 1: category not found: billing")
+	resp = doRequestWithHeader("POST", "/api/import", body(`{"version":1,"faqs":[{"texts":[{"locale":"xx","question":"q","answer":"a"}]}]}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "faq 1: unsupported locale: xx")
+	resp = doRequestWithHeader("POST", "/api/import", body(`{"version":`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "invalid JSON")
+}
+
+func TestPostAdminImport(t *testing.T) {
+	faqRepository = NewMemoryDB()
+	authenticateFunc = alwaysAdminFunc
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, err := mw.CreateFormFile("document", "faqs.json")
+	expectNoError(t, err)
+	fw.Write([]byte(`{"version":1,"faqs":[{"texts":[{"locale":"en","question":"Why?","answer":"Because."}]}]}`))
+	mw.WriteField("dryRun", "true")
+	mw.Close()
+	header := http.Header{}
+	header.Set("Content-Type", mw.FormDataContentType())
+
+	resp := doRequestWithHeader("POST", "/admin/import", &form, &header)
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, "Preview, nothing has been saved yet")
+	expectBodyContains(t, resp, `<textarea name="document" hidden>{&#34;version&#34;:1,`)
+	faqs, _ := faqRepository.AllFAQs()
+	expectNoFAQs(t, faqs)
+
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp = doRequestWithHeader("POST", "/admin/import", body(`document=`+`{"version":1,"faqs":[{"texts":[{"locale":"en","question":"Why?","answer":"Because."}]}]}`), &header)
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, "Imported:")
+	faqs, _ = faqRepository.AllFAQs()
+	expectSameInt(t, 1, len(faqs))
+
+	resp = doRequestWithHeader("POST", "/admin/import", body(`document={}`), &header)
+	expectStatus(t, resp, 400)
+	expectBodyContains(t, resp, "unsupported version: 0")
+
+	resp = doRequest("GET", "/admin/export", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeaderMatches(t, resp, "Content-Disposition", `^attachment; filename="faqaas-\d{4}-\d{2}-\d{2}\.json"$`)
+}
+
+func TestImportAndExportCommands(t *testing.T) {
+	var out bytes.Buffer
+	err := runExportCommand(seedTransferDB(t), nil, &out)
+	expectNoError(t, err)
+	doc := ExportDocument{}
+	expectNoError(t, json.Unmarshal(out.Bytes(), &doc))
+	expectSameInt(t, 2, len(doc.FAQs))
+
+	dir, err := ioutil.TempDir("", "faqaas")
+	expectNoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "faqs.json")
+	expectNoError(t, ioutil.WriteFile(path, out.Bytes(), 0644))
+
+	repo := NewMemoryDB()
+	out.Reset()
+	err = runImportCommand(repo, []string{"-dry-run", path}, &out)
+	expectNoError(t, err)
+	expectSameString(t, `create category  billing
+create     How do I pay? (en, de)
+create     How do I contact you? (en)
+2 created, 0 updated, 0 unchanged
+dry run, nothing was saved
+`, out.String())
+
+	out.Reset()
+	err = runImportCommand(repo, []string{path}, &out)
+	expectNoError(t, err)
+	faqs, _ := repo.AllFAQs()
+	expectSameInt(t, 2, len(faqs))
+
+	err = runImportCommand(repo, nil, &out)
+	expectSameString(t, "usage: faqaas import [-dry-run] faqs.json", err.Error())
+}
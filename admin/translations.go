@@ -0,0 +1,429 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// TranslationUnit is an FAQ to be translated from the default locale into a
+// target locale. Question and Answer are the translation, empty while the
+// FAQ is untranslated. ID identifies the unit in translation files; FAQID is
+// zero for units with an invalid ID.
+type TranslationUnit struct {
+	ID             string
+	FAQID          int
+	SourceQuestion string
+	SourceAnswer   string
+	Question       string
+	Answer         string
+}
+
+// unitID identifies the unit of an FAQ in translation files.
+func unitID(faqID int) string {
+	return fmt.Sprintf("faq-%d", faqID)
+}
+
+// parseUnitID returns the FAQ ID of a unit, or zero if id is invalid.
+func parseUnitID(id string) int {
+	if !strings.HasPrefix(id, "faq-") {
+		return 0
+	}
+	faqID, err := strconv.Atoi(strings.TrimPrefix(id, "faq-"))
+	if err != nil || faqID < 0 {
+		return 0
+	}
+	return faqID
+}
+
+// TranslationReport lists what happened to the units of an imported
+// translation file. Conflicts are units translated from a source text that
+// has changed since the export.
+type TranslationReport struct {
+	Locale    string            `json:"locale"`
+	Saved     []int             `json:"saved"`
+	Skipped   []TranslationSkip `json:"skipped"`
+	Conflicts []TranslationSkip `json:"conflicts"`
+}
+
+type TranslationSkip struct {
+	Unit   string `json:"unit"`
+	Reason string `json:"reason"`
+}
+
+// TranslationImportError is the body of an import that failed while saving.
+// Reports tell what was saved before.
+type TranslationImportError struct {
+	Error   string               `json:"error"`
+	Reports []*TranslationReport `json:"reports"`
+}
+
+// translationUnits returns a unit for each FAQ with a text in the default
+// locale, with its current text in localeCode as the translation.
+func translationUnits(repo FAQRepository, localeCode string) ([]TranslationUnit, error) {
+	faqs, err := repo.AllFAQs()
+	if err != nil {
+		return nil, err
+	}
+
+	units := []TranslationUnit{}
+	for _, faq := range faqs {
+		source := faq.TextForLocale(getDefaultLocale().Code)
+		if len(source.Question) == 0 {
+			continue
+		}
+		target := faq.TextForLocale(localeCode)
+		units = append(units, TranslationUnit{
+			ID:             unitID(faq.ID),
+			FAQID:          faq.ID,
+			SourceQuestion: source.Question,
+			SourceAnswer:   source.Answer,
+			Question:       target.Question,
+			Answer:         target.Answer,
+		})
+	}
+	return units, nil
+}
+
+// validateTargetLocale checks that texts can be translated into localeCode.
+func validateTargetLocale(localeCode string) error {
+	if !isSupportedLocale(localeCode) {
+		return fmt.Errorf("unsupported locale: %v", localeCode)
+	}
+	if localeCode == getDefaultLocale().Code {
+		return fmt.Errorf("cannot translate into the default locale: %v", localeCode)
+	}
+	return nil
+}
+
+// importTranslations saves the translated units into localeCode. Units with
+// invalid or duplicate IDs, units of unknown FAQs and untranslated or
+// unchanged units are skipped. Units whose
+// source no longer matches the text in the default locale are conflicts and
+// not saved either.
+func importTranslations(repo FAQRepository, localeCode string, units []TranslationUnit, author string) (*TranslationReport, error) {
+	faqs, err := repo.AllFAQs()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]FAQ)
+	for _, faq := range faqs {
+		byID[faq.ID] = faq
+	}
+
+	report := TranslationReport{Locale: localeCode, Saved: []int{}, Skipped: []TranslationSkip{}, Conflicts: []TranslationSkip{}}
+	skip := func(unit TranslationUnit, reason string) {
+		report.Skipped = append(report.Skipped, TranslationSkip{Unit: unit.ID, Reason: reason})
+	}
+	seen := make(map[int]bool)
+	for _, unit := range units {
+		if unit.FAQID == 0 {
+			skip(unit, "invalid unit id")
+			continue
+		}
+		if seen[unit.FAQID] {
+			skip(unit, "duplicate unit")
+			continue
+		}
+		seen[unit.FAQID] = true
+
+		faq, ok := byID[unit.FAQID]
+		if !ok {
+			skip(unit, "faq not found")
+			continue
+		}
+		text := FAQText{
+			Locale:   localeFromCode(localeCode),
+			Question: strings.TrimSpace(unit.Question),
+			Answer:   strings.TrimSpace(unit.Answer),
+			Author:   author,
+		}
+		if len(text.Question) == 0 || len(text.Answer) == 0 {
+			skip(unit, "not translated")
+			continue
+		}
+		source := faq.TextForLocale(getDefaultLocale().Code)
+		if !sameText(source.Question, unit.SourceQuestion) || !sameText(source.Answer, unit.SourceAnswer) {
+			report.Conflicts = append(report.Conflicts, TranslationSkip{Unit: unit.ID, Reason: "source changed"})
+			continue
+		}
+		if current := faq.TextForLocale(localeCode); current.Question == text.Question && current.Answer == text.Answer {
+			skip(unit, "unchanged")
+			continue
+		}
+
+		err = repo.SaveFAQText(faq.ID, &text)
+		if err != nil {
+			return &report, err
+		}
+		report.Saved = append(report.Saved, faq.ID)
+	}
+	return &report, nil
+}
+
+// sameText compares texts ignoring surrounding whitespace and line endings,
+// which translation tools may not preserve.
+func sameText(a, b string) bool {
+	normalize := func(s string) string {
+		return strings.TrimSpace(strings.Replace(s, "\r\n", "\n", -1))
+	}
+	return normalize(a) == normalize(b)
+}
+
+///// Translation files
+
+// translationSet holds the units of a translation file for one locale.
+type translationSet struct {
+	Locale string
+	Units  []TranslationUnit
+}
+
+// unitBuilder collects the question and answer fields of units from
+// translation files that store them separately, in order of appearance.
+type unitBuilder struct {
+	units []TranslationUnit
+	index map[string]int
+}
+
+func (b *unitBuilder) set(id, field, source, target string) {
+	if b.index == nil {
+		b.index = make(map[string]int)
+	}
+	i, ok := b.index[id]
+	if !ok {
+		i = len(b.units)
+		b.index[id] = i
+		b.units = append(b.units, TranslationUnit{ID: id, FAQID: parseUnitID(id)})
+	}
+	switch field {
+	case "question":
+		b.units[i].SourceQuestion, b.units[i].Question = source, target
+	case "answer":
+		b.units[i].SourceAnswer, b.units[i].Answer = source, target
+	}
+}
+
+// translationFormat is a file format for the translations of one locale.
+type translationFormat struct {
+	Extension   string
+	ContentType string
+	encode      func(w io.Writer, localeCode string, units []TranslationUnit) error
+	decode      func(r io.Reader) (string, []TranslationUnit, error)
+}
+
+var translationFormats = map[string]translationFormat{
+	"xliff": translationFormat{Extension: ".xlf", ContentType: xliffContentType, encode: encodeXLIFF, decode: readXLIFF},
+	"po":    translationFormat{Extension: ".po", ContentType: poContentType, encode: encodePO, decode: readPO},
+}
+
+// readTranslationFile reads an uploaded translation file, telling its format
+// by the extension of filename.
+func readTranslationFile(filename string, r io.Reader) ([]translationSet, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".csv" {
+		return readCSV(r)
+	}
+	if ext == ".xliff" {
+		ext = ".xlf"
+	}
+	for _, format := range translationFormats {
+		if ext == format.Extension {
+			localeCode, units, err := format.decode(r)
+			if err != nil {
+				return nil, err
+			}
+			return []translationSet{translationSet{Locale: localeCode, Units: units}}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported file type: %v", filename)
+}
+
+// importTranslationSets imports the sets one locale after the other. Texts
+// are saved one by one, so when saving fails the reports of what was saved
+// up to then are returned along with the error.
+func importTranslationSets(repo FAQRepository, sets []translationSet, author string) ([]*TranslationReport, error) {
+	reports := []*TranslationReport{}
+	for _, set := range sets {
+		report, err := importTranslations(repo, set.Locale, set.Units, author)
+		if report != nil {
+			reports = append(reports, report)
+		}
+		if err != nil {
+			return reports, err
+		}
+	}
+	return reports, nil
+}
+
+///// Translation handlers
+
+// writeTranslations writes the units for localeCode in format.
+func writeTranslations(w http.ResponseWriter, format translationFormat, localeCode string, units []TranslationUnit) {
+	w.Header().Set("Content-Type", format.ContentType)
+	err := format.encode(w, localeCode, units)
+	if err != nil {
+		logError(err)
+	}
+}
+
+// writeCSVTranslations writes the rows of a CSV file with all locales.
+func writeCSVTranslations(w http.ResponseWriter, rows [][]string) {
+	w.Header().Set("Content-Type", csvContentType)
+	err := encodeCSV(w, rows)
+	if err != nil {
+		logError(err)
+	}
+}
+
+func getAPITranslations(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	format, ok := translationFormats[ps.ByName("format")]
+	if !ok {
+		writeJSONErr(w, http.StatusNotFound, "unsupported format")
+		return
+	}
+	localeCode := ps.ByName("locale")
+	if err := validateTargetLocale(localeCode); err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	units, err := translationUnits(faqRepository, localeCode)
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeTranslations(w, format, localeCode, units)
+}
+
+func getAPITranslationsCSV(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	rows, err := translationRows(faqRepository)
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeCSVTranslations(w, rows)
+}
+
+// postAPITranslations imports a translation file and responds with its
+// report, or with a list of reports by locale for CSV files.
+func postAPITranslations(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	formatName := ps.ByName("format")
+	ext := ".csv"
+	if format, ok := translationFormats[formatName]; ok {
+		ext = format.Extension
+	} else if formatName != "csv" {
+		writeJSONErr(w, http.StatusNotFound, "unsupported format")
+		return
+	}
+	sets, err := readTranslationFile("translations"+ext, http.MaxBytesReader(w, r.Body, maxImportSize))
+	if err != nil {
+		writeJSONErr(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	reports, err := importTranslationSets(faqRepository, sets, apiAuthor(r))
+	if err != nil {
+		writeJSONWithStatus(w, http.StatusInternalServerError, TranslationImportError{Error: internalError, Reports: reports})
+		return
+	}
+	if formatName == "csv" {
+		writeJSON(w, reports)
+		return
+	}
+	writeJSON(w, reports[0])
+}
+
+type TranslationsPageData struct {
+	PageTitle string
+	MenuBar   []MenuEntry
+	Locales   []Locale // Locales to translate into
+	Reports   []*TranslationReport
+	Error     string
+}
+
+func translationsPageData() TranslationsPageData {
+	locales := []Locale{}
+	for _, l := range getSupportedLocales() {
+		if !l.IsDefaultLocale() {
+			locales = append(locales, l)
+		}
+	}
+	return TranslationsPageData{
+		PageTitle: "Admin / Translations",
+		MenuBar:   menuBar("Translations"),
+		Locales:   locales,
+	}
+}
+
+func getAdminTranslations(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	mustExecuteTemplate(tmplAdminTranslations, w, translationsPageData())
+}
+
+func getAdminTranslationsFile(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	format, ok := translationFormats[ps.ByName("format")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	localeCode := ps.ByName("locale")
+	if err := validateTargetLocale(localeCode); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	units, err := translationUnits(faqRepository, localeCode)
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="faqs-%s%s"`, localeCode, format.Extension))
+	writeTranslations(w, format, localeCode, units)
+}
+
+func getAdminTranslationsCSV(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	rows, err := translationRows(faqRepository)
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="faqs.csv"`)
+	writeCSVTranslations(w, rows)
+}
+
+func postAdminTranslationsImport(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	data := translationsPageData()
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		data.Error = "no file uploaded"
+		w.WriteHeader(http.StatusBadRequest)
+		mustExecuteTemplate(tmplAdminTranslations, w, data)
+		return
+	}
+	defer file.Close()
+
+	sets, err := readTranslationFile(header.Filename, file)
+	if err != nil {
+		data.Error = err.Error()
+		w.WriteHeader(http.StatusBadRequest)
+		mustExecuteTemplate(tmplAdminTranslations, w, data)
+		return
+	}
+	for _, set := range sets {
+		if !canEditLocale(r, set.Locale) {
+			data.Error = fmt.Sprintf("not allowed to edit locale: %v", set.Locale)
+			w.WriteHeader(http.StatusForbidden)
+			mustExecuteTemplate(tmplAdminTranslations, w, data)
+			return
+		}
+	}
+	data.Reports, err = importTranslationSets(faqRepository, sets, currentAuthor(r))
+	if err != nil {
+		data.Error = "The import stopped because of an internal error. Only the translations reported below were saved."
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	mustExecuteTemplate(tmplAdminTranslations, w, data)
+}
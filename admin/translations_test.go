@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGetAPITranslationsXLIFF(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+
+	resp := doRequest("GET", "/api/translations/de/xliff", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeaderMatches(t, resp, "Content-Type", `^application/xliff\+xml; charset=utf-8$`)
+	expectBodyContains(t, resp, `<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="de">`)
+	expectBodyContains(t, resp, `<unit id="faq-2">
+      <segment id="question" state="translated">
+        <source>How do I pay?</source>
+        <target>Wie bezahle ich?</target>
+      </segment>`)
+	expectBodyContains(t, resp, `<unit id="faq-5">
+      <segment id="question" state="initial">
+        <source>How do I contact you?</source>
+      </segment>`)
+
+	resp = doRequest("GET", "/api/translations/en/xliff", emptyBody())
+	expectErrorJSON(t, resp, 400, "cannot translate into the default locale: en")
+	resp = doRequest("GET", "/api/translations/xx/xliff", emptyBody())
+	expectErrorJSON(t, resp, 400, "unsupported locale: xx")
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("GET", "/api/translations/de/xliff", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestImportTranslations(t *testing.T) {
+	repo := seedTransferDB(t)
+	units, err := translationUnits(repo, "fr")
+	expectNoError(t, err)
+	expectSameInt(t, 2, len(units))
+
+	units[0].Question = "Comment payer ?"
+	units[0].Answer = "Par carte."
+	units[1].Question = "Comment vous contacter ?"
+	units[1].Answer = "Par courrier."
+	units[1].SourceAnswer = "By phone."
+	units = append(units,
+		TranslationUnit{ID: "faq-99", FAQID: 99, Question: "q", Answer: "a"},
+		TranslationUnit{ID: "question-1", Question: "q", Answer: "a"},
+		TranslationUnit{ID: "faq-2", FAQID: 2, Question: "q", Answer: "a"},
+	)
+
+	report, err := importTranslations(repo, "fr", units, "translator")
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(report.Saved))
+	expectSameInt(t, 2, report.Saved[0])
+	expectSameInt(t, 1, len(report.Conflicts))
+	expectSameString(t, "faq-5: source changed", report.Conflicts[0].Unit+": "+report.Conflicts[0].Reason)
+	expectSameInt(t, 3, len(report.Skipped))
+	expectSameString(t, "faq-99: faq not found", report.Skipped[0].Unit+": "+report.Skipped[0].Reason)
+	expectSameString(t, "question-1: invalid unit id", report.Skipped[1].Unit+": "+report.Skipped[1].Reason)
+	expectSameString(t, "faq-2: duplicate unit", report.Skipped[2].Unit+": "+report.Skipped[2].Reason)
+
+	faq, _ := repo.FAQById(2)
+	text := faq.TextForLocale("fr")
+	expectSameString(t, "Comment payer ?", text.Question)
+	expectIsTrue(t, text.HasUnpublishedChanges())
+	revisions, _ := repo.FAQTextRevisions(2, "fr")
+	expectSameString(t, "translator", revisions[0].Author)
+
+	units, _ = translationUnits(repo, "fr")
+	report, err = importTranslations(repo, "fr", units, "translator")
+	expectNoError(t, err)
+	expectSameInt(t, 0, len(report.Saved))
+	expectSameString(t, "faq-2: unchanged", report.Skipped[0].Unit+": "+report.Skipped[0].Reason)
+	expectSameString(t, "faq-5: not translated", report.Skipped[1].Unit+": "+report.Skipped[1].Reason)
+}
+
+func TestXLIFFRoundTrip(t *testing.T) {
+	repo := seedTransferDB(t)
+	units, _ := translationUnits(repo, "de")
+	units[0].Answer = "Per Karte.\r\nOder per Rechnung & Mahnung."
+
+	var doc bytes.Buffer
+	expectNoError(t, encodeXLIFF(&doc, "de", units))
+	localeCode, read, err := readXLIFF(&doc)
+	expectNoError(t, err)
+	expectSameString(t, "de", localeCode)
+	expectSameInt(t, 2, len(read))
+	expectSameString(t, "faq-2", read[0].ID)
+	expectSameInt(t, 2, read[0].FAQID)
+	expectSameString(t, "How do I pay?", read[0].SourceQuestion)
+	expectSameString(t, units[0].Answer, read[0].Answer)
+	expectSameString(t, "", read[1].Question)
+}
+
+func TestReadXLIFFValidation(t *testing.T) {
+	tests := []struct {
+		doc string
+		err string
+	}{
+		{`<xliff`, "invalid XLIFF"},
+		{`<xliff xmlns="urn:oasis:names:tc:xliff:document:1.2" version="1.2"></xliff>`, "invalid XLIFF"},
+		{`<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.1" srcLang="en" trgLang="de"></xliff>`, "unsupported XLIFF version: 2.1"},
+		{`<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="de" trgLang="fr"></xliff>`, "source language must be en, not de"},
+		{`<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="xx"></xliff>`, "unsupported locale: xx"},
+		{`<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en"></xliff>`, "unsupported locale: "},
+	}
+	for _, tt := range tests {
+		_, _, err := readXLIFF(strings.NewReader(tt.doc))
+		if err == nil {
+			t.Errorf("expected error %q for %s", tt.err, tt.doc)
+			continue
+		}
+		expectSameString(t, tt.err, err.Error())
+	}
+}
+
+const translatedXLIFF = `<?xml version="1.0" encoding="UTF-8"?>
+<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="fr">
+  <file id="faqs">
+    <unit id="faq-2">
+      <segment id="question" state="translated">
+        <source>How do I pay?</source>
+        <target>Comment payer ?</target>
+      </segment>
+      <segment id="answer" state="translated">
+        <source>By card.</source>
+        <target>Par carte.</target>
+      </segment>
+    </unit>
+    <unit id="faq-5">
+      <segment id="question" state="initial">
+        <source>How do I contact you?</source>
+      </segment>
+      <segment id="answer" state="initial">
+        <source>By mail.</source>
+      </segment>
+    </unit>
+  </file>
+</xliff>`
+
+func TestPostAPITranslationsXLIFF(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+
+	resp := doRequest("POST", "/api/translations/xliff", body(translatedXLIFF))
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"locale":"fr","saved":[2],"skipped":[{"unit":"faq-5","reason":"not translated"}],"conflicts":[]}`)
+	faq, _ := faqRepository.FAQById(2)
+	expectSameString(t, "Comment payer ?", faq.TextForLocale("fr").Question)
+
+	resp = doRequest("POST", "/api/translations/xliff", body(`<faqs/>`))
+	expectErrorJSON(t, resp, 400, "invalid XLIFF")
+}
+
+func TestPostAdminTranslationsImport(t *testing.T) {
+	faqRepository = seedTransferDB(t)
+	authenticateFunc = alwaysAdminFunc
+
+	resp := doRequest("GET", "/admin/translations", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `href="/admin/translations/de/xliff"`)
+
+	resp = doRequest("GET", "/admin/translations/de/xliff", emptyBody())
+	expectStatus(t, resp, 200)
+	expectHeaderMatches(t, resp, "Content-Disposition", `^attachment; filename="faqs-de\.xlf"$`)
+
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	fw, err := mw.CreateFormFile("file", "faqs-fr.xlf")
+	expectNoError(t, err)
+	fw.Write([]byte(translatedXLIFF))
+	mw.Close()
+	header := http.Header{}
+	header.Set("Content-Type", mw.FormDataContentType())
+
+	resp = doRequestWithHeader("POST", "/admin/translations/import", &form, &header)
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, "1 translations into fr saved, 1 skipped, 0 conflicts.")
+	expectBodyContains(t, resp, "not translated")
+	revisions, _ := faqRepository.FAQTextRevisions(2, "fr")
+	expectSameString(t, "admin", revisions[0].Author)
+
+	resp = doRequestWithHeader("POST", "/admin/translations/import", body(""), &header)
+	expectStatus(t, resp, 400)
+	expectBodyContains(t, resp, "no file uploaded")
+}
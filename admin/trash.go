@@ -0,0 +1,315 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultTrashRetentionDays = 30
+	trashPurgeInterval        = 1 * time.Hour
+)
+
+// trashRetentionDays is how long deleted FAQs stay restorable in the trash.
+var trashRetentionDays int
+
+func init() {
+	trashRetentionDays = defaultTrashRetentionDays
+	if days := os.Getenv("TRASH_RETENTION_DAYS"); len(days) > 0 {
+		var err error
+		trashRetentionDays, err = strconv.Atoi(days)
+		if err != nil || trashRetentionDays < 0 {
+			panic(fmt.Errorf("invalid TRASH_RETENTION_DAYS: %v", days))
+		}
+	}
+}
+
+func trashRetention() time.Duration {
+	return time.Duration(trashRetentionDays) * 24 * time.Hour
+}
+
+func purgeTrash(repo FAQRepository) {
+	n, err := repo.PurgeFAQs(time.Now().Add(-trashRetention()), systemAuthor)
+	if err != nil {
+		logError(err)
+		return
+	}
+	if n > 0 {
+		log.Printf("purged %d FAQs from trash", n)
+	}
+}
+
+func purgeTrashPeriodically(repo FAQRepository, interval time.Duration) {
+	for {
+		purgeTrash(repo)
+		time.Sleep(interval)
+	}
+}
+
+///// Trash persistence
+
+func (db *DB) TrashedFAQs() ([]FAQ, error) {
+	return getTrashedFAQs(db.DB)
+}
+
+func (db *DB) RestoreFAQ(faqID int, author string) error {
+	return withAudit(db.DB, author, auditRestoreFAQ, faqDetails(faqID), func(tx *sql.Tx) error {
+		return restoreFAQ(tx, faqID)
+	})
+}
+
+func (db *DB) PurgeFAQ(faqID int, author string) error {
+	return withAudit(db.DB, author, auditPurgeFAQ, faqDetails(faqID), func(tx *sql.Tx) error {
+		return purgeFAQ(tx, faqID)
+	})
+}
+
+func (db *DB) PurgeFAQs(deletedBefore time.Time, author string) (int, error) {
+	return purgeFAQs(db.DB, deletedBefore, author)
+}
+
+func (mdb *mockDB) TrashedFAQs() ([]FAQ, error) {
+	deletedAt := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	texts := []FAQText{FAQText{Locale: Locale{Code: "en", NameLocal: "English"}, Question: "old question?", Answer: "old answer!"}}
+	return []FAQ{FAQ{ID: 321, Texts: texts, DeletedAt: &deletedAt}}, nil
+}
+
+func (mdb *mockDB) RestoreFAQ(faqID int, author string) error {
+	return nil
+}
+
+func (mdb *mockDB) PurgeFAQ(faqID int, author string) error {
+	return nil
+}
+
+func (mdb *mockDB) PurgeFAQs(deletedBefore time.Time, author string) (int, error) {
+	return 0, nil
+}
+
+func (mdb *brokenDB) TrashedFAQs() ([]FAQ, error) {
+	return nil, errors.New(someDBError)
+}
+
+func (mdb *brokenDB) RestoreFAQ(faqID int, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) PurgeFAQ(faqID int, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) PurgeFAQs(deletedBefore time.Time, author string) (int, error) {
+	return 0, errors.New(someDBError)
+}
+
+func getTrashedFAQs(db *sql.DB) ([]FAQ, error) {
+	rows, err := db.Query(`
+		SELECT id, deleted_at FROM faqs
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC, id;`)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	faqs := []FAQ{}
+	for rows.Next() {
+		var id int
+		var deletedAt pq.NullTime
+		err = rows.Scan(&id, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		faqs = append(faqs, FAQ{ID: id, DeletedAt: &deletedAt.Time})
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	err = addTexts(db, faqs)
+	if err != nil {
+		return nil, err
+	}
+	return faqs, nil
+}
+
+func restoreFAQ(db dbtx, faqID int) error {
+	sqlStatement := `UPDATE faqs SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL;`
+	res, err := db.Exec(sqlStatement, faqID)
+	if err != nil {
+		logError(err)
+		return err
+	}
+	return expectRowsAffected(res, errFAQNotFound)
+}
+
+// purgeFAQ deletes a trashed FAQ, its texts and their revisions for good.
+func purgeFAQ(db dbtx, faqID int) error {
+	sqlStatement := `
+		DELETE FROM faq_text_revisions
+		WHERE faq_id IN (SELECT id FROM faqs WHERE id = $1 AND deleted_at IS NOT NULL);`
+	_, err := db.Exec(sqlStatement, faqID)
+	if err != nil {
+		logError(err)
+		return err
+	}
+
+	sqlStatement = `
+		DELETE FROM faq_texts
+		WHERE faq_id IN (SELECT id FROM faqs WHERE id = $1 AND deleted_at IS NOT NULL);`
+	_, err = db.Exec(sqlStatement, faqID)
+	if err != nil {
+		logError(err)
+		return err
+	}
+
+	sqlStatement = `DELETE FROM faqs WHERE id = $1 AND deleted_at IS NOT NULL;`
+	res, err := db.Exec(sqlStatement, faqID)
+	if err != nil {
+		logError(err)
+		return err
+	}
+	return expectRowsAffected(res, errFAQNotFound)
+}
+
+// purgeFAQs deletes the FAQs trashed before deletedBefore for good and records
+// the purge unless there were none.
+func purgeFAQs(db *sql.DB, deletedBefore time.Time, author string) (int, error) {
+	var n int64
+	err := withTx(db, func(tx *sql.Tx) error {
+		for _, table := range []string{"faq_text_revisions", "faq_texts"} {
+			sqlStatement := `
+				DELETE FROM ` + table + `
+				WHERE faq_id IN (SELECT id FROM faqs WHERE deleted_at < $1);`
+			_, err := tx.Exec(sqlStatement, deletedBefore)
+			if err != nil {
+				logError(err)
+				return err
+			}
+		}
+
+		sqlStatement := `DELETE FROM faqs WHERE deleted_at < $1;`
+		res, err := tx.Exec(sqlStatement, deletedBefore)
+		if err != nil {
+			logError(err)
+			return err
+		}
+		n, err = res.RowsAffected()
+		if err != nil || n == 0 {
+			return err
+		}
+		return addAuditEntry(tx, author, auditPurgeTrash, purgeDetails(int(n), deletedBefore))
+	})
+	return int(n), err
+}
+
+///// Trash handlers
+
+func getAPITrash(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqs, err := faqRepository.TrashedFAQs()
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeJSON(w, faqs)
+}
+
+func postAPIFAQRestore(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, ok := faqIDParam(ps)
+	if !ok {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+
+	err := faqRepository.RestoreFAQ(faqID, apiAuthor(r))
+	if err == errFAQNotFound {
+		writeJSONErr(w, http.StatusNotFound, "faq not found")
+		return
+	}
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+
+	faq, err := faqRepository.FAQById(faqID)
+	if err != nil {
+		writeJSONErr(w, http.StatusInternalServerError, internalError)
+		return
+	}
+	writeJSON(w, faq)
+}
+
+type TrashPageData struct {
+	PageTitle     string
+	MenuBar       []MenuEntry
+	FAQs          []FAQ
+	RetentionDays int
+}
+
+func getAdminTrash(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqs, err := faqRepository.TrashedFAQs()
+	if err != nil {
+		panic(err)
+	}
+	data := TrashPageData{
+		PageTitle:     "Admin / Trash",
+		MenuBar:       menuBar("Trash"),
+		FAQs:          faqs,
+		RetentionDays: trashRetentionDays,
+	}
+	mustExecuteTemplate(tmplAdminTrash, w, data)
+}
+
+func postAdminTrashRestore(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, err := strconv.Atoi(r.FormValue("faqID"))
+	if err != nil {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+
+	err = faqRepository.RestoreFAQ(faqID, currentAuthor(r))
+	if err == errFAQNotFound {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	redirectURL := fmt.Sprintf("/admin/faqs/edit/%d", faqID)
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+func postAdminTrashPurge(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	faqID, err := strconv.Atoi(r.FormValue("faqID"))
+	if err != nil {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+
+	err = faqRepository.PurgeFAQ(faqID, currentAuthor(r))
+	if err == errFAQNotFound {
+		http.Error(w, "faq not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/trash", http.StatusFound)
+}
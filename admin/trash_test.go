@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// trashedDB has FAQ 123 in the trash.
+type trashedDB struct {
+	mockDB
+}
+
+func (tdb *trashedDB) FAQById(id int) (*FAQ, error) {
+	faq, err := tdb.mockDB.FAQById(id)
+	if err != nil {
+		return nil, err
+	}
+	deletedAt := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+	faq.DeletedAt = &deletedAt
+	return faq, nil
+}
+
+func TestGetAPITrash(t *testing.T) {
+	faqRepository = &mockDB{}
+	resp := doRequest("GET", "/api/trash", emptyBody())
+
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"id":321,"texts":[{"locale":{"code":"en","name_local":"English"},"question":"old question?","answer":"old answer!"}],"deleted_at":"2018-06-01T12:00:00Z"}]`)
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("GET", "/api/trash", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestPostAPIFAQRestore(t *testing.T) {
+	faqRepository = &mockDB{}
+	resp := doRequest("POST", "/api/faqs/123/restore", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `{"id":123,`)
+
+	resp = doRequest("POST", "/api/faqs/abc/restore", emptyBody())
+	expectErrorJSON(t, resp, 404, "faq not found")
+
+	faqRepository = &brokenDB{}
+	resp = doRequest("POST", "/api/faqs/123/restore", emptyBody())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestTrashedFAQIsNotServed(t *testing.T) {
+	faqRepository = &trashedDB{}
+
+	resp := doRequest("GET", "/api/faqs/123", emptyBody())
+	expectErrorJSON(t, resp, 404, "faq not found")
+
+	resp = doRequest("GET", "/faq/en/123", emptyBody())
+	expectStatus(t, resp, 404)
+}
+
+func TestGetAdminTrash(t *testing.T) {
+	faqRepository = &mockDB{}
+	resp := doRequest("GET", "/admin/trash", emptyBody())
+
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<title>Admin / Trash</title>`)
+	expectBodyContains(t, resp, `<td>old question?</td>`)
+	expectBodyContains(t, resp, `<td>2018-06-01 12:00</td>`)
+	expectBodyContains(t, resp, `restored for 30 days`)
+}
+
+func TestGetAdminFAQsEditTrashed(t *testing.T) {
+	faqRepository = &trashedDB{}
+	resp := doRequest("GET", "/admin/faqs/edit/123", emptyBody())
+
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `This FAQ is in the trash.`)
+}
+
+func TestPostAdminTrashRestoreAndPurge(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("POST", "/admin/trash/restore", body("faqID=321"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/faqs/edit/321")
+
+	resp = doRequestWithHeader("POST", "/admin/trash/purge", body("faqID=321"), formHeader())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/trash")
+
+	resp = doRequestWithHeader("POST", "/admin/trash/purge", body("faqID=abc"), formHeader())
+	expectStatus(t, resp, 404)
+}
+
+func TestTrash(t *testing.T) {
+	repo := prepareDB()
+
+	f, err := repo.CreateFAQ()
+	expectNoError(t, err)
+	txt := FAQText{Question: "question", Answer: "answer", Locale: Locale{Code: "en"}}
+	err = repo.SaveFAQText(f.ID, &txt)
+	expectNoError(t, err)
+
+	err = repo.DeleteFAQ(f.ID, testAuthor)
+	expectNoError(t, err)
+	err = repo.DeleteFAQ(f.ID, testAuthor)
+	expectSameError(t, errFAQNotFound, err)
+
+	faqs, err := repo.AllFAQs()
+	expectNoError(t, err)
+	expectNoFAQs(t, faqs)
+
+	trashed, err := repo.TrashedFAQs()
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(trashed))
+	expectSameInt(t, 1, len(trashed[0].Texts))
+
+	err = repo.RestoreFAQ(f.ID, testAuthor)
+	expectNoError(t, err)
+	err = repo.RestoreFAQ(f.ID, testAuthor)
+	expectSameError(t, errFAQNotFound, err)
+
+	faqs, err = repo.AllFAQs()
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(faqs))
+
+	err = repo.PurgeFAQ(f.ID, testAuthor)
+	expectSameError(t, errFAQNotFound, err)
+
+	err = repo.DeleteFAQ(f.ID, testAuthor)
+	expectNoError(t, err)
+	n, err := repo.PurgeFAQs(time.Now().Add(-time.Hour), testAuthor)
+	expectNoError(t, err)
+	expectSameInt(t, 0, n)
+	n, err = repo.PurgeFAQs(time.Now().Add(time.Hour), testAuthor)
+	expectNoError(t, err)
+	expectSameInt(t, 1, n)
+
+	revisions, err := repo.FAQTextRevisions(f.ID, "en")
+	expectNoError(t, err)
+	expectSameInt(t, 0, len(revisions))
+
+	trashed, err = repo.TrashedFAQs()
+	expectNoError(t, err)
+	expectNoFAQs(t, trashed)
+}
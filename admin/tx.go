@@ -0,0 +1,94 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// dbtx is implemented by *sql.DB and *sql.Tx, so the same statements can run
+// on their own or as part of a transaction.
+type dbtx interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// withTx runs fn in a transaction. It commits if fn succeeds and rolls back
+// otherwise.
+func withTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		logError(err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(tx)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		logError(err)
+	}
+	return err
+}
+
+///// Transactional persistence
+
+func (db *DB) CreateFAQWithTexts(faq *FAQ) error {
+	return withTx(db.DB, func(tx *sql.Tx) error {
+		return createFAQWithTexts(tx, faq, saveFAQText)
+	})
+}
+
+func (db *DB) SaveFAQTexts(faqID int, texts []FAQText) error {
+	return withTx(db.DB, func(tx *sql.Tx) error {
+		return saveFAQTexts(tx, faqID, texts, saveFAQText)
+	})
+}
+
+func (mdb *mockDB) CreateFAQWithTexts(faq *FAQ) error {
+	faq.ID = 123
+	return nil
+}
+
+func (mdb *mockDB) SaveFAQTexts(faqID int, texts []FAQText) error {
+	return nil
+}
+
+func (mdb *brokenDB) CreateFAQWithTexts(faq *FAQ) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) SaveFAQTexts(faqID int, texts []FAQText) error {
+	return errors.New(someDBError)
+}
+
+// createFAQWithTexts inserts faq, moves it to its category if it has one and
+// saves its texts with save. It sets the ID of faq.
+func createFAQWithTexts(tx dbtx, faq *FAQ, save func(dbtx, int, *FAQText) error) error {
+	created, err := createFAQ(tx)
+	if err != nil {
+		return err
+	}
+	faq.ID = created.ID
+
+	if faq.CategoryID != 0 {
+		err = moveFAQ(tx, faq.ID, faq.CategoryID, faq.Position)
+		if err != nil {
+			return err
+		}
+	}
+	return saveFAQTexts(tx, faq.ID, faq.Texts, save)
+}
+
+func saveFAQTexts(tx dbtx, faqID int, texts []FAQText, save func(dbtx, int, *FAQText) error) error {
+	for i := range texts {
+		err := save(tx, faqID, &texts[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
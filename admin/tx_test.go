@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPutAPIFAQTexts(t *testing.T) {
+	faqRepository = &mockDB{}
+
+	resp := doRequestWithHeader("PUT", "/api/faqs/123/texts", body(`[{"locale":{"code":"en"},"question":"Why?","answer":"Because."},{"locale":{"code":"de"},"question":"Warum?","answer":"Darum."}]`), jsonHeader())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `[{"locale":{"code":"en","name_en":"English","name_local":"English"},"question":"Why?","answer":"Because."},{"locale":{"code":"de","name_en":"German","name_local":"Deutsch"},"question":"Warum?","answer":"Darum."}]`)
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/123/texts", body(`[]`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "texts empty")
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/123/texts", body(`[{"locale":{"code":"en"},"question":"q","answer":"a"},{"locale":{"code":"en"},"question":"q","answer":"a"}]`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "duplicate locale: en")
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/not-a-valid-id/texts", body(`[{"locale":{"code":"en"},"question":"q","answer":"a"}]`), jsonHeader())
+	expectErrorJSON(t, resp, 404, "faq not found")
+
+	faqRepository = &brokenDB{}
+	resp = doRequestWithHeader("PUT", "/api/faqs/123/texts", body(`[{"locale":{"code":"en"},"question":"q","answer":"a"}]`), jsonHeader())
+	expectErrorJSON(t, resp, 500, "internal error")
+}
+
+func TestMemoryDBCreateFAQWithTextsIsAtomic(t *testing.T) {
+	faqRepository = NewMemoryDB()
+
+	resp := doRequestWithHeader("POST", "/api/faqs", body(`{"category_id":7,"texts":[{"locale":{"code":"en"},"question":"q","answer":"a"}]}`), jsonHeader())
+	expectErrorJSON(t, resp, 400, "category not found")
+
+	faqs, err := faqRepository.AllFAQs()
+	expectNoError(t, err)
+	expectNoFAQs(t, faqs)
+
+	resp = doRequestWithHeader("PUT", "/api/faqs/1/texts", body(`[{"locale":{"code":"en"},"question":"q","answer":"a"}]`), jsonHeader())
+	expectErrorJSON(t, resp, 404, "faq not found")
+}
+
+func TestCreateFAQWithTextsInDB(t *testing.T) {
+	repo := prepareDB()
+
+	texts := []FAQText{
+		FAQText{Locale: Locale{Code: "en"}, Question: "Why?", Answer: "Because.", Author: "admin"},
+		FAQText{Locale: Locale{Code: "de"}, Question: "Warum?", Answer: "Darum.", Author: "admin"},
+	}
+	faq := FAQ{CategoryID: 4711, Texts: texts}
+	err := repo.CreateFAQWithTexts(&faq)
+	expectSameError(t, errCategoryNotFound, err)
+
+	faqs, err := repo.AllFAQs()
+	expectNoError(t, err)
+	expectNoFAQs(t, faqs)
+
+	faq = FAQ{Texts: texts}
+	err = repo.CreateFAQWithTexts(&faq)
+	expectNoError(t, err)
+	expectHasID(t, faq.ID)
+
+	stored, err := repo.FAQById(faq.ID)
+	expectNoError(t, err)
+	expectSameInt(t, 2, len(stored.Texts))
+	revisions, err := repo.FAQTextRevisions(faq.ID, "de")
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(revisions))
+
+	texts[0].Question = "Why not?"
+	err = repo.SaveFAQTexts(faq.ID, texts)
+	expectNoError(t, err)
+	stored, err = repo.FAQById(faq.ID)
+	expectNoError(t, err)
+	expectSameString(t, "Why not?", stored.Texts[0].Question)
+
+	err = repo.SaveFAQTexts(faq.ID+1, texts)
+	expectSameError(t, errFAQNotFound, err)
+}
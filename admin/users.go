@@ -0,0 +1,478 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Roles of admin users, each allowed what the previous ones are:
+//
+//   - viewers see all admin pages
+//   - translators save texts in their locales and import translations
+//   - editors manage FAQs, categories and the trash, and publish texts
+//   - admins manage languages and users
+const (
+	roleViewer     = "viewer"
+	roleTranslator = "translator"
+	roleEditor     = "editor"
+	roleAdmin      = "admin"
+)
+
+var roles = []string{roleViewer, roleTranslator, roleEditor, roleAdmin}
+
+// User is an account of the admin UI. Email is the login name, recorded as
+// the author of the changes the user makes.
+type User struct {
+	ID           int      `json:"id"`
+	Email        string   `json:"email"`
+	PasswordHash string   `json:"-"`
+	Role         string   `json:"role"`
+	Locales      []string `json:"locales"` // Locale codes a translator may edit
+}
+
+// HasRole tells whether u is allowed what role is.
+func (u *User) HasRole(role string) bool {
+	return roleRank(u.Role) >= roleRank(role) && roleRank(role) > 0
+}
+
+// CanEditLocale tells whether u may save texts in the given locale.
+func (u *User) CanEditLocale(localeCode string) bool {
+	if u.HasRole(roleEditor) {
+		return true
+	}
+	return u.Role == roleTranslator && containsString(u.Locales, localeCode)
+}
+
+// LocaleCodes returns the locales of a translator as a comma separated list.
+func (u *User) LocaleCodes() string {
+	return strings.Join(u.Locales, ",")
+}
+
+func roleRank(role string) int {
+	for i, r := range roles {
+		if r == role {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+var (
+	errUserExists   = errors.New("user exists")
+	errUserNotFound = errors.New("user not found")
+)
+
+// validateUser checks role and locales of user.
+func validateUser(user *User) error {
+	if len(strings.TrimSpace(user.Email)) == 0 {
+		return errors.New("email empty")
+	}
+	if roleRank(user.Role) == 0 {
+		return fmt.Errorf("unknown role: %v", user.Role)
+	}
+	if user.Role != roleTranslator && len(user.Locales) > 0 {
+		return errors.New("only translators are limited to locales")
+	}
+	for _, code := range user.Locales {
+		if err := validateLocaleCode(code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adminUserEmail is the user created from ADMIN_PASSWORD.
+const adminUserEmail = "admin"
+
+// noAdminPassword in ADMIN_PASSWORD turns off the login, everybody is an
+// admin. For development and tests only.
+const noAdminPassword = "no-admin-password-required"
+
+func adminLoginDisabled() bool {
+	return os.Getenv("ADMIN_PASSWORD") == noAdminPassword
+}
+
+// seedUsers creates an admin user with the ADMIN_PASSWORD hash if there are
+// no users yet.
+func seedUsers(repo FAQRepository) error {
+	if adminLoginDisabled() {
+		return nil
+	}
+	users, err := repo.AllUsers()
+	if err != nil || len(users) > 0 {
+		return err
+	}
+	admin := User{Email: adminUserEmail, PasswordHash: adminPasswordHash, Role: roleAdmin, Locales: []string{}}
+	return repo.CreateUser(&admin, systemAuthor)
+}
+
+///// Persistence
+
+func (db *DB) AllUsers() ([]User, error) {
+	return getAllUsers(db.DB)
+}
+
+func (db *DB) UserByEmail(email string) (*User, error) {
+	return getUserByEmail(db.DB, email)
+}
+
+func (db *DB) CreateUser(user *User, author string) error {
+	return withAudit(db.DB, author, auditCreateUser, userDetails(user), func(tx *sql.Tx) error {
+		return createUser(tx, user)
+	})
+}
+
+func (db *DB) SaveUser(user *User, author string) error {
+	return withAudit(db.DB, author, auditSaveUser, userDetails(user), func(tx *sql.Tx) error {
+		return saveUser(tx, user)
+	})
+}
+
+func (db *DB) DeleteUser(id int, author string) error {
+	return withAudit(db.DB, author, auditDeleteUser, userIDDetails(id), func(tx *sql.Tx) error {
+		return deleteUser(tx, id)
+	})
+}
+
+func (mdb *mockDB) AllUsers() ([]User, error) {
+	return []User{User{ID: 1, Email: adminUserEmail, Role: roleAdmin, Locales: []string{}}}, nil
+}
+
+func (mdb *mockDB) UserByEmail(email string) (*User, error) {
+	return &User{ID: 1, Email: email, Role: roleAdmin, Locales: []string{}}, nil
+}
+
+func (mdb *mockDB) CreateUser(user *User, author string) error {
+	user.ID = 2
+	return nil
+}
+
+func (mdb *mockDB) SaveUser(user *User, author string) error {
+	return nil
+}
+
+func (mdb *mockDB) DeleteUser(id int, author string) error {
+	return nil
+}
+
+func (mdb *brokenDB) AllUsers() ([]User, error) {
+	return nil, errors.New(someDBError)
+}
+
+func (mdb *brokenDB) UserByEmail(email string) (*User, error) {
+	return nil, errors.New(someDBError)
+}
+
+func (mdb *brokenDB) CreateUser(user *User, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) SaveUser(user *User, author string) error {
+	return errors.New(someDBError)
+}
+
+func (mdb *brokenDB) DeleteUser(id int, author string) error {
+	return errors.New(someDBError)
+}
+
+const userColumns = `id, email, password_hash, role, locales`
+
+func scanUser(row interface {
+	Scan(dest ...interface{}) error
+}) (*User, error) {
+	user := User{}
+	var locales string
+	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Role, &locales)
+	if err != nil {
+		return nil, err
+	}
+	user.Locales = parseLocaleCodes(locales)
+	return &user, nil
+}
+
+func getAllUsers(db *sql.DB) ([]User, error) {
+	rows, err := db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY email;`)
+	if err != nil {
+		logError(err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			logError(err)
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
+
+func getUserByEmail(db *sql.DB, email string) (*User, error) {
+	user, err := scanUser(db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = $1;`, email))
+	if err == sql.ErrNoRows {
+		return nil, errUserNotFound
+	}
+	if err != nil {
+		logError(err)
+	}
+	return user, err
+}
+
+func createUser(db dbtx, user *User) error {
+	err := db.QueryRow(`INSERT INTO users (email, password_hash, role, locales) VALUES ($1, $2, $3, $4) RETURNING id;`,
+		user.Email, user.PasswordHash, user.Role, strings.Join(user.Locales, ",")).Scan(&user.ID)
+	if isUniqueViolation(err) {
+		return errUserExists
+	}
+	if err != nil {
+		logError(err)
+	}
+	return err
+}
+
+// saveUser updates password hash, role and locales of a user.
+func saveUser(db dbtx, user *User) error {
+	res, err := db.Exec(`UPDATE users SET password_hash = $1, role = $2, locales = $3 WHERE id = $4;`,
+		user.PasswordHash, user.Role, strings.Join(user.Locales, ","), user.ID)
+	if err != nil {
+		logError(err)
+		return err
+	}
+	return expectRowsAffected(res, errUserNotFound)
+}
+
+func deleteUser(db dbtx, id int) error {
+	res, err := db.Exec(`DELETE FROM users WHERE id = $1;`, id)
+	if err != nil {
+		logError(err)
+		return err
+	}
+	return expectRowsAffected(res, errUserNotFound)
+}
+
+///// Authentication
+
+// authenticateFunc checks the credentials of a login.
+var authenticateFunc = authenticateUser
+
+func authenticateUser(email string, password string) (*User, error) {
+	user, err := faqRepository.UserByEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if !checkPassword(user.PasswordHash, password) {
+		return nil, errors.New("wrong password")
+	}
+	return user, nil
+}
+
+// currentUser returns the logged in user. Users deleted since they logged
+// in are logged out.
+func currentUser(r *http.Request) (*User, bool) {
+	if adminLoginDisabled() {
+		return &User{Email: adminUserEmail, Role: roleAdmin, Locales: []string{}}, true
+	}
+	authCookie, err := r.Cookie(authCookieName)
+	if err != nil {
+		return nil, false
+	}
+	email, ok := adminJWTSubject(authCookie.Value)
+	if !ok {
+		return nil, false
+	}
+	user, err := faqRepository.UserByEmail(email)
+	if err != nil {
+		return nil, false
+	}
+	return user, true
+}
+
+// currentAuthor returns the email of the logged in user, recorded as the
+// author of the changes made in the admin UI.
+func currentAuthor(r *http.Request) string {
+	user, ok := currentUser(r)
+	if !ok {
+		return ""
+	}
+	return user.Email
+}
+
+// canEditLocale tells whether the logged in user may save texts in the
+// given locale.
+func canEditLocale(r *http.Request, localeCode string) bool {
+	user, ok := currentUser(r)
+	return ok && user.CanEditLocale(localeCode)
+}
+
+// requireRole lets logged in users with at least the given role through.
+// Others are sent to the login page or get 403 Forbidden.
+func requireRole(role string, h httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		user, ok := currentUser(r)
+		if !ok {
+			redirectToAdminLogin(w, r)
+			return
+		}
+		if !user.HasRole(role) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h(w, r, ps)
+	}
+}
+
+///// User handlers
+
+type UsersPageData struct {
+	PageTitle   string
+	MenuBar     []MenuEntry
+	Users       []User
+	Roles       []string
+	CurrentUser string
+	Error       string
+}
+
+func usersPageData(r *http.Request) UsersPageData {
+	users, err := faqRepository.AllUsers()
+	if err != nil {
+		panic(err)
+	}
+	return UsersPageData{
+		PageTitle:   "Admin / Users",
+		MenuBar:     menuBar("Users"),
+		Users:       users,
+		Roles:       roles,
+		CurrentUser: currentAuthor(r),
+	}
+}
+
+func getAdminUsers(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	mustExecuteTemplate(tmplAdminUsers, w, usersPageData(r))
+}
+
+func renderUsersError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	data := usersPageData(r)
+	data.Error = err.Error()
+	w.WriteHeader(status)
+	mustExecuteTemplate(tmplAdminUsers, w, data)
+}
+
+func postAdminUsersCreate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user := User{
+		Email:   strings.TrimSpace(r.FormValue("email")),
+		Role:    r.FormValue("role"),
+		Locales: parseLocaleCodes(r.FormValue("locales")),
+	}
+	if err := validateUser(&user); err != nil {
+		renderUsersError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	password := r.FormValue("password")
+	if len(password) < minPasswordLength {
+		renderUsersError(w, r, http.StatusBadRequest, errPasswordTooShort)
+		return
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	user.PasswordHash = hash
+
+	err = faqRepository.CreateUser(&user, currentAuthor(r))
+	if err == errUserExists {
+		renderUsersError(w, r, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusFound)
+}
+
+// postAdminUsersUpdate changes the role and locales of a user, and the
+// password if one is given. Admins can't take away their own admin role.
+func postAdminUsersUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user, status, err := userFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	user.Role = r.FormValue("role")
+	user.Locales = parseLocaleCodes(r.FormValue("locales"))
+	if err = validateUser(user); err != nil {
+		renderUsersError(w, r, http.StatusBadRequest, err)
+		return
+	}
+	if user.Email == currentAuthor(r) && user.Role != roleAdmin {
+		renderUsersError(w, r, http.StatusBadRequest, errors.New("you can't remove your own admin role"))
+		return
+	}
+	if password := r.FormValue("password"); len(password) > 0 {
+		if len(password) < minPasswordLength {
+			renderUsersError(w, r, http.StatusBadRequest, errPasswordTooShort)
+			return
+		}
+		if user.PasswordHash, err = hashPassword(password); err != nil {
+			http.Error(w, internalError, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err = faqRepository.SaveUser(user, currentAuthor(r)); err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusFound)
+}
+
+func postAdminUsersDelete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	user, status, err := userFromForm(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if user.Email == currentAuthor(r) {
+		renderUsersError(w, r, http.StatusBadRequest, errors.New("you can't delete yourself"))
+		return
+	}
+	if err = faqRepository.DeleteUser(user.ID, currentAuthor(r)); err != nil {
+		http.Error(w, internalError, http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/admin/users", http.StatusFound)
+}
+
+// userFromForm returns the user with the userID of the form. On failure it
+// returns the HTTP status to respond with.
+func userFromForm(r *http.Request) (*User, int, error) {
+	id, err := strconv.Atoi(r.FormValue("userID"))
+	if err != nil {
+		return nil, http.StatusNotFound, errUserNotFound
+	}
+	users, err := faqRepository.AllUsers()
+	if err != nil {
+		return nil, http.StatusInternalServerError, errors.New(internalError)
+	}
+	for i := range users {
+		if users[i].ID == id {
+			return &users[i], http.StatusOK, nil
+		}
+	}
+	return nil, http.StatusNotFound, errUserNotFound
+}
+
+const minPasswordLength = 8
+
+var errPasswordTooShort = fmt.Errorf("passwords need at least %d characters", minPasswordLength)
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// requireLogin turns the admin login on until the returned func is called.
+func requireLogin() func() {
+	previous := os.Getenv("ADMIN_PASSWORD")
+	os.Setenv("ADMIN_PASSWORD", "$2a$12$not-a-real-hash")
+	return func() { os.Setenv("ADMIN_PASSWORD", previous) }
+}
+
+func loginHeader(email string) *http.Header {
+	cookie := createAuthCookie(email)
+	header := formHeader()
+	header.Set("Cookie", cookie.String())
+	return header
+}
+
+func seedUsersDB(t *testing.T) *MemoryDB {
+	repo := seedTransferDB(t)
+	for _, user := range []User{
+		User{Email: "vera@example.com", Role: roleViewer},
+		User{Email: "tom@example.com", Role: roleTranslator, Locales: []string{"de"}},
+		User{Email: "eddie@example.com", Role: roleEditor},
+		User{Email: "ada@example.com", Role: roleAdmin},
+	} {
+		u := user
+		expectNoError(t, repo.CreateUser(&u, testAuthor))
+	}
+	return repo
+}
+
+func TestUserRoles(t *testing.T) {
+	translator := User{Email: "tom@example.com", Role: roleTranslator, Locales: []string{"de", "fr"}}
+	expectIsTrue(t, translator.HasRole(roleViewer))
+	expectIsTrue(t, translator.HasRole(roleTranslator))
+	expectIsTrue(t, !translator.HasRole(roleEditor))
+	expectIsTrue(t, translator.CanEditLocale("fr"))
+	expectIsTrue(t, !translator.CanEditLocale("en"))
+
+	editor := User{Role: roleEditor}
+	expectIsTrue(t, editor.CanEditLocale("en"))
+	expectIsTrue(t, !editor.HasRole(roleAdmin))
+
+	viewer := User{Email: "vera@example.com", Role: roleViewer, Locales: []string{"de"}}
+	expectIsTrue(t, !viewer.CanEditLocale("de"))
+	expectIsTrue(t, !(&User{Role: "root"}).HasRole(roleViewer))
+
+	expectSameString(t, "unknown role: root", validateUser(&User{Email: "x", Role: "root"}).Error())
+	expectSameString(t, "only translators are limited to locales", validateUser(&viewer).Error())
+	expectSameString(t, "email empty", validateUser(&User{Role: roleAdmin}).Error())
+	expectNoError(t, validateUser(&translator))
+}
+
+func TestRequireRole(t *testing.T) {
+	defer requireLogin()()
+	faqRepository = seedUsersDB(t)
+
+	resp := doRequest("GET", "/admin/faqs", emptyBody())
+	expectStatus(t, resp, 302)
+	expectHeader(t, resp, "Location", "/admin/login")
+
+	resp = doRequestWithHeader("GET", "/admin/faqs", emptyBody(), loginHeader("vera@example.com"))
+	expectStatus(t, resp, 200)
+	resp = doRequestWithHeader("POST", "/admin/faqs/update", body("faqID=2&localeCode=de&question=Q&answer=A"), loginHeader("vera@example.com"))
+	expectStatus(t, resp, 403)
+
+	resp = doRequestWithHeader("POST", "/admin/faqs/update", body("faqID=2&localeCode=fr&question=Q&answer=A"), loginHeader("tom@example.com"))
+	expectStatus(t, resp, 403)
+	resp = doRequestWithHeader("POST", "/admin/faqs/update", body("faqID=2&localeCode=de&question=Q&answer=A"), loginHeader("tom@example.com"))
+	expectStatus(t, resp, 302)
+	resp = doRequestWithHeader("POST", "/admin/faqs/create", body("localeCode=de&question=Q&answer=A"), loginHeader("tom@example.com"))
+	expectStatus(t, resp, 403)
+
+	resp = doRequestWithHeader("POST", "/admin/faqs/create", body("localeCode=en&question=Q&answer=A"), loginHeader("eddie@example.com"))
+	expectStatus(t, resp, 302)
+	resp = doRequestWithHeader("GET", "/admin/users", emptyBody(), loginHeader("eddie@example.com"))
+	expectStatus(t, resp, 403)
+
+	resp = doRequestWithHeader("GET", "/admin/users", emptyBody(), loginHeader("ada@example.com"))
+	expectStatus(t, resp, 200)
+
+	// Users deleted or never created are logged out
+	resp = doRequestWithHeader("GET", "/admin/faqs", emptyBody(), loginHeader("mallory@example.com"))
+	expectStatus(t, resp, 302)
+}
+
+func TestChangesRecordUser(t *testing.T) {
+	defer requireLogin()()
+	repo := seedUsersDB(t)
+	faqRepository = repo
+
+	resp := doRequestWithHeader("POST", "/admin/faqs/update", body("faqID=2&localeCode=de&question=Wie+zahle+ich%3F&answer=Bar."), loginHeader("tom@example.com"))
+	expectStatus(t, resp, 302)
+
+	revisions, err := repo.FAQTextRevisions(2, "de")
+	expectNoError(t, err)
+	expectSameString(t, "tom@example.com", revisions[0].Author)
+
+	resp = doRequestWithHeader("GET", "/admin/faqs/revisions/2/de", emptyBody(), loginHeader("vera@example.com"))
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, "<td>tom@example.com</td>")
+}
+
+func TestPostAdminLoginUser(t *testing.T) {
+	repo := NewMemoryDB()
+	faqRepository = repo
+	authenticateFunc = authenticateUser
+	defer func() { authenticateFunc = alwaysAdminFunc }()
+	hash, err := hashPassword("correct horse")
+	expectNoError(t, err)
+	expectNoError(t, repo.CreateUser(&User{Email: "tom@example.com", PasswordHash: hash, Role: roleTranslator}, testAuthor))
+
+	resp := doRequestWithHeader("POST", "/admin/login", body("email=tom@example.com&password=wrong"), &http.Header{"Content-Type": {"application/x-www-form-urlencoded"}})
+	expectHeader(t, resp, "Location", "/admin/login")
+	expectEmptyHeader(t, resp, "Set-Cookie")
+
+	resp = doRequestWithHeader("POST", "/admin/login", body("email=tom@example.com&password=correct+horse"), &http.Header{"Content-Type": {"application/x-www-form-urlencoded"}})
+	expectHeader(t, resp, "Location", "/admin/faqs")
+	r := httptest.NewRequest("GET", "/admin/faqs", nil)
+	r.Header.Set("Cookie", resp.Header().Get("Set-Cookie"))
+	defer requireLogin()()
+	user, ok := currentUser(r)
+	expectIsTrue(t, ok)
+	expectSameString(t, "tom@example.com", user.Email)
+}
+
+func TestAdminUsers(t *testing.T) {
+	repo := NewMemoryDB()
+	faqRepository = repo
+	header := formHeader()
+
+	resp := doRequestWithHeader("POST", "/admin/users/create", body("email=tom@example.com&password=secret&role=translator"), header)
+	expectStatus(t, resp, 400)
+	expectBodyContains(t, resp, "passwords need at least 8 characters")
+
+	resp = doRequestWithHeader("POST", "/admin/users/create", body("email=tom@example.com&password=secret123&role=translator&locales=de,fr"), header)
+	expectStatus(t, resp, 302)
+	resp = doRequestWithHeader("POST", "/admin/users/create", body("email=tom@example.com&password=secret123&role=viewer"), header)
+	expectStatus(t, resp, 409)
+
+	users, err := repo.AllUsers()
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(users))
+	tom := users[0]
+	expectSameString(t, "de,fr", tom.LocaleCodes())
+	expectIsTrue(t, checkPassword(tom.PasswordHash, "secret123"))
+
+	resp = doRequestWithHeader("POST", "/admin/users/update", body("userID=1&role=editor&locales=de"), header)
+	expectStatus(t, resp, 400)
+	expectBodyContains(t, resp, "only translators are limited to locales")
+	resp = doRequestWithHeader("POST", "/admin/users/update", body("userID=1&role=editor"), header)
+	expectStatus(t, resp, 302)
+	saved, err := repo.UserByEmail("tom@example.com")
+	expectNoError(t, err)
+	expectSameString(t, roleEditor, saved.Role)
+	expectSameString(t, tom.PasswordHash, saved.PasswordHash)
+
+	resp = doRequest("GET", "/admin/users", emptyBody())
+	expectStatus(t, resp, 200)
+	expectBodyContains(t, resp, `<title>Admin / Users</title>`)
+	expectBodyContains(t, resp, `<option value="editor" selected>editor</option>`)
+
+	resp = doRequestWithHeader("POST", "/admin/users/delete", body("userID=1"), header)
+	expectStatus(t, resp, 302)
+	resp = doRequestWithHeader("POST", "/admin/users/delete", body("userID=1"), header)
+	expectStatus(t, resp, 404)
+	users, _ = repo.AllUsers()
+	expectSameInt(t, 0, len(users))
+}
+
+func testUsers(t *testing.T, repo FAQRepository) {
+	defer requireLogin()()
+	expectNoError(t, seedUsers(repo))
+	expectNoError(t, seedUsers(repo))
+	users, err := repo.AllUsers()
+	expectNoError(t, err)
+	expectSameInt(t, 1, len(users))
+	expectSameString(t, adminUserEmail, users[0].Email)
+	expectSameString(t, roleAdmin, users[0].Role)
+
+	tom := User{Email: "tom@example.com", PasswordHash: "hash", Role: roleTranslator, Locales: []string{"de", "fr"}}
+	expectNoError(t, repo.CreateUser(&tom, testAuthor))
+	expectSameError(t, errUserExists, repo.CreateUser(&User{Email: "tom@example.com", Role: roleViewer}, testAuthor))
+
+	tom.Locales = []string{"fr"}
+	expectNoError(t, repo.SaveUser(&tom, testAuthor))
+	saved, err := repo.UserByEmail("tom@example.com")
+	expectNoError(t, err)
+	expectSameInt(t, tom.ID, saved.ID)
+	expectSameString(t, "fr", saved.LocaleCodes())
+
+	expectNoError(t, repo.DeleteUser(tom.ID, testAuthor))
+	expectSameError(t, errUserNotFound, repo.DeleteUser(tom.ID, testAuthor))
+	expectSameError(t, errUserNotFound, repo.SaveUser(&tom, testAuthor))
+	_, err = repo.UserByEmail("tom@example.com")
+	expectSameError(t, errUserNotFound, err)
+}
+
+func TestUsers(t *testing.T) {
+	testUsers(t, NewMemoryDB())
+}
+
+func TestUsersInDB(t *testing.T) {
+	testUsers(t, prepareDB())
+}
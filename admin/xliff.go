@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// XLIFF 2.0 documents exchange translations with CAT tools. Each FAQ is a
+// unit with a question and an answer segment; the default locale is the
+// source language.
+const (
+	xliffNamespace   = "urn:oasis:names:tc:xliff:document:2.0"
+	xliffVersion     = "2.0"
+	xliffContentType = "application/xliff+xml; charset=utf-8"
+)
+
+type xliffDocument struct {
+	XMLName xml.Name    `xml:"urn:oasis:names:tc:xliff:document:2.0 xliff"`
+	Version string      `xml:"version,attr"`
+	SrcLang string      `xml:"srcLang,attr"`
+	TrgLang string      `xml:"trgLang,attr,omitempty"`
+	Files   []xliffFile `xml:"file"`
+}
+
+type xliffFile struct {
+	ID    string      `xml:"id,attr"`
+	Units []xliffUnit `xml:"unit"`
+}
+
+type xliffUnit struct {
+	ID       string         `xml:"id,attr"`
+	Segments []xliffSegment `xml:"segment"`
+}
+
+type xliffSegment struct {
+	ID     string `xml:"id,attr"`
+	State  string `xml:"state,attr,omitempty"`
+	Source string `xml:"source"`
+	Target string `xml:"target,omitempty"`
+}
+
+func (u *xliffUnit) segment(id string) xliffSegment {
+	for _, s := range u.Segments {
+		if s.ID == id {
+			return s
+		}
+	}
+	return xliffSegment{}
+}
+
+func newXLIFFSegment(id, source, target string) xliffSegment {
+	state := "initial"
+	if len(target) > 0 {
+		state = "translated"
+	}
+	return xliffSegment{ID: id, State: state, Source: source, Target: target}
+}
+
+func encodeXLIFF(w io.Writer, localeCode string, units []TranslationUnit) error {
+	file := xliffFile{ID: "faqs", Units: []xliffUnit{}}
+	for _, unit := range units {
+		file.Units = append(file.Units, xliffUnit{
+			ID: unit.ID,
+			Segments: []xliffSegment{
+				newXLIFFSegment("question", unit.SourceQuestion, unit.Question),
+				newXLIFFSegment("answer", unit.SourceAnswer, unit.Answer),
+			},
+		})
+	}
+	doc := xliffDocument{
+		Version: xliffVersion,
+		SrcLang: getDefaultLocale().Code,
+		TrgLang: localeCode,
+		Files:   []xliffFile{file},
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(doc)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// readXLIFF decodes a translated XLIFF document and returns its target
+// locale and units. The source language must be the default locale.
+func readXLIFF(r io.Reader) (string, []TranslationUnit, error) {
+	doc := xliffDocument{}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return "", nil, errors.New("invalid XLIFF")
+	}
+	if doc.Version != xliffVersion {
+		return "", nil, fmt.Errorf("unsupported XLIFF version: %v", doc.Version)
+	}
+	if doc.SrcLang != getDefaultLocale().Code {
+		return "", nil, fmt.Errorf("source language must be %v, not %v", getDefaultLocale().Code, doc.SrcLang)
+	}
+	if err := validateTargetLocale(doc.TrgLang); err != nil {
+		return "", nil, err
+	}
+
+	units := []TranslationUnit{}
+	for _, f := range doc.Files {
+		for _, u := range f.Units {
+			question, answer := u.segment("question"), u.segment("answer")
+			units = append(units, TranslationUnit{
+				ID:             u.ID,
+				FAQID:          parseUnitID(u.ID),
+				SourceQuestion: question.Source,
+				SourceAnswer:   answer.Source,
+				Question:       question.Target,
+				Answer:         answer.Target,
+			})
+		}
+	}
+	return doc.TrgLang, units, nil
+}
@@ -1,3 +0,0 @@
-DROP MATERIALIZED VIEW search_index;
-DROP TABLE faq_texts;
-DROP TABLE faqs;